@@ -3,8 +3,11 @@ module backend
 go 1.24.4
 
 require (
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/grandcat/zeroconf v1.0.0
+	github.com/klauspost/compress v1.18.0
 	github.com/shirou/gopsutil v3.21.11+incompatible
+	golang.org/x/time v0.12.0
 	google.golang.org/grpc v1.73.0
 	google.golang.org/protobuf v1.36.6
 )
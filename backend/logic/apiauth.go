@@ -0,0 +1,98 @@
+package logic
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// The REST API controls pairing, consent and every transfer, so it only
+// answers the local frontend by default. Requests from other hosts, which
+// reach it only when api_address is widened, must carry the per-install
+// token from api_token.
+
+const (
+	apiTokenFile = "api_token"
+
+	// The frontend allowed to call the API from a browser
+	FrontendOrigin = "http://localhost:9000"
+)
+
+var apiToken string
+
+// InitAPIToken loads the API token from api_token, creating it on first start
+func InitAPIToken() {
+	data, err := os.ReadFile(apiTokenFile)
+	if token := strings.TrimSpace(string(data)); err == nil && token != "" {
+		apiToken = token
+		return
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Error reading %s: %v", apiTokenFile, err)
+	}
+
+	log.Printf("Generating API token in %s...", apiTokenFile)
+	apiToken = generateRandomID()
+	if err := os.WriteFile(apiTokenFile, []byte(apiToken+"\n"), 0600); err != nil {
+		log.Fatalf("Failed to write API token: %v", err)
+	}
+}
+
+// RequireAPIAuth admits requests from the local frontend, and from anywhere
+// else only with the API token. Pages from other origins are refused even
+// on this host, as are host names other than loopback, which a DNS
+// rebinding page would send.
+func RequireAPIAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && origin != FrontendOrigin {
+			log.Printf("Refusing API request from origin %s", origin)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if !isLoopbackRequest(r) && !hasAPIToken(r) {
+			log.Printf("Refusing unauthenticated API request from %s", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackRequest reports whether a request came from this host and
+// named it by a loopback address
+func isLoopbackRequest(r *http.Request) bool {
+	remoteHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !isLoopbackHost(remoteHost) {
+		return false
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return isLoopbackHost(strings.Trim(host, "[]"))
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback address
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// hasAPIToken reports whether a request carries the API token, as a bearer
+// token or, for EventSource which can't set headers, a token parameter
+func hasAPIToken(r *http.Request) bool {
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return apiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) == 1
+}
@@ -0,0 +1,74 @@
+package logic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAPIAuth(t *testing.T) {
+	previous := apiToken
+	apiToken = "secret"
+	t.Cleanup(func() { apiToken = previous })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		host       string
+		origin     string
+		header     string // Authorization header
+		query      string
+		wantCode   int
+	}{
+		{name: "local frontend", remoteAddr: "127.0.0.1:50000", host: "localhost:8080", origin: FrontendOrigin, wantCode: http.StatusOK},
+		{name: "local without origin", remoteAddr: "127.0.0.1:50000", host: "127.0.0.1:8080", wantCode: http.StatusOK},
+		{name: "local IPv6", remoteAddr: "[::1]:50000", host: "[::1]:8080", wantCode: http.StatusOK},
+		{name: "other origin on this host", remoteAddr: "127.0.0.1:50000", host: "localhost:8080", origin: "http://evil.example", wantCode: http.StatusForbidden},
+		{name: "other origin with token", remoteAddr: "192.168.1.5:50000", host: "192.168.1.2:8080", origin: "http://evil.example", header: "Bearer secret", wantCode: http.StatusForbidden},
+		{name: "rebound host name", remoteAddr: "127.0.0.1:50000", host: "evil.example:8080", wantCode: http.StatusUnauthorized},
+		{name: "other host", remoteAddr: "192.168.1.5:50000", host: "192.168.1.2:8080", wantCode: http.StatusUnauthorized},
+		{name: "other host with token", remoteAddr: "192.168.1.5:50000", host: "192.168.1.2:8080", header: "Bearer secret", wantCode: http.StatusOK},
+		{name: "other host with token parameter", remoteAddr: "192.168.1.5:50000", host: "192.168.1.2:8080", query: "?token=secret", wantCode: http.StatusOK},
+		{name: "other host with wrong token", remoteAddr: "192.168.1.5:50000", host: "192.168.1.2:8080", header: "Bearer guess", wantCode: http.StatusUnauthorized},
+		{name: "token without bearer", remoteAddr: "192.168.1.5:50000", host: "192.168.1.2:8080", header: "secret", wantCode: http.StatusUnauthorized},
+	}
+
+	handler := RequireAPIAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/peers"+tt.query, nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestInitAPITokenPersists(t *testing.T) {
+	t.Chdir(t.TempDir())
+	previous := apiToken
+	t.Cleanup(func() { apiToken = previous })
+
+	InitAPIToken()
+	first := apiToken
+	if first == "" {
+		t.Fatal("no API token generated")
+	}
+
+	apiToken = ""
+	InitAPIToken()
+	if apiToken != first {
+		t.Fatalf("API token after restart = %q, want %q", apiToken, first)
+	}
+}
@@ -0,0 +1,449 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BrowseEntryInfo describes an entry in a peer's shared folder
+type BrowseEntryInfo struct {
+	Path    string `json:"path"`
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	IsDir   bool   `json:"is_dir,omitempty"`
+	ModTime string `json:"mod_time"`
+	Mode    uint32 `json:"mode"`
+	Sha256  string `json:"sha256,omitempty"`
+
+	// Set when the digest was asked for but would not fit in the page's
+	// hashing budget; stat the file for it
+	HashPending bool `json:"hash_pending,omitempty"`
+}
+
+type BrowseFilesResponse struct {
+	Peer          string            `json:"peer"`
+	Path          string            `json:"path"`
+	Entries       []BrowseEntryInfo `json:"entries"`
+	Count         int               `json:"count"`
+	NextPageToken string            `json:"next_page_token,omitempty"`
+}
+
+const (
+	// Entries per page when the caller doesn't ask, and the most allowed
+	defaultBrowsePageSize = 100
+	maxBrowsePageSize     = 1000
+
+	// Entries a recursive search looks at before asking for a narrower one
+	maxBrowseScan = 100000
+
+	// Bytes of uncached files one page hashes at most
+	maxBrowseHashBytes = 256 * 1024 * 1024
+
+	// Digests remembered; beyond this the oldest are forgotten
+	maxHashCacheEntries = 4096
+)
+
+var errTooManyEntries = status.Error(codes.ResourceExhausted, "too many entries, narrow the search")
+
+// Digests of shared files, keyed by transfer ID so an edited file is hashed again
+var (
+	hashCache      = make(map[string]string)
+	hashCacheOrder []string // oldest first
+	hashCacheMutex sync.Mutex
+)
+
+// browseServer serves a read-only view of the shared folder
+type browseServer struct {
+	pb.UnimplementedBrowseServiceServer
+}
+
+// sharedEntry is a file or directory found in the shared folder
+type sharedEntry struct {
+	path string
+	info fs.FileInfo
+}
+
+// readSharedDir lists a directory in the shared folder, or its whole subtree
+// when recursive. Entries a peer could not download are left out, and
+// symlinks are followed only when they stay inside the shared folder.
+func readSharedDir(root *os.Root, relPath string, recursive bool) ([]sharedEntry, error) {
+	var entries []sharedEntry
+
+	err := fs.WalkDir(root.FS(), relPath, func(entryPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if entryPath == relPath {
+				return err
+			}
+			return nil
+		}
+		if entryPath == relPath {
+			return nil
+		}
+
+		// Names a receiver would reject can't be downloaded anyway
+		if name, err := sanitizeFileName(d.Name()); err != nil || name != d.Name() {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if len(entries) >= maxBrowseScan {
+			return errTooManyEntries
+		}
+
+		info, err := root.Stat(entryPath)
+		if err == nil && (info.IsDir() || info.Mode().IsRegular()) {
+			entries = append(entries, sharedEntry{path: entryPath, info: info})
+		}
+
+		if d.IsDir() && !recursive {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(entries, func(a, b sharedEntry) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return entries, nil
+}
+
+// matchesPattern reports whether a name matches a search: a glob when the
+// pattern has glob characters, otherwise a case-insensitive substring
+func matchesPattern(name, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(pattern))
+}
+
+// cachedFileHash returns the digest of a shared file if it was hashed since
+// it last changed
+func cachedFileHash(sharedDir string, entry sharedEntry) (string, bool) {
+	key := describeSharedFile(sharedDir, entry.path, entry.info).TransferId
+
+	hashCacheMutex.Lock()
+	defer hashCacheMutex.Unlock()
+
+	digest, ok := hashCache[key]
+	return digest, ok
+}
+
+// sharedFileHash returns the SHA-256 of a shared file, hashing it only when
+// it has changed since it was last hashed. Hashing stops when ctx ends.
+func sharedFileHash(ctx context.Context, root *os.Root, sharedDir string, entry sharedEntry) (string, error) {
+	if digest, ok := cachedFileHash(sharedDir, entry); ok {
+		return digest, nil
+	}
+
+	file, err := root.Open(entry.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	buf := make([]byte, basisCopySize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := file.Read(buf)
+		hasher.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	digest := hex.EncodeToString(hasher.Sum(nil))
+
+	key := describeSharedFile(sharedDir, entry.path, entry.info).TransferId
+	hashCacheMutex.Lock()
+	if _, ok := hashCache[key]; !ok {
+		// Forget the oldest digest to make room
+		if len(hashCacheOrder) >= maxHashCacheEntries {
+			delete(hashCache, hashCacheOrder[0])
+			hashCacheOrder = hashCacheOrder[1:]
+		}
+		hashCacheOrder = append(hashCacheOrder, key)
+	}
+	hashCache[key] = digest
+	hashCacheMutex.Unlock()
+
+	return digest, nil
+}
+
+// browseEntry converts a shared entry for the wire, with its digest if
+// asked. hashBudget, when not nil, is the bytes left to hash for the page; a
+// file that doesn't fit is marked hash pending instead.
+func browseEntry(ctx context.Context, root *os.Root, sharedDir string, entry sharedEntry, withHash bool, hashBudget *int64) (*pb.BrowseEntry, error) {
+	metadata := entryMetadata(entry.info)
+	result := &pb.BrowseEntry{
+		Path:        entry.path,
+		Name:        path.Base(entry.path),
+		IsDirectory: entry.info.IsDir(),
+		Mtime:       metadata.ModTime,
+		Mode:        metadata.Mode,
+	}
+
+	if entry.info.Mode().IsRegular() {
+		result.Size = entry.info.Size()
+		if withHash {
+			if _, cached := cachedFileHash(sharedDir, entry); !cached && hashBudget != nil {
+				if result.Size > *hashBudget {
+					result.HashPending = true
+					return result, nil
+				}
+				*hashBudget -= result.Size
+			}
+
+			digest, err := sharedFileHash(ctx, root, sharedDir, entry)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, status.FromContextError(ctxErr).Err()
+			}
+			if err != nil {
+				log.Printf("Error hashing shared file %s: %v", entry.path, err)
+				return nil, status.Errorf(codes.Internal, "failed to hash %s", entry.path)
+			}
+			result.Sha256 = digest
+		}
+	}
+
+	return result, nil
+}
+
+// Browse lists a directory in the shared folder a page at a time, in path
+// order. A pattern filters entries by name, and recursive searches the whole
+// subtree. The page token is the last path of the previous page, so pages
+// stay consistent while files are added or removed. Digests, when asked
+// for, are limited to maxBrowseHashBytes of hashing per page.
+func (s *browseServer) Browse(ctx context.Context, req *pb.BrowseRequest) (*pb.BrowseResponse, error) {
+	relPath, err := sharedRelPath(req.Path)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if _, err := path.Match(req.Pattern, ""); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid search pattern")
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = defaultBrowsePageSize
+	}
+	pageSize = min(pageSize, maxBrowsePageSize)
+
+	root, sharedDir, err := openSharedDir()
+	if err != nil {
+		log.Printf("Error opening shared folder: %v", err)
+		return nil, status.Error(codes.Internal, "shared folder is unavailable")
+	}
+	defer root.Close()
+
+	info, err := root.Stat(relPath)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Path)
+	}
+	if !info.IsDir() {
+		return nil, status.Errorf(codes.InvalidArgument, "%s is not a directory", req.Path)
+	}
+
+	entries, err := readSharedDir(root, relPath, req.Recursive)
+	if errors.Is(err, errTooManyEntries) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Error reading shared directory %s: %v", relPath, err)
+		return nil, status.Error(codes.Internal, "failed to read directory")
+	}
+
+	response := &pb.BrowseResponse{}
+	hashBudget := int64(maxBrowseHashBytes)
+	for _, entry := range entries {
+		// The caller may give up on a slow page, hashing above all
+		if err := ctx.Err(); err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+
+		if req.PageToken != "" && entry.path <= req.PageToken {
+			continue
+		}
+		if !matchesPattern(path.Base(entry.path), req.Pattern) {
+			continue
+		}
+
+		if len(response.Entries) == pageSize {
+			response.NextPageToken = response.Entries[pageSize-1].Path
+			break
+		}
+
+		result, err := browseEntry(ctx, root, sharedDir, entry, req.IncludeHashes, &hashBudget)
+		if err != nil {
+			return nil, err
+		}
+		response.Entries = append(response.Entries, result)
+	}
+
+	return response, nil
+}
+
+// Stat describes one file or directory in the shared folder. Files always
+// include their digest.
+func (s *browseServer) Stat(ctx context.Context, req *pb.StatRequest) (*pb.BrowseEntry, error) {
+	relPath, err := sanitizeRelativePath(req.Path)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	root, sharedDir, err := openSharedDir()
+	if err != nil {
+		log.Printf("Error opening shared folder: %v", err)
+		return nil, status.Error(codes.Internal, "shared folder is unavailable")
+	}
+	defer root.Close()
+
+	info, err := root.Stat(relPath)
+	if err != nil || (!info.IsDir() && !info.Mode().IsRegular()) {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Path)
+	}
+
+	return browseEntry(ctx, root, sharedDir, sharedEntry{path: relPath, info: info}, true, nil)
+}
+
+// browseEntryInfo converts a browse entry for the REST API
+func browseEntryInfo(entry *pb.BrowseEntry) BrowseEntryInfo {
+	return BrowseEntryInfo{
+		Path:    entry.Path,
+		Name:    entry.Name,
+		Size:    entry.Size,
+		IsDir:   entry.IsDirectory,
+		ModTime: time.Unix(0, entry.Mtime).UTC().Format(time.RFC3339),
+		Mode:    entry.Mode,
+		Sha256:  entry.Sha256,
+
+		HashPending: entry.HashPending,
+	}
+}
+
+// BrowsePeerFiles HTTP handler that lists or searches a peer's shared folder.
+// Query parameters: path, q (glob or substring), recursive, page_size,
+// page_token and hashes.
+func BrowsePeerFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	pageSize, _ := strconv.Atoi(query.Get("page_size"))
+	recursive, _ := strconv.ParseBool(query.Get("recursive"))
+	hashes, _ := strconv.ParseBool(query.Get("hashes"))
+
+	peer := sharedPeer(w, r)
+	if peer == nil {
+		return
+	}
+
+	conn, err := dialPeer(peer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	page, err := pb.NewBrowseServiceClient(conn).Browse(ctx, &pb.BrowseRequest{
+		Path:          query.Get("path"),
+		Pattern:       query.Get("q"),
+		Recursive:     recursive,
+		PageSize:      int32(min(max(pageSize, 0), maxBrowsePageSize)),
+		PageToken:     query.Get("page_token"),
+		IncludeHashes: hashes,
+	})
+	if err != nil {
+		log.Printf("Error browsing files on %s: %v", peer.Hostname, err)
+		http.Error(w, status.Convert(err).Message(), sharedErrorStatus(err))
+		return
+	}
+
+	entries := make([]BrowseEntryInfo, len(page.Entries))
+	for i, entry := range page.Entries {
+		entries[i] = browseEntryInfo(entry)
+	}
+
+	response := BrowseFilesResponse{
+		Peer:          peer.Hostname,
+		Path:          query.Get("path"),
+		Entries:       entries,
+		Count:         len(entries),
+		NextPageToken: page.NextPageToken,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// StatPeerFile HTTP handler that describes one entry in a peer's shared
+// folder, including a file's SHA-256
+func StatPeerFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	peer := sharedPeer(w, r)
+	if peer == nil {
+		return
+	}
+
+	conn, err := dialPeer(peer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+
+	// Hashing a large file takes a while on the first request
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	entry, err := pb.NewBrowseServiceClient(conn).Stat(ctx, &pb.StatRequest{Path: r.URL.Query().Get("path")})
+	if err != nil {
+		log.Printf("Error getting file info from %s: %v", peer.Hostname, err)
+		http.Error(w, status.Convert(err).Message(), sharedErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(browseEntryInfo(entry))
+}
@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// withHashCache gives the test an empty digest cache
+func withHashCache(t *testing.T) {
+	hashCacheMutex.Lock()
+	previous, previousOrder := hashCache, hashCacheOrder
+	hashCache, hashCacheOrder = make(map[string]string), nil
+	hashCacheMutex.Unlock()
+
+	t.Cleanup(func() {
+		hashCacheMutex.Lock()
+		hashCache, hashCacheOrder = previous, previousOrder
+		hashCacheMutex.Unlock()
+	})
+}
+
+// sharedRoot opens the configured shared folder for the rest of the test
+func sharedRoot(t *testing.T) (*os.Root, string) {
+	t.Helper()
+
+	root, sharedDir, err := openSharedDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root.Close() })
+	return root, sharedDir
+}
+
+// statShared describes an entry of the shared folder as readSharedDir does
+func statShared(t *testing.T, root *os.Root, relPath string) sharedEntry {
+	t.Helper()
+
+	info, err := root.Stat(relPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sharedEntry{path: relPath, info: info}
+}
+
+func TestBrowseEntryHashBudget(t *testing.T) {
+	t.Chdir(t.TempDir())
+	withHashCache(t)
+	withConfig(t, func(c *Config) { c.SharedDir = "shared" })
+	makeTree(t, ".", map[string]int{"shared/a.bin": 10, "shared/b.bin": 20, "shared/c.bin": 5, "shared/d.bin": 30})
+	root, sharedDir := sharedRoot(t)
+
+	// Digests already known cost nothing
+	if _, err := sharedFileHash(context.Background(), root, sharedDir, statShared(t, root, "d.bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries of one page, in order, sharing its budget
+	budget := int64(25)
+	tests := []struct {
+		file        string
+		wantPending bool
+		wantBudget  int64
+	}{
+		{file: "a.bin", wantBudget: 15},
+		{file: "b.bin", wantPending: true, wantBudget: 15},
+		{file: "c.bin", wantBudget: 10},
+		{file: "d.bin", wantBudget: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			entry, err := browseEntry(context.Background(), root, sharedDir, statShared(t, root, tt.file), true, &budget)
+			if err != nil {
+				t.Fatalf("browseEntry: %v", err)
+			}
+			if entry.HashPending != tt.wantPending || (entry.Sha256 == "") != tt.wantPending {
+				t.Fatalf("digest %q, pending %v, want pending %v", entry.Sha256, entry.HashPending, tt.wantPending)
+			}
+			if budget != tt.wantBudget {
+				t.Fatalf("budget left = %d, want %d", budget, tt.wantBudget)
+			}
+		})
+	}
+}
+
+func TestBrowseStopsWhenCancelled(t *testing.T) {
+	t.Chdir(t.TempDir())
+	withHashCache(t)
+	makeSharedDir(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := (&browseServer{}).Browse(ctx, &pb.BrowseRequest{Recursive: true, IncludeHashes: true})
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("Browse error = %v, want %s", err, codes.Canceled)
+	}
+
+	hashCacheMutex.Lock()
+	defer hashCacheMutex.Unlock()
+	if len(hashCache) != 0 {
+		t.Fatalf("cancelled browse hashed %d files", len(hashCache))
+	}
+}
+
+func TestHashCacheEvictsOldest(t *testing.T) {
+	t.Chdir(t.TempDir())
+	withHashCache(t)
+	withConfig(t, func(c *Config) { c.SharedDir = "shared" })
+	makeTree(t, ".", map[string]int{"shared/a.bin": 10})
+	root, sharedDir := sharedRoot(t)
+
+	hashCacheMutex.Lock()
+	for i := range maxHashCacheEntries {
+		key := fmt.Sprintf("key-%d", i)
+		hashCache[key] = "digest"
+		hashCacheOrder = append(hashCacheOrder, key)
+	}
+	hashCacheMutex.Unlock()
+
+	if _, err := sharedFileHash(context.Background(), root, sharedDir, statShared(t, root, "a.bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	hashCacheMutex.Lock()
+	defer hashCacheMutex.Unlock()
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "key-0"},
+		{key: "key-1", want: true},
+		{key: fmt.Sprintf("key-%d", maxHashCacheEntries-1), want: true},
+	}
+	for _, tt := range tests {
+		if _, ok := hashCache[tt.key]; ok != tt.want {
+			t.Fatalf("%s cached = %v, want %v", tt.key, ok, tt.want)
+		}
+	}
+	if len(hashCache) != maxHashCacheEntries || len(hashCacheOrder) != maxHashCacheEntries {
+		t.Fatalf("cache holds %d digests in order of %d, want %d", len(hashCache), len(hashCacheOrder), maxHashCacheEntries)
+	}
+}
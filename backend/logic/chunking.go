@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Bounds of the adaptive chunk size. gRPC refuses messages over 4 MB by
+	// default, so the largest chunk leaves plenty of room.
+	minChunkSize     = 16 * 1024
+	initialChunkSize = 64 * 1024
+	maxChunkSize     = 1024 * 1024
+
+	// Each chunk should take about this long to send at the measured rate,
+	// or a whole round trip on slower links
+	chunkTargetDuration = 20 * time.Millisecond
+
+	// How long the rate is measured before the chunk size changes
+	chunkAdjustInterval = 200 * time.Millisecond
+
+	// Room after a full chunk for the tag added when it is sealed in place
+	chunkBufferSlack = 64
+)
+
+// Buffers for reading and sealing chunks, shared by every stream
+var chunkBufferPool = sync.Pool{
+	New: func() any {
+		buffer := make([]byte, maxChunkSize+chunkBufferSlack)
+		return &buffer
+	},
+}
+
+// getChunkBuffer returns a pooled buffer big enough for any chunk
+func getChunkBuffer() *[]byte {
+	return chunkBufferPool.Get().(*[]byte)
+}
+
+// putChunkBuffer returns a buffer to the pool once its chunk has been sent
+func putChunkBuffer(buffer *[]byte) {
+	chunkBufferPool.Put(buffer)
+}
+
+// chunkSizer picks the size of the next chunk on a stream. Larger chunks
+// cut per-message overhead on fast or distant links; smaller ones keep
+// progress and stall detection responsive on slow ones.
+type chunkSizer struct {
+	size  int
+	fixed bool
+	rtt   time.Duration
+
+	windowStart time.Time
+	windowBytes int64
+}
+
+// newChunkSizer starts a stream's sizing from the configured size, or from
+// the default when it adapts. rtt is the round trip measured before the
+// stream, or 0 if unknown.
+func newChunkSizer(rtt time.Duration) *chunkSizer {
+	s := &chunkSizer{
+		size:        initialChunkSize,
+		rtt:         rtt,
+		windowStart: time.Now(),
+	}
+
+	if kb := GetConfig().ChunkSizeKB; kb > 0 {
+		s.size = min(max(kb*1024, minChunkSize), maxChunkSize)
+		s.fixed = true
+	}
+	return s
+}
+
+// next returns the size of the next chunk
+func (s *chunkSizer) next() int {
+	return s.size
+}
+
+// record notes n bytes sent and, once the rate has been measured long
+// enough, doubles or halves the chunk size towards the target
+func (s *chunkSizer) record(n int) {
+	if s.fixed {
+		return
+	}
+
+	s.windowBytes += int64(n)
+	elapsed := time.Since(s.windowStart)
+	if elapsed < chunkAdjustInterval {
+		return
+	}
+
+	rate := float64(s.windowBytes) / elapsed.Seconds()
+	want := int(rate * max(chunkTargetDuration, s.rtt).Seconds())
+
+	// One step per window, so a single slow sample can't collapse the size
+	switch {
+	case want >= s.size*2 && s.size < maxChunkSize:
+		s.size *= 2
+	case want < s.size/2 && s.size > minChunkSize:
+		s.size /= 2
+	}
+
+	s.windowStart = time.Now()
+	s.windowBytes = 0
+}
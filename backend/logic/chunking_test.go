@@ -0,0 +1,74 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"os"
+	"testing"
+
+	pb "backend/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Bytes an in-memory connection buffers in each direction
+const bufconnSize = 4 * 1024 * 1024
+
+// bufconnPeer serves this node over an in-memory listener, so the
+// benchmark measures the send and receive path rather than the network,
+// and returns a client connected to it
+func bufconnPeer(b *testing.B) (*Peer, pb.FileTransferServiceClient) {
+	quietLogs(b)
+	testNode(b)
+
+	// Every iteration receives the same file, replacing the last copy
+	withConfig(b, func(c *Config) { c.CollisionPolicy = collisionOverwrite })
+
+	lis := bufconn.Listen(bufconnSize)
+	server := newGRPCServer()
+	go server.Serve(lis)
+	b.Cleanup(server.Stop)
+
+	peer := &Peer{ID: systemInfo.PeerID, Hostname: "bench", Verified: true}
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(credentials.NewTLS(clientTLSConfig(peer))),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+
+	return peer, pb.NewFileTransferServiceClient(conn)
+}
+
+// benchmarkChunking measures sending one file over a single stream with
+// the chunk size fixed at chunkSizeKB, or adapting when it is 0
+func benchmarkChunking(b *testing.B, chunkSizeKB int) {
+	peer, client := bufconnPeer(b)
+
+	withConfig(b, func(c *Config) { c.ChunkSizeKB = chunkSizeKB })
+
+	data := make([]byte, benchmarkFileSize)
+	rand.Read(data)
+	if err := os.WriteFile("bench.bin", data, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(benchmarkFileSize)
+	b.ReportAllocs()
+	for b.Loop() {
+		transfer := newTransfer(peer, []string{"bench.bin"}, transferOptions{})
+		if err := sendTransfer(context.Background(), client, peer, transfer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChunkingFixed64K(b *testing.B) { benchmarkChunking(b, 64) }
+func BenchmarkChunkingFixed1M(b *testing.B)  { benchmarkChunking(b, 1024) }
+func BenchmarkChunkingAdaptive(b *testing.B) { benchmarkChunking(b, 0) }
@@ -0,0 +1,218 @@
+package logic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	pb "backend/proto"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Chunk data can be compressed before it is sealed. The sender lists the
+// codecs it can use in its offer and the receiver picks one. Each chunk is
+// compressed on its own and names its codec, so resumes and parallel
+// ranges need nothing extra, and a chunk that would not shrink goes as it
+// is. Files that are already compressed are skipped altogether.
+
+const (
+	compressionZstd = "zstd"
+	compressionGzip = "gzip"
+
+	// Bytes read from the start of a file to judge whether it compresses
+	compressionSampleSize = 64 * 1024
+
+	// Samples with more bits of entropy per byte than this are already
+	// compressed or encrypted
+	maxCompressibleEntropy = 7.5
+)
+
+// Codecs this node can use, most preferred first
+var supportedCompression = []string{compressionZstd, compressionGzip}
+
+// Extensions of formats that are compressed already
+var compressedExtensions = map[string]bool{
+	".7z": true, ".aac": true, ".apk": true, ".avi": true, ".br": true,
+	".bz2": true, ".docx": true, ".flac": true, ".gif": true, ".gz": true,
+	".heic": true, ".jar": true, ".jpeg": true, ".jpg": true, ".lz4": true,
+	".m4a": true, ".m4v": true, ".mkv": true, ".mov": true, ".mp3": true,
+	".mp4": true, ".odt": true, ".ogg": true, ".opus": true, ".pdf": true,
+	".png": true, ".pptx": true, ".rar": true, ".tgz": true, ".webm": true,
+	".webp": true, ".xlsx": true, ".xz": true, ".zip": true, ".zst": true,
+}
+
+// Shared codecs; EncodeAll and DecodeAll are safe for concurrent use. The
+// decoder refuses to expand a chunk beyond the largest chunk size.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxChunkSize))
+
+	gzipWriterPool = sync.Pool{
+		New: func() any {
+			writer, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+			return writer
+		},
+	}
+)
+
+// offeredCompression returns the codecs this node is willing to use, in
+// order of preference, as set by the compression option
+func offeredCompression() []string {
+	switch setting := GetConfig().Compression; setting {
+	case "off":
+		return nil
+	case compressionZstd, compressionGzip:
+		return []string{setting}
+	default:
+		return supportedCompression
+	}
+}
+
+// chooseCompression picks the first codec a sender offered that this node
+// is also willing to use, or "" to send data as it is
+func chooseCompression(offered []string) string {
+	accepted := offeredCompression()
+	for _, codec := range offered {
+		if slices.Contains(accepted, codec) {
+			return codec
+		}
+	}
+	return ""
+}
+
+// worthCompressing reports whether a file is likely to shrink, judging by
+// its extension and the entropy of its first bytes
+func worthCompressing(name string, file io.ReaderAt) bool {
+	if compressedExtensions[strings.ToLower(filepath.Ext(name))] {
+		return false
+	}
+
+	sample := make([]byte, compressionSampleSize)
+	n, err := file.ReadAt(sample, 0)
+	if err != nil && err != io.EOF {
+		return false
+	}
+	return byteEntropy(sample[:n]) <= maxCompressibleEntropy
+}
+
+// byteEntropy returns the Shannon entropy of data in bits per byte
+func byteEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(len(data))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// compressChunk compresses data with codec into dst's storage, returning
+// nil if the result would be no smaller
+func compressChunk(codec string, data, dst []byte) []byte {
+	var packed []byte
+
+	switch codec {
+	case compressionZstd:
+		packed = zstdEncoder.EncodeAll(data, dst[:0])
+	case compressionGzip:
+		buffer := bytes.NewBuffer(dst[:0])
+		writer := gzipWriterPool.Get().(*gzip.Writer)
+		defer gzipWriterPool.Put(writer)
+
+		writer.Reset(buffer)
+		if _, err := writer.Write(data); err != nil {
+			return nil
+		}
+		if err := writer.Close(); err != nil {
+			return nil
+		}
+		packed = buffer.Bytes()
+	default:
+		return nil
+	}
+
+	if len(packed) >= len(data) {
+		return nil
+	}
+	return packed
+}
+
+// decompressChunk expands data compressed with codec into dst's storage,
+// refusing anything larger than a chunk
+func decompressChunk(codec string, data, dst []byte) ([]byte, error) {
+	switch codec {
+	case compressionZstd:
+		return zstdDecoder.DecodeAll(data, dst[:0])
+	case compressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		buffer := bytes.NewBuffer(dst[:0])
+		if _, err := buffer.ReadFrom(io.LimitReader(reader, maxChunkSize+1)); err != nil {
+			return nil, err
+		}
+		if buffer.Len() > maxChunkSize {
+			return nil, fmt.Errorf("expands beyond %d bytes", maxChunkSize)
+		}
+		return buffer.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", codec)
+	}
+}
+
+// inflateChunk returns a received chunk's file data, decompressing the
+// opened data into dst's storage if the chunk names a codec
+func inflateChunk(chunk *pb.FileChunk, data, dst []byte) ([]byte, error) {
+	if chunk.Compression == "" {
+		return data, nil
+	}
+	if !slices.Contains(supportedCompression, chunk.Compression) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported compression %q", chunk.Compression)
+	}
+
+	inflated, err := decompressChunk(chunk.Compression, data, dst)
+	if err != nil {
+		log.Printf("Chunk %d for %s failed to decompress: %v", chunk.ChunkNumber, chunk.FileName, err)
+		return nil, status.Errorf(codes.DataLoss, "chunk %d failed to decompress", chunk.ChunkNumber)
+	}
+	return inflated, nil
+}
+
+// compress puts a chunk's data in place, compressed into dst's storage
+// when the file is worth compressing and the chunk shrinks, and counts the
+// bytes for the transfer
+func (o *outgoingFile) compress(chunk *pb.FileChunk, data, dst []byte) {
+	chunk.Data = data
+	if o.compression == "" || len(data) == 0 {
+		return
+	}
+
+	if o.compressFile {
+		if packed := compressChunk(o.compression, data, dst); packed != nil {
+			chunk.Data = packed
+			chunk.Compression = o.compression
+		}
+	}
+	o.transfer.addCompressedBytes(int64(len(data)), int64(len(chunk.Data)))
+}
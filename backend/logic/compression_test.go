@@ -0,0 +1,163 @@
+package logic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	pb "backend/proto"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChooseCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string // the receiver's compression option
+		offered []string
+		want    string
+	}{
+		{name: "auto prefers the sender's first choice", setting: "auto", offered: []string{compressionZstd, compressionGzip}, want: compressionZstd},
+		{name: "auto takes the sender's order", setting: "auto", offered: []string{compressionGzip, compressionZstd}, want: compressionGzip},
+		{name: "unset is auto", setting: "", offered: []string{compressionZstd}, want: compressionZstd},
+		{name: "unknown codec skipped", setting: "auto", offered: []string{"brotli", compressionGzip}, want: compressionGzip},
+		{name: "only unknown codecs", setting: "auto", offered: []string{"brotli"}, want: ""},
+		{name: "older sender offers nothing", setting: "auto", offered: nil, want: ""},
+		{name: "receiver limited to gzip", setting: compressionGzip, offered: []string{compressionZstd, compressionGzip}, want: compressionGzip},
+		{name: "receiver limited to zstd", setting: compressionZstd, offered: []string{compressionGzip}, want: ""},
+		{name: "receiver off", setting: "off", offered: []string{compressionZstd, compressionGzip}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.Compression = tt.setting })
+
+			if got := chooseCompression(tt.offered); got != tt.want {
+				t.Fatalf("chooseCompression(%q) with %q = %q, want %q", tt.offered, tt.setting, got, tt.want)
+			}
+		})
+	}
+}
+
+// zstdStream compresses data as a stream, whose frame does not declare how
+// large it expands to, with a window the decoder accepts
+func zstdStream(t *testing.T, data []byte) []byte {
+	var buffer bytes.Buffer
+	writer, err := zstd.NewWriter(&buffer, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithWindowSize(maxChunkSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// gzipData compresses data with gzip
+func gzipData(t *testing.T, data []byte) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestDecompressChunk(t *testing.T) {
+	full := bytes.Repeat([]byte("sync "), maxChunkSize/5)
+	full = append(full, make([]byte, maxChunkSize-len(full))...)
+	over := make([]byte, maxChunkSize+1)
+	bomb := make([]byte, 64*maxChunkSize)
+
+	tests := []struct {
+		name    string
+		codec   string
+		packed  func(t *testing.T, data []byte) []byte
+		data    []byte
+		wantErr bool
+	}{
+		{name: "zstd largest chunk", codec: compressionZstd, data: full},
+		{name: "zstd one byte over", codec: compressionZstd, data: over, wantErr: true},
+		{name: "zstd bomb", codec: compressionZstd, data: bomb, wantErr: true},
+		{name: "zstd stream largest chunk", codec: compressionZstd, packed: zstdStream, data: full},
+		{name: "zstd stream bomb", codec: compressionZstd, packed: zstdStream, data: bomb, wantErr: true},
+		{name: "gzip largest chunk", codec: compressionGzip, data: full},
+		{name: "gzip one byte over", codec: compressionGzip, data: over, wantErr: true},
+		{name: "gzip bomb", codec: compressionGzip, packed: gzipData, data: bomb, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var packed []byte
+			if tt.packed != nil {
+				packed = tt.packed(t, tt.data)
+			} else {
+				packed = compressChunk(tt.codec, tt.data, nil)
+			}
+			if packed == nil || len(packed) >= len(tt.data) {
+				t.Fatalf("%d bytes did not compress", len(tt.data))
+			}
+
+			got, err := decompressChunk(tt.codec, packed, make([]byte, maxChunkSize))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("%d compressed bytes expanded to %d, want error", len(packed), len(got))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("decompressChunk: %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Fatalf("decompressed %d bytes that differ from the %d compressed", len(got), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestInflateChunk(t *testing.T) {
+	data := bytes.Repeat([]byte("chunk data "), 1000)
+	bomb := compressChunk(compressionZstd, make([]byte, 64*maxChunkSize), nil)
+
+	tests := []struct {
+		name     string
+		chunk    *pb.FileChunk
+		data     []byte
+		want     []byte
+		wantCode codes.Code
+	}{
+		{name: "uncompressed", chunk: &pb.FileChunk{}, data: data, want: data},
+		{name: "zstd", chunk: &pb.FileChunk{Compression: compressionZstd}, data: compressChunk(compressionZstd, data, nil), want: data},
+		{name: "gzip", chunk: &pb.FileChunk{Compression: compressionGzip}, data: compressChunk(compressionGzip, data, nil), want: data},
+		{name: "unsupported codec", chunk: &pb.FileChunk{Compression: "brotli"}, data: data, wantCode: codes.InvalidArgument},
+		{name: "corrupt", chunk: &pb.FileChunk{Compression: compressionGzip}, data: data, wantCode: codes.DataLoss},
+		{name: "bomb", chunk: &pb.FileChunk{Compression: compressionZstd}, data: bomb, wantCode: codes.DataLoss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inflateChunk(tt.chunk, tt.data, nil)
+			if tt.wantCode != codes.OK {
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("inflateChunk error = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("inflateChunk: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("inflated %d bytes that differ from the %d sent", len(got), len(tt.want))
+			}
+		})
+	}
+}
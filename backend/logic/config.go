@@ -0,0 +1,156 @@
+package logic
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type Config struct {
+	// Address the REST API listens on. Other hosts need the API token, so
+	// widening it beyond loopback is only for remote control.
+	APIAddress string `json:"api_address"`
+
+	// Keep partial data when a sender cancels so a later send can resume
+	KeepPartialOnCancel bool `json:"keep_partial_on_cancel"`
+
+	// Abort a transfer when no bytes move for this many seconds
+	StallTimeoutSeconds int `json:"stall_timeout_seconds"`
+
+	// Optional cap on a transfer's total duration; 0 disables it
+	MaxTransferSeconds int `json:"max_transfer_seconds"`
+
+	// Drop setuid, setgid and sticky bits from received files
+	StripSpecialModeBits bool `json:"strip_special_mode_bits"`
+
+	// Directory paired peers may browse and download from
+	SharedDir string `json:"shared_dir"`
+
+	// Files dropped in <outbox_dir>/<peer>/ are sent to that peer once no
+	// writes have touched them for outbox_stable_seconds
+	OutboxDir           string `json:"outbox_dir"`
+	OutboxStableSeconds int    `json:"outbox_stable_seconds"`
+
+	// Directory every sync folder must be inside
+	SyncBaseDir string `json:"sync_base_dir"`
+
+	// Fixed chunk size for outgoing streams; 0 adapts it to the link
+	ChunkSizeKB int `json:"chunk_size_kb"`
+
+	// Chunk compression codecs to negotiate: "auto" for zstd or gzip,
+	// "zstd" or "gzip" for only that one, "off" to send data as it is
+	Compression string `json:"compression"`
+
+	// What a received file does when its name is taken in the downloads
+	// directory: "rename" to "name (1).ext", "overwrite", "skip" to keep
+	// the existing file, or "version" to move the existing file to "name.~1~"
+	CollisionPolicy string `json:"collision_policy"`
+
+	// Upload and download rate limits, changeable at runtime through /api/limits
+	RateLimits RateLimits `json:"rate_limits"`
+}
+
+var (
+	config      = defaultConfig()
+	configMutex sync.RWMutex
+)
+
+const configFile = "config.json"
+
+// defaultConfig returns the settings used when config.json is missing a value
+func defaultConfig() Config {
+	return Config{
+		APIAddress: "127.0.0.1:80",
+
+		KeepPartialOnCancel: false,
+		StallTimeoutSeconds: 60,
+		MaxTransferSeconds:  0,
+
+		StripSpecialModeBits: true,
+
+		SharedDir: "./shared",
+
+		OutboxDir:           "~/outbox",
+		OutboxStableSeconds: 5,
+
+		SyncBaseDir: "~/Sync",
+
+		Compression:     "auto",
+		CollisionPolicy: collisionRename,
+	}
+}
+
+// InitConfig loads config.json, creating it with defaults if it doesn't exist
+func InitConfig() {
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		log.Println("config.json not found, creating with defaults...")
+		saveConfigToFile()
+		return
+	}
+
+	file, err := os.Open(configFile)
+	if err != nil {
+		log.Printf("Error opening config.json, using defaults: %v", err)
+		return
+	}
+	defer file.Close()
+
+	// Decode over the defaults so settings missing from the file keep them
+	loaded := defaultConfig()
+	if err := json.NewDecoder(file).Decode(&loaded); err != nil {
+		log.Printf("Error decoding config.json, using defaults: %v", err)
+		return
+	}
+
+	configMutex.Lock()
+	config = loaded
+	configMutex.Unlock()
+
+	log.Println("Configuration loaded from config.json")
+}
+
+// saveConfigToFile saves the current configuration to config.json
+func saveConfigToFile() {
+	configMutex.RLock()
+	current := config
+	configMutex.RUnlock()
+
+	file, err := os.Create(configFile)
+	if err != nil {
+		log.Printf("Error creating config.json: %v", err)
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "    ") // Pretty print JSON
+
+	if err := encoder.Encode(current); err != nil {
+		log.Printf("Error encoding config.json: %v", err)
+		return
+	}
+}
+
+// expandHome returns dir as an absolute path, with a leading ~ expanded to
+// the user's home directory
+func expandHome(dir string) (string, error) {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	return filepath.Abs(dir)
+}
+
+// GetConfig returns the current configuration
+func GetConfig() Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return config
+}
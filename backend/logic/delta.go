@@ -0,0 +1,446 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Delta transfers work like rsync: the receiver describes the blocks of the
+// copy it already holds with a weak rolling checksum and a strong one, and
+// the sender finds those blocks anywhere in the new file, sending only the
+// data in between plus references to the blocks.
+
+const (
+	// Bounds on the block size of a basis file
+	minDeltaBlockSize = 2 * 1024
+	maxDeltaBlockSize = 128 * 1024
+
+	// Files smaller than this are always sent whole
+	minDeltaFileSize = 256 * 1024
+
+	// Block signatures per message
+	blockSignatureBatch = 4096
+
+	// Bytes of a block's SHA-256 used as its strong checksum
+	strongChecksumSize = 16
+
+	// Bytes copied from the basis file per read
+	basisCopySize = 1024 * 1024
+
+	// Bytes of the basis file one block reference copies at most, so the
+	// receiver reports progress through a long unchanged stretch
+	maxDeltaRunSize = 4 * 1024 * 1024
+)
+
+// blockSignatures describes the blocks of the receiver's existing copy. Its
+// size and mtime go back with the delta so the receiver can tell the copy
+// has not changed since.
+type blockSignatures struct {
+	blockSize  int64
+	basisSize  int64
+	basisMtime int64              // Unix nanoseconds
+	weak       map[uint32][]int64 // block indexes by weak checksum
+	strong     [][]byte
+}
+
+// rollingChecksum is rsync's weak checksum, which can slide along a file a
+// byte at a time
+type rollingChecksum struct {
+	a, b uint32
+	n    uint32
+}
+
+// deltaBlockSize picks a block size near the square root of a basis file's
+// size, as rsync does, rounded down to a whole KB
+func deltaBlockSize(size int64) int64 {
+	blockSize := int64(math.Sqrt(float64(size))) &^ 1023
+	return min(max(blockSize, minDeltaBlockSize), maxDeltaBlockSize)
+}
+
+// newRollingChecksum computes the weak checksum of a window
+func newRollingChecksum(window []byte) rollingChecksum {
+	r := rollingChecksum{n: uint32(len(window))}
+	for i, c := range window {
+		r.a += uint32(c)
+		r.b += (r.n - uint32(i)) * uint32(c)
+	}
+	return r
+}
+
+// sum returns the checksum of the current window
+func (r *rollingChecksum) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+// roll slides the window one byte, dropping out and taking in
+func (r *rollingChecksum) roll(out, in byte) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - r.n*uint32(out)
+}
+
+// shrink drops the first byte of the window, for the tail of a file
+func (r *rollingChecksum) shrink(out byte) {
+	r.a -= uint32(out)
+	r.b -= r.n * uint32(out)
+	r.n--
+}
+
+// strongChecksum returns the strong checksum of a block
+func strongChecksum(block []byte) []byte {
+	sum := sha256.Sum256(block)
+	return sum[:strongChecksumSize]
+}
+
+// openBasisFile opens the receiver's existing copy of a file, which must be
+// a regular file rather than a link to one
+func openBasisFile(basisPath string) (*os.File, fs.FileInfo, error) {
+	info, err := os.Lstat(basisPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, fmt.Errorf("%s is not a regular file", basisPath)
+	}
+
+	file, err := os.Open(basisPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// GetBlockSignatures describes the blocks of the copy of an offered file the
+// receiver already holds. No signatures are sent when there is no copy.
+func (s *fileTransferServer) GetBlockSignatures(req *pb.BlockSignatureRequest, stream pb.FileTransferService_GetBlockSignaturesServer) error {
+	fileName, err := sanitizeRelativePath(req.FileName)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Only describe files the user agreed to receive
+	if !isOfferAccepted(stream.Context(), req.OfferId, OfferedFile{Path: fileName, Size: req.FileSize}) {
+		return status.Error(codes.PermissionDenied, "transfer was not accepted by the receiver")
+	}
+
+	basisPath, err := receivedPath(fileName)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	file, info, err := openBasisFile(basisPath)
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+	defer file.Close()
+
+	blockSize := deltaBlockSize(info.Size())
+	buffer := make([]byte, blockSize)
+	newBatch := func() *pb.BlockSignatures {
+		return &pb.BlockSignatures{BlockSize: blockSize, BasisSize: info.Size(), BasisMtime: info.ModTime().UnixNano()}
+	}
+	batch := newBatch()
+
+	for {
+		n, err := io.ReadFull(file, buffer)
+		if n > 0 {
+			block := buffer[:n]
+			checksum := newRollingChecksum(block)
+			batch.Weak = append(batch.Weak, checksum.sum())
+			batch.Strong = append(batch.Strong, strongChecksum(block))
+		}
+
+		if len(batch.Weak) == blockSignatureBatch {
+			if err := stream.Send(batch); err != nil {
+				return err
+			}
+			batch = newBatch()
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read %s: %v", fileName, err)
+		}
+	}
+
+	if len(batch.Weak) > 0 {
+		return stream.Send(batch)
+	}
+	return nil
+}
+
+// fetchBlockSignatures asks the receiver to describe its copy of a file,
+// returning nil if it has none
+func fetchBlockSignatures(ctx context.Context, client pb.FileTransferServiceClient, offerID, fileName string, fileSize int64) (*blockSignatures, error) {
+	stream, err := client.GetBlockSignatures(ctx, &pb.BlockSignatureRequest{
+		OfferId:  offerID,
+		FileName: fileName,
+		FileSize: fileSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sigs *blockSignatures
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if sigs == nil {
+			if batch.BlockSize < minDeltaBlockSize || batch.BlockSize > maxDeltaBlockSize || batch.BasisSize <= 0 {
+				return nil, errors.New("invalid block signatures")
+			}
+			sigs = &blockSignatures{
+				blockSize:  batch.BlockSize,
+				basisSize:  batch.BasisSize,
+				basisMtime: batch.BasisMtime,
+				weak:       make(map[uint32][]int64),
+			}
+		}
+
+		if batch.BlockSize != sigs.blockSize || batch.BasisSize != sigs.basisSize || batch.BasisMtime != sigs.basisMtime || len(batch.Weak) != len(batch.Strong) {
+			return nil, errors.New("invalid block signatures")
+		}
+
+		for i, weak := range batch.Weak {
+			if len(batch.Strong[i]) != strongChecksumSize {
+				return nil, errors.New("invalid block signatures")
+			}
+			index := int64(len(sigs.strong))
+			sigs.weak[weak] = append(sigs.weak[weak], index)
+			sigs.strong = append(sigs.strong, batch.Strong[i])
+		}
+	}
+
+	if sigs != nil && int64(len(sigs.strong)) != (sigs.basisSize+sigs.blockSize-1)/sigs.blockSize {
+		return nil, errors.New("block signatures do not cover the basis file")
+	}
+	return sigs, nil
+}
+
+// deltaChunk starts a chunk of a delta against the receiver's copy, with a
+// run of its blocks to copy or nil for literal data
+func (s *blockSignatures) deltaChunk(run *pb.BlockCopy) *pb.FileChunk {
+	return &pb.FileChunk{
+		DeltaBlockSize: s.blockSize,
+		BasisSize:      s.basisSize,
+		BasisMtime:     s.basisMtime,
+		Copy:           run,
+	}
+}
+
+// runLength returns the number of bytes in count blocks starting at index;
+// the last block of the basis file may be short
+func (s *blockSignatures) runLength(index, count int64) int64 {
+	return min(count*s.blockSize, s.basisSize-index*s.blockSize)
+}
+
+// match returns a block of the basis file with the same contents as window
+func (s *blockSignatures) match(weak uint32, window []byte) (int64, bool) {
+	candidates := s.weak[weak]
+	if len(candidates) == 0 {
+		return 0, false
+	}
+
+	strong := strongChecksum(window)
+	for _, index := range candidates {
+		if s.runLength(index, 1) == int64(len(window)) && bytes.Equal(s.strong[index], strong) {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// writeDelta reads a new version of a file and describes it against the
+// receiver's blocks: literal data, at most literalSize bytes at a time, and
+// runs of consecutive blocks the receiver copies from its own file, at most
+// maxDeltaRunSize bytes at a time
+func writeDelta(r io.Reader, sigs *blockSignatures, literalSize int, literal func([]byte) error, copyBlocks func(index, count int64) error) error {
+	reader := bufio.NewReaderSize(r, basisCopySize)
+	blockSize := int(sigs.blockSize)
+
+	// buf[:start] is literal data not yet sent; buf[start:] is the window
+	buf := make([]byte, 0, literalSize+blockSize)
+	start := 0
+
+	maxRun := max(1, maxDeltaRunSize/sigs.blockSize)
+	var runIndex, runCount int64
+	flushRun := func() error {
+		if runCount == 0 {
+			return nil
+		}
+		err := copyBlocks(runIndex, runCount)
+		runCount = 0
+		return err
+	}
+
+	flushLiteral := func() error {
+		if start == 0 {
+			return nil
+		}
+		if err := flushRun(); err != nil {
+			return err
+		}
+		if err := literal(buf[:start]); err != nil {
+			return err
+		}
+		buf = append(buf[:0], buf[start:]...)
+		start = 0
+		return nil
+	}
+
+	fillWindow := func() error {
+		for len(buf)-start < blockSize {
+			c, err := reader.ReadByte()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			buf = append(buf, c)
+		}
+		return nil
+	}
+
+	if err := fillWindow(); err != nil {
+		return err
+	}
+	checksum := newRollingChecksum(buf)
+
+	for len(buf) > start {
+		if index, ok := sigs.match(checksum.sum(), buf[start:]); ok {
+			if err := flushLiteral(); err != nil {
+				return err
+			}
+			if runCount > 0 && runCount < maxRun && runIndex+runCount == index {
+				runCount++
+			} else {
+				if err := flushRun(); err != nil {
+					return err
+				}
+				runIndex, runCount = index, 1
+			}
+
+			buf = buf[:0]
+			if err := fillWindow(); err != nil {
+				return err
+			}
+			checksum = newRollingChecksum(buf)
+			continue
+		}
+
+		// No block starts here, so this byte is literal data
+		out := buf[start]
+		start++
+
+		c, err := reader.ReadByte()
+		switch {
+		case err == nil:
+			buf = append(buf, c)
+			checksum.roll(out, c)
+		case err == io.EOF:
+			checksum.shrink(out)
+		default:
+			return err
+		}
+
+		if start >= literalSize {
+			if err := flushLiteral(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flushLiteral(); err != nil {
+		return err
+	}
+	return flushRun()
+}
+
+// openBasis opens the receiver's existing copy of the file for the delta
+// stream starting with chunk, which must still be the copy the sender was
+// given signatures for: the same size and mtime it echoes back
+func (r *fileReceiver) openBasis(chunk *pb.FileChunk) error {
+	basisPath, err := receivedPath(r.state.FileName)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	file, info, err := openBasisFile(basisPath)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, "basis file for delta transfer is gone")
+	}
+	if info.Size() != chunk.BasisSize || info.ModTime().UnixNano() != chunk.BasisMtime || deltaBlockSize(info.Size()) != chunk.DeltaBlockSize {
+		file.Close()
+		return status.Error(codes.FailedPrecondition, "basis file for delta transfer has changed")
+	}
+
+	r.basis = file
+	r.basisSize = info.Size()
+	r.blockSize = chunk.DeltaBlockSize
+	return nil
+}
+
+// copyBlocks appends a run of blocks from the basis file to the partial
+// file, returning the number of bytes copied
+func (r *fileReceiver) copyBlocks(run *pb.BlockCopy) (int64, error) {
+	if r.basis == nil {
+		return 0, status.Error(codes.InvalidArgument, "block reference without a basis file")
+	}
+
+	start := run.Index * r.blockSize
+	if run.Index < 0 || run.Count <= 0 || start >= r.basisSize || run.Count > r.basisSize/r.blockSize+1 {
+		return 0, status.Errorf(codes.InvalidArgument, "block run %d+%d is outside the basis file", run.Index, run.Count)
+	}
+	length := min(run.Count*r.blockSize, r.basisSize-start)
+
+	if r.copyBuffer == nil {
+		r.copyBuffer = make([]byte, basisCopySize)
+	}
+
+	for copied := int64(0); copied < length; {
+		n := min(int64(len(r.copyBuffer)), length-copied)
+		block := r.copyBuffer[:n]
+
+		if _, err := r.basis.ReadAt(block, start+copied); err != nil {
+			return copied, status.Errorf(codes.Internal, "failed to read basis file: %v", err)
+		}
+		if _, err := r.file.Write(block); err != nil {
+			return copied, status.Errorf(codes.Internal, "failed to write file: %v", err)
+		}
+
+		r.state.hash.Write(block)
+		copied += n
+	}
+
+	r.bytesSaved += length
+	return length, nil
+}
+
+// closeBasis releases the basis file, which must happen before the new
+// version replaces it
+func (r *fileReceiver) closeBasis() {
+	if r.basis != nil {
+		r.basis.Close()
+		r.basis = nil
+	}
+}
@@ -0,0 +1,181 @@
+package logic
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// signaturesOf describes basis the way GetBlockSignatures does
+func signaturesOf(basis []byte) *blockSignatures {
+	blockSize := deltaBlockSize(int64(len(basis)))
+	sigs := &blockSignatures{
+		blockSize: blockSize,
+		basisSize: int64(len(basis)),
+		weak:      make(map[uint32][]int64),
+	}
+
+	for start := int64(0); start < sigs.basisSize; start += blockSize {
+		block := basis[start:min(start+blockSize, sigs.basisSize)]
+		checksum := newRollingChecksum(block)
+		index := int64(len(sigs.strong))
+		sigs.weak[checksum.sum()] = append(sigs.weak[checksum.sum()], index)
+		sigs.strong = append(sigs.strong, strongChecksum(block))
+	}
+	return sigs
+}
+
+// seededBytes returns n reproducible random bytes
+func seededBytes(seed uint64, n int) []byte {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(rng.Uint32())
+	}
+	return data
+}
+
+// concatBytes concatenates byte slices into a new one
+func concatBytes(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestWriteDelta(t *testing.T) {
+	const literalSize = 64 * 1024
+	basis := seededBytes(1, 1024*1024)
+	small := seededBytes(2, 1000)
+	insert := seededBytes(3, 100)
+
+	tests := []struct {
+		name       string
+		basis      []byte
+		target     []byte
+		maxLiteral int // literal bytes the delta may send
+	}{
+		{name: "identical", basis: basis, target: basis, maxLiteral: 0},
+		{name: "insert", basis: basis, target: concatBytes(basis[:300000], insert, basis[300000:]), maxLiteral: 100 + 2*2048},
+		{name: "delete", basis: basis, target: concatBytes(basis[:300000], basis[310000:]), maxLiteral: 2 * 2048},
+		{name: "append", basis: basis, target: concatBytes(basis, insert), maxLiteral: 100},
+		{name: "prepend", basis: basis, target: concatBytes(insert, basis), maxLiteral: 100},
+		{name: "truncate", basis: basis, target: basis[:500000], maxLiteral: 2048},
+		{name: "unrelated", basis: basis, target: seededBytes(4, 200000), maxLiteral: 200000},
+		{name: "empty target", basis: basis, target: nil, maxLiteral: 0},
+		{name: "basis smaller than one block", basis: small, target: small, maxLiteral: 0},
+		{name: "basis smaller than one block, prefixed", basis: small, target: concatBytes(insert, small), maxLiteral: 100},
+		{name: "basis smaller than one block, edited", basis: small, target: concatBytes(small[:500], insert, small[500:]), maxLiteral: 1100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sigs := signaturesOf(tt.basis)
+
+			var rebuilt bytes.Buffer
+			literalBytes := 0
+			literal := func(data []byte) error {
+				if len(data) > literalSize {
+					t.Fatalf("literal of %d bytes, want at most %d", len(data), literalSize)
+				}
+				literalBytes += len(data)
+				rebuilt.Write(data)
+				return nil
+			}
+			copyBlocks := func(index, count int64) error {
+				if index < 0 || count <= 0 || index+count > int64(len(sigs.strong)) {
+					t.Fatalf("block run %d+%d is outside the %d basis blocks", index, count, len(sigs.strong))
+				}
+				start := index * sigs.blockSize
+				rebuilt.Write(tt.basis[start : start+sigs.runLength(index, count)])
+				return nil
+			}
+
+			if err := writeDelta(bytes.NewReader(tt.target), sigs, literalSize, literal, copyBlocks); err != nil {
+				t.Fatalf("writeDelta: %v", err)
+			}
+
+			if !bytes.Equal(rebuilt.Bytes(), tt.target) {
+				t.Fatalf("rebuilt %d bytes that differ from the %d byte target", rebuilt.Len(), len(tt.target))
+			}
+			if literalBytes > tt.maxLiteral {
+				t.Fatalf("sent %d literal bytes, want at most %d", literalBytes, tt.maxLiteral)
+			}
+		})
+	}
+}
+
+func TestWriteDeltaCapsRuns(t *testing.T) {
+	basis := seededBytes(5, 3*maxDeltaRunSize+12345)
+	sigs := signaturesOf(basis)
+	maxRun := maxDeltaRunSize / sigs.blockSize
+
+	var rebuilt bytes.Buffer
+	literal := func(data []byte) error {
+		t.Fatalf("unexpected literal of %d bytes", len(data))
+		return nil
+	}
+	copyBlocks := func(index, count int64) error {
+		if count > maxRun {
+			t.Fatalf("run of %d blocks, want at most %d", count, maxRun)
+		}
+		start := index * sigs.blockSize
+		rebuilt.Write(basis[start : start+sigs.runLength(index, count)])
+		return nil
+	}
+
+	if err := writeDelta(bytes.NewReader(basis), sigs, initialChunkSize, literal, copyBlocks); err != nil {
+		t.Fatalf("writeDelta: %v", err)
+	}
+	if !bytes.Equal(rebuilt.Bytes(), basis) {
+		t.Fatal("rebuilt file differs from the basis")
+	}
+}
+
+func TestOpenBasisChecksCopy(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	const size = 300 * 1024
+	mtime := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	basisPath := filepath.Join(downloadsDir, "report.bin")
+	if err := os.MkdirAll(downloadsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		change   func() error // what happens to the copy after signing
+		wantCode codes.Code
+	}{
+		{name: "unchanged", change: func() error { return nil }},
+		{name: "grown", change: func() error { return os.WriteFile(basisPath, make([]byte, size+1), 0644) }, wantCode: codes.FailedPrecondition},
+		{name: "rewritten in place", change: func() error { return os.Chtimes(basisPath, mtime, mtime.Add(time.Second)) }, wantCode: codes.FailedPrecondition},
+		{name: "removed", change: func() error { return os.Remove(basisPath) }, wantCode: codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(basisPath, make([]byte, size), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(basisPath, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			sigs := &blockSignatures{blockSize: deltaBlockSize(size), basisSize: size, basisMtime: mtime.UnixNano()}
+
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+
+			receiver := &fileReceiver{state: &partialState{FileName: "report.bin"}}
+			err := receiver.openBasis(sigs.deltaChunk(nil))
+			receiver.closeBasis()
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("openBasis error = %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package logic
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain separation strings for end-to-end encryption
+const (
+	e2eStaticKeyInfo  = "filetransfer x25519 static key v1"
+	e2eKeySignContext = "filetransfer x25519 key signature v1"
+	e2eHeaderContext  = "filetransfer e2e header v1"
+	e2eChunkKeyInfo   = "filetransfer e2e chunk key v1"
+)
+
+// This node's X25519 key for end-to-end encryption, derived from the
+// identity key so it needs no file of its own
+var encryptionKey *ecdh.PrivateKey
+
+// chunkCipher seals or opens the chunks of one stream. Every stream uses a
+// fresh ephemeral key, so chunk numbers never repeat a nonce under a key.
+type chunkCipher struct {
+	aead cipher.AEAD
+}
+
+// deriveEncryptionKey derives the static X25519 key from the identity key
+func deriveEncryptionKey(identity ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	seed, err := hkdf.Key(sha256.New, identity.Seed(), nil, e2eStaticKeyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(seed)
+}
+
+// GetEncryptionKey returns this node's X25519 public key, signed with the
+// identity key so senders can check it even when it reaches them through
+// another node
+func (s *fileTransferServer) GetEncryptionKey(ctx context.Context, req *pb.EncryptionKeyRequest) (*pb.EncryptionKey, error) {
+	publicKey := encryptionKey.PublicKey().Bytes()
+
+	return &pb.EncryptionKey{
+		PublicKey: publicKey,
+		Signature: ed25519.Sign(identityKey, append([]byte(e2eKeySignContext), publicKey...)),
+	}, nil
+}
+
+// fetchRecipientKey asks a paired peer for its encryption key and checks the
+// signature against the identity key recorded when pairing
+func fetchRecipientKey(ctx context.Context, client pb.FileTransferServiceClient, peerID string) (*ecdh.PublicKey, error) {
+	identity, ok := trustedIdentityKey(peerID)
+	if !ok {
+		return nil, fmt.Errorf("peer %s is not paired", peerID)
+	}
+
+	response, err := client.GetEncryptionKey(ctx, &pb.EncryptionKeyRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %w", err)
+	}
+
+	signed := append([]byte(e2eKeySignContext), response.PublicKey...)
+	if !ed25519.Verify(identity, signed, response.Signature) {
+		return nil, errors.New("encryption key is not signed by the peer's identity key")
+	}
+
+	return ecdh.X25519().NewPublicKey(response.PublicKey)
+}
+
+// newSendCipher starts an encrypted stream to the holder of recipientKey,
+// returning the header the receiver needs to derive the same key
+func newSendCipher(recipientKey *ecdh.PublicKey, recipientID, transferID, offerID string) (*chunkCipher, *pb.EncryptionHeader, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shared, err := ephemeral.ECDH(recipientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	senderID := GetSystemInfoStruct().PeerID
+	header := &pb.EncryptionHeader{
+		EphemeralKey: ephemeral.PublicKey().Bytes(),
+		SenderPeerId: senderID,
+	}
+	header.Signature = ed25519.Sign(identityKey, headerSigningBytes(header, recipientID, transferID, offerID))
+
+	c, err := newChunkCipher(shared, header.EphemeralKey, recipientKey.Bytes(), transferID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, header, nil
+}
+
+// newReceiveCipher checks the header of an encrypted stream, which must be
+// signed by a paired sender, and derives the stream's key
+func newReceiveCipher(header *pb.EncryptionHeader, transferID, offerID string) (*chunkCipher, error) {
+	identity, ok := trustedIdentityKey(header.SenderPeerId)
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "encrypted stream from an unpaired sender")
+	}
+
+	recipientID := GetSystemInfoStruct().PeerID
+	if !ed25519.Verify(identity, headerSigningBytes(header, recipientID, transferID, offerID), header.Signature) {
+		return nil, status.Error(codes.PermissionDenied, "encryption header is not signed by the sender")
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(header.EphemeralKey)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ephemeral key")
+	}
+
+	shared, err := encryptionKey.ECDH(ephemeral)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ephemeral key")
+	}
+
+	c, err := newChunkCipher(shared, header.EphemeralKey, encryptionKey.PublicKey().Bytes(), transferID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to set up decryption: %v", err)
+	}
+	return c, nil
+}
+
+// headerSigningBytes is what the sender signs: the ephemeral key bound to
+// both peers and to the transfer, so a header can't be moved to another one
+func headerSigningBytes(header *pb.EncryptionHeader, recipientID, transferID, offerID string) []byte {
+	var b []byte
+	b = appendField(b, []byte(e2eHeaderContext))
+	b = appendField(b, header.EphemeralKey)
+	b = appendField(b, []byte(header.SenderPeerId))
+	b = appendField(b, []byte(recipientID))
+	b = appendField(b, []byte(transferID))
+	b = appendField(b, []byte(offerID))
+	return b
+}
+
+// newChunkCipher derives an AES-256-GCM key from an X25519 shared secret
+func newChunkCipher(shared, ephemeralKey, recipientKey []byte, transferID string) (*chunkCipher, error) {
+	salt := append(append([]byte{}, ephemeralKey...), recipientKey...)
+
+	key, err := hkdf.Key(sha256.New, shared, salt, e2eChunkKeyInfo+" "+transferID, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunkCipher{aead: aead}, nil
+}
+
+// seal encrypts a chunk's data in place of the plaintext, writing into
+// dst's storage when it has room
+func (c *chunkCipher) seal(chunk *pb.FileChunk, plaintext, dst []byte) {
+	chunk.Data = c.aead.Seal(dst[:0], chunkNonce(chunk), plaintext, chunkAAD(chunk))
+}
+
+// open decrypts a chunk's data, failing if it was altered, reordered or
+// replayed from elsewhere in the stream
+func (c *chunkCipher) open(chunk *pb.FileChunk) ([]byte, error) {
+	return c.aead.Open(nil, chunkNonce(chunk), chunk.Data, chunkAAD(chunk))
+}
+
+// chunkNonce binds the chunk number into the nonce. The trailer reuses the
+// last data chunk's number, so it gets its own flag byte.
+func chunkNonce(chunk *pb.FileChunk) []byte {
+	nonce := make([]byte, 12)
+	if chunk.Sha256 != "" {
+		nonce[0] = 1
+	}
+	binary.BigEndian.PutUint64(nonce[4:], uint64(chunk.ChunkNumber))
+	return nonce
+}
+
+// chunkAAD authenticates the chunk's header fields along with its data. The
+// offset ties each chunk to its place in the file.
+func chunkAAD(chunk *pb.FileChunk) []byte {
+	var b []byte
+	b = appendField(b, []byte(chunk.TransferId))
+	b = appendField(b, []byte(chunk.OfferId))
+	b = appendField(b, []byte(chunk.FileName))
+	b = binary.BigEndian.AppendUint64(b, uint64(chunk.ChunkNumber))
+	b = binary.BigEndian.AppendUint64(b, uint64(chunk.Offset))
+	b = binary.BigEndian.AppendUint64(b, uint64(chunk.FileSize))
+	b = appendField(b, []byte(chunk.Sha256))
+	b = binary.BigEndian.AppendUint32(b, chunk.Mode)
+	b = binary.BigEndian.AppendUint64(b, uint64(chunk.Mtime))
+	b = binary.BigEndian.AppendUint64(b, uint64(chunk.DeltaBlockSize))
+	b = binary.BigEndian.AppendUint64(b, uint64(chunk.Copy.GetIndex()))
+	b = binary.BigEndian.AppendUint64(b, uint64(chunk.Copy.GetCount()))
+	b = appendField(b, []byte(chunk.Compression))
+	return b
+}
+
+// appendField appends a length-prefixed field
+func appendField(b, field []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(field)))
+	return append(b, field...)
+}
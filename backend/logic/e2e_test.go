@@ -0,0 +1,144 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEncryptionHeaderBinding(t *testing.T) {
+	// This node sends to itself, as a paired peer
+	testPeer(t)
+	selfID := systemInfo.PeerID
+	recipientKey := encryptionKey.PublicKey()
+	_, strangerID := newPeerKey(t)
+
+	otherEphemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		recipientID string // who the sender meant the stream for
+		transferID  string // transfer and offer the stream arrives on
+		offerID     string
+		tamper      func(*pb.EncryptionHeader)
+		wantCode    codes.Code
+	}{
+		{name: "valid", recipientID: selfID, transferID: "transfer-1", offerID: "offer-1"},
+		{name: "moved to another transfer", recipientID: selfID, transferID: "transfer-2", offerID: "offer-1", wantCode: codes.PermissionDenied},
+		{name: "moved to another offer", recipientID: selfID, transferID: "transfer-1", offerID: "offer-2", wantCode: codes.PermissionDenied},
+		{name: "meant for another recipient", recipientID: strangerID, transferID: "transfer-1", offerID: "offer-1", wantCode: codes.PermissionDenied},
+		{
+			name: "ephemeral key swapped", recipientID: selfID, transferID: "transfer-1", offerID: "offer-1",
+			tamper:   func(h *pb.EncryptionHeader) { h.EphemeralKey = otherEphemeral.PublicKey().Bytes() },
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name: "unpaired sender", recipientID: selfID, transferID: "transfer-1", offerID: "offer-1",
+			tamper:   func(h *pb.EncryptionHeader) { h.SenderPeerId = strangerID },
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name: "unsigned", recipientID: selfID, transferID: "transfer-1", offerID: "offer-1",
+			tamper:   func(h *pb.EncryptionHeader) { h.Signature = nil },
+			wantCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealer, header, err := newSendCipher(recipientKey, tt.recipientID, "transfer-1", "offer-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(header)
+			}
+
+			opener, err := newReceiveCipher(header, tt.transferID, tt.offerID)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("newReceiveCipher error = %v, want %s", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			chunk := &pb.FileChunk{TransferId: "transfer-1", OfferId: "offer-1", FileName: "notes.txt"}
+			sealer.seal(chunk, []byte("secret"), nil)
+			if bytes.Contains(chunk.Data, []byte("secret")) {
+				t.Fatal("sealed chunk carries the plaintext")
+			}
+			if data, err := opener.open(chunk); err != nil || string(data) != "secret" {
+				t.Fatalf("open = %q, %v, want %q", data, err, "secret")
+			}
+		})
+	}
+}
+
+func TestChunkCipherRejectsAlteredChunks(t *testing.T) {
+	shared := make([]byte, 32)
+	c, err := newChunkCipher(shared, []byte("ephemeral"), []byte("recipient"), "transfer-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		alter   func(*pb.FileChunk)
+		wantErr bool
+	}{
+		{name: "unaltered", alter: func(*pb.FileChunk) {}},
+		{name: "data flipped", alter: func(c *pb.FileChunk) { c.Data[0] ^= 1 }, wantErr: true},
+		{name: "moved to another offset", alter: func(c *pb.FileChunk) { c.Offset += 1024 }, wantErr: true},
+		{name: "reordered", alter: func(c *pb.FileChunk) { c.ChunkNumber++ }, wantErr: true},
+		{name: "another file", alter: func(c *pb.FileChunk) { c.FileName = "other.txt" }, wantErr: true},
+		{name: "replayed as the trailer", alter: func(c *pb.FileChunk) { c.Sha256 = "digest" }, wantErr: true},
+		{name: "mode changed", alter: func(c *pb.FileChunk) { c.Mode = 0o4755 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := &pb.FileChunk{TransferId: "transfer-1", FileName: "notes.txt", ChunkNumber: 3, Offset: 2048, FileSize: 4096, Mode: 0o644}
+			c.seal(chunk, []byte("chunk data"), nil)
+			tt.alter(chunk)
+
+			_, err := c.open(chunk)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("open error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendEncrypted(t *testing.T) {
+	peer := testPeer(t)
+
+	content := make([]byte, 200*1024+100) // several chunks and a short one
+	rand.Read(content)
+	if err := os.WriteFile("secret.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := newTransfer(peer, []string{"secret.bin"}, transferOptions{encrypt: true})
+	runTransfer(transfer, peer)
+	if transfer.State != TransferSucceeded {
+		t.Fatalf("transfer %s: %s", transfer.State, transfer.Error)
+	}
+
+	received, err := os.ReadFile(filepath.Join(downloadsDir, "secret.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, content) {
+		t.Fatal("received file differs from the one sent")
+	}
+}
@@ -0,0 +1,141 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event types pushed to the frontend
+const (
+	EventTransferProgress = "transfer_progress"
+	EventTransferState    = "transfer_state"
+	EventIncomingOffer    = "incoming_offer"
+	EventIncomingFile     = "incoming_file"
+	EventPeerJoined       = "peer_joined"
+	EventPeerLeft         = "peer_left"
+	EventSyncConflict     = "sync_conflict"
+)
+
+type Event struct {
+	Type string      `json:"type"`
+	Time string      `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// IncomingFileEvent describes the progress of a file being received
+type IncomingFileEvent struct {
+	TransferID    string `json:"transfer_id"`
+	FileName      string `json:"file_name"`
+	FileSize      int64  `json:"file_size"`
+	BytesReceived int64  `json:"bytes_received"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+var (
+	eventSubscribers = make(map[chan Event]struct{})
+	eventsMutex      sync.Mutex
+)
+
+const (
+	// Events buffered per client before new ones are dropped for it
+	eventBufferSize = 64
+
+	// Interval between keep-alive comments on idle streams
+	eventHeartbeatInterval = 15 * time.Second
+)
+
+// publishEvent delivers an event to every connected client without blocking;
+// clients that fall behind miss events rather than stalling transfers
+func publishEvent(eventType string, data interface{}) {
+	event := Event{
+		Type: eventType,
+		Time: time.Now().Format(time.RFC3339Nano),
+		Data: data,
+	}
+
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	for subscriber := range eventSubscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// subscribeEvents registers a new event listener
+func subscribeEvents() chan Event {
+	subscriber := make(chan Event, eventBufferSize)
+
+	eventsMutex.Lock()
+	eventSubscribers[subscriber] = struct{}{}
+	eventsMutex.Unlock()
+
+	return subscriber
+}
+
+// unsubscribeEvents removes an event listener
+func unsubscribeEvents(subscriber chan Event) {
+	eventsMutex.Lock()
+	delete(eventSubscribers, subscriber)
+	eventsMutex.Unlock()
+}
+
+// HandleEvents HTTP handler that streams events to the client using
+// Server-Sent Events
+func HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	subscriber := subscribeEvents()
+	defer unsubscribeEvents(subscriber)
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	log.Println("Event stream client connected")
+
+	// Send an initial comment so the client knows the stream is open
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Println("Event stream client disconnected")
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+
+		case event := <-subscriber:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error encoding event %s: %v", event.Type, err)
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package logic
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// receiveEvents returns the events published while the test runs
+func receiveEvents(t *testing.T) chan Event {
+	subscriber := subscribeEvents()
+	t.Cleanup(func() { unsubscribeEvents(subscriber) })
+	return subscriber
+}
+
+// drainEvents returns the events already buffered for a subscriber
+func drainEvents(subscriber chan Event) []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-subscriber:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestHandleEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(HandleEvents))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if got := response.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	// The opening comment arrives once the client is subscribed
+	reader := bufio.NewReader(response.Body)
+	readFrame := func() string {
+		var frame strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading event stream: %v", err)
+			}
+			if line == "\n" {
+				return frame.String()
+			}
+			frame.WriteString(line)
+		}
+	}
+	if frame := readFrame(); frame != ": connected\n" {
+		t.Fatalf("first frame = %q, want the connected comment", frame)
+	}
+
+	publishEvent(EventTransferState, Transfer{ID: "abc", State: TransferRunning})
+
+	frame := readFrame()
+	eventLine, dataLine, _ := strings.Cut(strings.TrimSuffix(frame, "\n"), "\n")
+	if eventLine != "event: "+EventTransferState {
+		t.Fatalf("event line = %q, want %q", eventLine, "event: "+EventTransferState)
+	}
+
+	var event struct {
+		Type string   `json:"type"`
+		Data Transfer `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(dataLine, "data: ")), &event); err != nil {
+		t.Fatalf("decoding %q: %v", dataLine, err)
+	}
+	if event.Type != EventTransferState || event.Data.ID != "abc" || event.Data.State != TransferRunning {
+		t.Fatalf("event = %+v, want the running transfer abc", event)
+	}
+}
+
+func TestPublishEventDropsForSlowClients(t *testing.T) {
+	subscriber := receiveEvents(t)
+
+	for range eventBufferSize + 10 {
+		publishEvent(EventTransferProgress, nil)
+	}
+
+	if got := len(drainEvents(subscriber)); got != eventBufferSize {
+		t.Fatalf("buffered %d events, want %d", got, eventBufferSize)
+	}
+}
+
+func TestPeerEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     []string
+		after      []string
+		wantJoined []string
+		wantLeft   []string
+	}{
+		{name: "first discovery", after: []string{"a", "b"}, wantJoined: []string{"a", "b"}},
+		{name: "unchanged", before: []string{"a"}, after: []string{"a"}},
+		{name: "one joins", before: []string{"a"}, after: []string{"a", "b"}, wantJoined: []string{"b"}},
+		{name: "one leaves", before: []string{"a", "b"}, after: []string{"b"}, wantLeft: []string{"a"}},
+		{name: "all replaced", before: []string{"a"}, after: []string{"b"}, wantJoined: []string{"b"}, wantLeft: []string{"a"}},
+	}
+
+	peersOf := func(ids []string) []Peer {
+		var peers []Peer
+		for _, id := range ids {
+			peers = append(peers, Peer{ID: id, Hostname: "host-" + id})
+		}
+		return peers
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peersMutex.Lock()
+			previous := discoveredPeers
+			discoveredPeers = peersOf(tt.before)
+			peersMutex.Unlock()
+			t.Cleanup(func() {
+				peersMutex.Lock()
+				discoveredPeers = previous
+				peersMutex.Unlock()
+			})
+
+			subscriber := receiveEvents(t)
+			updatePeersList(peersOf(tt.after))
+
+			var joined, left []string
+			for _, event := range drainEvents(subscriber) {
+				peer := event.Data.(Peer)
+				switch event.Type {
+				case EventPeerJoined:
+					joined = append(joined, peer.ID)
+				case EventPeerLeft:
+					if peer.Status != "offline" {
+						t.Fatalf("peer %s left with status %q, want offline", peer.ID, peer.Status)
+					}
+					left = append(left, peer.ID)
+				}
+			}
+			slices.Sort(joined)
+			slices.Sort(left)
+
+			if !slices.Equal(joined, tt.wantJoined) || !slices.Equal(left, tt.wantLeft) {
+				t.Fatalf("joined %q and left %q, want %q and %q", joined, left, tt.wantJoined, tt.wantLeft)
+			}
+		})
+	}
+}
@@ -2,33 +2,68 @@ package logic
 
 import (
 	"context"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
 	pb "backend/proto" // Replace with your actual module path
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 type FileTransferRequest struct {
-	PeerID string `json:"peerid"`
-	File   string `json:"file"`
+	PeerID string   `json:"peerid"`
+	File   string   `json:"file"`
+	Files  []string `json:"files"` // files or directories sent in the same job
+
+	// Seal chunks so only the receiver can read them, even through a relay
+	Encrypt bool `json:"encrypt"`
+
+	// Send only the blocks that differ from the receiver's existing copy
+	Delta bool `json:"delta"`
+
+	// Send large files as ranges over this many concurrent streams. This
+	// is no faster than one stream on the links benchmarked; see parallel.go.
+	Streams int `json:"streams"`
 }
 
 type FileTransferResponse struct {
-	Message string `json:"message"`
-	Peer    string `json:"peer"`
-	File    string `json:"file"`
-	Status  string `json:"status"`
+	Message    string `json:"message"`
+	TransferID string `json:"transfer_id"`
+	Peer       string `json:"peer"`
+	File       string `json:"file"`
+	Status     string `json:"status"`
 }
 
+const (
+	// Number of times a broken stream is resumed before giving up
+	maxSendAttempts  = 5
+	resumeRetryDelay = 3 * time.Second
+
+	// How long a receiver waits for a cancelled stream to wind down, and
+	// how long a sender waits for the receiver to acknowledge a cancel
+	cancelWaitTimeout   = 5 * time.Second
+	cancelNotifyTimeout = 10 * time.Second
+)
+
+// CRC32C (Castagnoli) table for per-chunk checksums
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
 // gRPC server implementation
 type fileTransferServer struct {
 	pb.UnimplementedFileTransferServiceServer
@@ -41,86 +76,405 @@ func StartGRPCServer(port int) {
 		log.Fatalf("Failed to listen on port %d: %v", port, err)
 	}
 
-	grpcServer := grpc.NewServer()
-	pb.RegisterFileTransferServiceServer(grpcServer, &fileTransferServer{})
+	grpcServer := newGRPCServer()
 
-	log.Printf("gRPC server listening on port %d", port)
+	log.Printf("gRPC server listening on port %d (TLS)", port)
 
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve gRPC: %v", err)
 	}
 }
 
+// newGRPCServer creates the gRPC server with every service registered
+func newGRPCServer() *grpc.Server {
+	// Mutual TLS: both sides present certificates pinned to their peer IDs,
+	// and only paired peers may call anything but Pair
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(serverTLSConfig())),
+		grpc.UnaryInterceptor(requireTrustedPeer),
+		grpc.StreamInterceptor(requireTrustedPeerStream),
+	)
+	pb.RegisterFileTransferServiceServer(grpcServer, &fileTransferServer{})
+	pb.RegisterBrowseServiceServer(grpcServer, &browseServer{})
+	pb.RegisterSyncServiceServer(grpcServer, &syncServer{})
+
+	return grpcServer
+}
+
+// QueryResume reports how much of a transfer is already on disk so the
+// sender can continue from that offset
+func (s *fileTransferServer) QueryResume(ctx context.Context, req *pb.ResumeRequest) (*pb.ResumeResponse, error) {
+	if !isValidTransferID(req.TransferId) {
+		return nil, status.Error(codes.InvalidArgument, "invalid transfer ID")
+	}
+
+	fileName, err := sanitizeRelativePath(req.FileName)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	bytesReceived, chunksReceived := resumePoint(req.TransferId, fileName, req.FileSize)
+	if bytesReceived > 0 {
+		log.Printf("Transfer %s for %s can resume from byte %d", req.TransferId, req.FileName, bytesReceived)
+	}
+
+	return &pb.ResumeResponse{
+		BytesReceived:  bytesReceived,
+		ChunksReceived: chunksReceived,
+	}, nil
+}
+
 // SendFile handles incoming file transfers via gRPC streaming
 func (s *fileTransferServer) SendFile(stream pb.FileTransferService_SendFileServer) error {
-	var fileName string
-	var totalBytes int64
-	var receivedChunks int64
-
-	// Create downloads directory if it doesn't exist
-	downloadsDir := "./downloads"
-	if err := os.MkdirAll(downloadsDir, 0755); err != nil {
+	// Create downloads and partial directories if they don't exist
+	if err := os.MkdirAll(filepath.Join(downloadsDir, partialDir), 0755); err != nil {
 		log.Printf("Error creating downloads directory: %v", err)
 		return err
 	}
 
-	var file *os.File
-
-	for {
-		chunk, err := stream.Recv()
-		if err == io.EOF {
-			// End of stream - close file and send response
-			if file != nil {
-				file.Close()
-			}
-
-			log.Printf("File transfer completed: %s (%d bytes)", fileName, totalBytes)
+	chunk, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "no file data received")
+	}
+	if err != nil {
+		log.Printf("Error receiving chunk: %v", err)
+		return err
+	}
 
-			return stream.SendAndClose(&pb.FileTransferResponse{
-				Success:       true,
-				Message:       fmt.Sprintf("File %s received successfully", fileName),
-				BytesReceived: totalBytes,
-			})
-		}
+	// Directories and links are a single chunk with no data
+	if chunk.IsDirectory {
+		return receiveDirectory(stream, chunk)
+	}
+	if chunk.SymlinkTarget != "" || chunk.HardlinkTarget != "" {
+		return receiveLink(stream, chunk)
+	}
 
+	// An encrypted stream announces itself on its first chunk
+	var opener *chunkCipher
+	if chunk.Encryption != nil {
+		opener, err = newReceiveCipher(chunk.Encryption, chunk.TransferId, chunk.OfferId)
 		if err != nil {
-			log.Printf("Error receiving chunk: %v", err)
-			if file != nil {
-				file.Close()
-				// Clean up partial file
-				os.Remove(filepath.Join(downloadsDir, fileName))
-			}
+			log.Printf("Rejecting encrypted stream for %s: %v", chunk.FileName, err)
 			return err
 		}
+	}
 
-		// First chunk - create file
-		if file == nil {
-			fileName = chunk.FileName
-			filePath := filepath.Join(downloadsDir, fileName)
+	// Open the partial file at the resume offset
+	state, file, err := openPartialFile(stream.Context(), chunk)
+	if err != nil {
+		log.Printf("Error starting receive of %s: %v", chunk.FileName, err)
+		return err
+	}
 
-			file, err = os.Create(filePath)
-			if err != nil {
-				log.Printf("Error creating file %s: %v", filePath, err)
-				return err
-			}
+	receiver := newFileReceiver(state, file, opener)
+	if err := receiver.receive(stream, chunk); err != nil {
+		return err
+	}
 
-			log.Printf("Starting to receive file: %s", fileName)
+	response, err := receiver.complete()
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(response)
+}
+
+// receiveDirectory creates a directory entry from an accepted offer. Senders
+// repeat the entry with its metadata once the directory's contents are in
+// place, since writing them would change its mtime.
+func receiveDirectory(stream pb.FileTransferService_SendFileServer, chunk *pb.FileChunk) error {
+	dirName, err := sanitizeRelativePath(chunk.FileName)
+	if err != nil {
+		log.Printf("Rejecting directory name %q: %v", chunk.FileName, err)
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if !isOfferAccepted(stream.Context(), chunk.OfferId, OfferedFile{Path: dirName, IsDir: true}) {
+		log.Printf("Rejecting directory %s: offer %q was not accepted", dirName, chunk.OfferId)
+		return status.Error(codes.PermissionDenied, "transfer was not accepted by the receiver")
+	}
+
+	dirPath, err := receivedPath(dirName)
+	if err == nil {
+		if info, statErr := os.Lstat(dirPath); statErr == nil && info.Mode()&fs.ModeSymlink != 0 {
+			err = fmt.Errorf("%s is a symlink", dirPath)
 		}
+	}
+	if err != nil {
+		log.Printf("Rejecting directory %s: %v", dirName, err)
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
 
-		// Write chunk data to file
-		bytesWritten, err := file.Write(chunk.Data)
-		if err != nil {
-			log.Printf("Error writing to file: %v", err)
-			file.Close()
-			os.Remove(filepath.Join(downloadsDir, fileName))
-			return err
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		log.Printf("Error creating directory %s: %v", dirPath, err)
+		return status.Errorf(codes.Internal, "failed to create directory: %v", err)
+	}
+
+	if err := applyMetadata(dirPath, chunkMetadata(chunk)); err != nil {
+		log.Printf("Error applying metadata to %s: %v", dirPath, err)
+	}
+
+	log.Printf("Created directory: %s", dirName)
+
+	// The entry carrying its metadata is the directory's last
+	if chunk.Mtime != 0 {
+		consumeOfferEntry(chunk.OfferId, dirName)
+	}
+
+	return stream.SendAndClose(&pb.FileTransferResponse{
+		Success:  true,
+		Message:  fmt.Sprintf("Directory %s created", dirName),
+		Verified: true,
+	})
+}
+
+// receiveLink creates a symlink or hardlink entry from an accepted offer. A
+// taken name is resolved by the collision policy, as for a received file.
+func receiveLink(stream pb.FileTransferService_SendFileServer, chunk *pb.FileChunk) error {
+	linkName, err := sanitizeRelativePath(chunk.FileName)
+	if err != nil {
+		log.Printf("Rejecting link name %q: %v", chunk.FileName, err)
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	entry := OfferedFile{Path: linkName, SymlinkTarget: chunk.SymlinkTarget, HardlinkTarget: chunk.HardlinkTarget}
+	if !isOfferAccepted(stream.Context(), chunk.OfferId, entry) {
+		log.Printf("Rejecting link %s: offer %q was not accepted", linkName, chunk.OfferId)
+		return status.Error(codes.PermissionDenied, "transfer was not accepted by the receiver")
+	}
+
+	linkPath, err := receivedPath(linkName)
+	if err != nil {
+		log.Printf("Rejecting link %s: %v", linkName, err)
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		log.Printf("Error creating directory for %s: %v", linkPath, err)
+		return status.Errorf(codes.Internal, "failed to create directory: %v", err)
+	}
+
+	storedPath, err := placeReceivedLink(linkPath, entry, chunk.OfferId)
+	if err != nil {
+		log.Printf("Error creating link %s: %v", linkPath, err)
+		return err
+	}
+
+	message := fmt.Sprintf("Link %s created", linkName)
+	switch storedPath {
+	case "":
+		log.Printf("Kept the existing %s, skipping link", linkPath)
+		message = fmt.Sprintf("Link %s skipped, kept the existing entry", linkName)
+	case linkPath:
+		log.Printf("Created link: %s", linkName)
+	default:
+		log.Printf("%s already exists, created the link as %s", linkPath, storedPath)
+		message = fmt.Sprintf("Link %s created as %s", linkName, filepath.Base(storedPath))
+	}
+	consumeOfferEntry(chunk.OfferId, linkName)
+
+	return stream.SendAndClose(&pb.FileTransferResponse{
+		Success:  true,
+		Message:  message,
+		Verified: true,
+	})
+}
+
+// placeReceivedLink creates a link at linkPath under the collision policy,
+// returning where it was created, or "" if the existing entry was kept
+func placeReceivedLink(linkPath string, entry OfferedFile, offerID string) (string, error) {
+	placementMutex.Lock()
+	defer placementMutex.Unlock()
+
+	storedPath, err := resolveCollision(linkPath, collisionPolicy())
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to place link: %v", err)
+	}
+	if storedPath == "" {
+		return "", nil
+	}
+
+	// Only overwriting leaves something at the path
+	if info, err := os.Lstat(storedPath); err == nil {
+		if info.IsDir() {
+			return "", status.Errorf(codes.FailedPrecondition, "%s already exists as a directory", entry.Path)
+		}
+		if err := os.Remove(storedPath); err != nil {
+			return "", status.Errorf(codes.Internal, "failed to replace %s: %v", entry.Path, err)
 		}
+	}
+
+	if entry.SymlinkTarget != "" {
+		err = os.Symlink(filepath.FromSlash(entry.SymlinkTarget), storedPath)
+	} else {
+		err = linkReceivedFile(placedName(offerID, entry.HardlinkTarget), storedPath)
+	}
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to create link: %v", err)
+	}
+
+	return storedPath, nil
+}
+
+// linkReceivedFile hardlinks linkPath to a regular file already received
+// into the downloads directory
+func linkReceivedFile(target, linkPath string) error {
+	targetPath, err := receivedPath(target)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(targetPath)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("hardlink target %s is not a regular file", target)
+	}
+
+	return os.Link(targetPath, linkPath)
+}
 
-		totalBytes += int64(bytesWritten)
-		receivedChunks++
+// openPartialFile validates the first chunk of a stream and opens the partial
+// file positioned at the offset the receiver already holds
+func openPartialFile(ctx context.Context, chunk *pb.FileChunk) (*partialState, *os.File, error) {
+	if !isValidTransferID(chunk.TransferId) {
+		return nil, nil, status.Error(codes.InvalidArgument, "invalid transfer ID")
+	}
+	if chunk.FileSize < 0 {
+		return nil, nil, status.Error(codes.InvalidArgument, "invalid file size")
+	}
+
+	// Never trust a peer-supplied name as a path
+	fileName, err := sanitizeRelativePath(chunk.FileName)
+	if err != nil {
+		log.Printf("Rejecting file name %q: %v", chunk.FileName, err)
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
-		log.Printf("Received chunk %d for %s (%d bytes)", receivedChunks, fileName, bytesWritten)
+	// Only accept data the user agreed to receive
+	if !isOfferAccepted(ctx, chunk.OfferId, OfferedFile{Path: fileName, Size: chunk.FileSize}) {
+		log.Printf("Rejecting stream for %s: offer %q was not accepted", fileName, chunk.OfferId)
+		return nil, nil, status.Error(codes.PermissionDenied, "transfer was not accepted by the receiver")
 	}
+
+	return openPartial(chunk.TransferId, chunk.OfferId, fileName, chunk.FileSize, chunk.Offset)
+}
+
+// openPartial claims a transfer and opens its partial file at the offset the
+// receiver already holds, which must be where the incoming data starts
+func openPartial(transferID, offerID, fileName string, fileSize, startOffset int64) (*partialState, *os.File, error) {
+	if !claimReceive(transferID) {
+		return nil, nil, status.Error(codes.Aborted, "transfer already in progress")
+	}
+
+	offset, chunks := resumePoint(transferID, fileName, fileSize)
+	if startOffset != offset {
+		releaseReceive(transferID)
+		return nil, nil, status.Errorf(codes.FailedPrecondition,
+			"resume offset mismatch: sender at %d, receiver has %d", startOffset, offset)
+	}
+
+	dataPath, _ := partialPaths(transferID)
+	file, err := os.OpenFile(dataPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		releaseReceive(transferID)
+		return nil, nil, status.Errorf(codes.Internal, "failed to open partial file: %v", err)
+	}
+
+	state := &partialState{
+		TransferID:     transferID,
+		OfferID:        offerID,
+		FileName:       fileName,
+		FileSize:       fileSize,
+		BytesReceived:  offset,
+		ChunksReceived: chunks,
+		hash:           sha256.New(),
+	}
+
+	// Drop anything written after the last checkpoint and carry on from the
+	// digest saved with it. A sidecar without one means hashing the bytes
+	// already held, so the final digest covers the whole file.
+	err = file.Truncate(offset)
+	if err == nil && restoreHash(state.hash, transferID, offset) {
+		_, err = file.Seek(offset, io.SeekStart)
+	} else if err == nil {
+		_, err = io.CopyN(state.hash, file, offset)
+	}
+	if err != nil {
+		file.Close()
+		releaseReceive(transferID)
+		return nil, nil, status.Errorf(codes.Internal, "failed to position partial file: %v", err)
+	}
+
+	if err := savePartialState(state); err != nil {
+		log.Printf("Error saving partial state for %s: %v", state.TransferID, err)
+	}
+
+	return state, file, nil
+}
+
+// CancelTransfer handles a sender cancelling a transfer. The accepted offer is
+// revoked and the partial data is kept or removed according to the
+// keep_partial_on_cancel setting.
+func (s *fileTransferServer) CancelTransfer(ctx context.Context, req *pb.CancelRequest) (*pb.CancelResponse, error) {
+	if !isValidTransferID(req.OfferId) {
+		return nil, status.Error(codes.InvalidArgument, "invalid offer ID")
+	}
+
+	// Only the sender of an offer may cancel it
+	if !revokeOffer(ctx, req.OfferId) {
+		log.Printf("Refusing to cancel offer %s for a peer that did not send it", req.OfferId)
+		return nil, status.Error(codes.PermissionDenied, "offer belongs to another sender")
+	}
+
+	// Cancelled between files, so there is no partial data to deal with
+	if req.TransferId == "" {
+		log.Printf("Offer %s cancelled by sender", req.OfferId)
+		return &pb.CancelResponse{PartialKept: false}, nil
+	}
+
+	if !isValidTransferID(req.TransferId) {
+		return nil, status.Error(codes.InvalidArgument, "invalid transfer ID")
+	}
+
+	// Give the cancelled stream a moment to notice and save its sidecar
+	if !waitForReceiveRelease(req.TransferId, cancelWaitTimeout) {
+		return nil, status.Error(codes.Unavailable, "transfer is still being written")
+	}
+
+	// Only the sender that owns the partial data may discard it
+	state := loadPartialState(req.TransferId)
+	if state == nil || state.OfferID != req.OfferId {
+		log.Printf("Transfer %s cancelled by sender before any data arrived", req.TransferId)
+		return &pb.CancelResponse{PartialKept: false}, nil
+	}
+
+	keep := GetConfig().KeepPartialOnCancel
+	if !keep {
+		removePartial(req.TransferId)
+	}
+
+	log.Printf("Transfer %s of %s cancelled by sender at %d of %d bytes (partial kept: %t)",
+		state.TransferID, state.FileName, state.BytesReceived, state.FileSize, keep)
+	publishIncomingFile(state, "cancelled", nil)
+
+	return &pb.CancelResponse{PartialKept: keep}, nil
+}
+
+// publishIncomingFile notifies the frontend about a file being received
+func publishIncomingFile(state *partialState, fileStatus string, err error) {
+	event := IncomingFileEvent{
+		TransferID:    state.TransferID,
+		FileName:      state.FileName,
+		FileSize:      state.FileSize,
+		BytesReceived: state.BytesReceived,
+		Status:        fileStatus,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	publishEvent(EventIncomingFile, event)
 }
 
 // HandleFileTransfer HTTP handler for file transfer requests
@@ -139,69 +493,247 @@ func HandleFileTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A single "file" and a "files" list may be combined
+	paths := req.Files
+	if req.File != "" {
+		paths = append([]string{req.File}, paths...)
+	}
+
 	// Validate required fields
-	if req.PeerID == "" || req.File == "" {
-		http.Error(w, "Missing required fields: peerid and file", http.StatusBadRequest)
+	if req.PeerID == "" || len(paths) == 0 {
+		http.Error(w, "Missing required fields: peerid and file or files", http.StatusBadRequest)
 		return
 	}
 
-	// Find peer by ID
+	// Paired peers that are offline get the transfer once they reappear
 	peer := GetPeerByID(req.PeerID)
-	if peer == nil {
+	trusted, paired := getTrustedPeer(req.PeerID)
+	if peer == nil && !paired {
 		http.Error(w, "Peer not found", http.StatusNotFound)
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(req.File); os.IsNotExist(err) {
-		http.Error(w, "File not found", http.StatusNotFound)
+	if !paired {
+		http.Error(w, "Peer is not paired with this device", http.StatusForbidden)
+		return
+	}
+
+	if req.Streams < 0 || req.Streams > maxParallelStreams {
+		http.Error(w, fmt.Sprintf("streams must be between 0 and %d", maxParallelStreams), http.StatusBadRequest)
 		return
 	}
 
-	// Start file transfer in goroutine
-	go func() {
-		if err := sendFileToP2P(peer, req.File); err != nil {
-			log.Printf("File transfer failed: %v", err)
+	// Check that every file or directory exists
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			http.Error(w, "File not found: "+path, http.StatusNotFound)
+			return
 		}
-	}()
+	}
+
+	hostname := trusted.Hostname
+	message := "File transfer queued until the peer is online"
+	if peer != nil {
+		hostname = peer.Hostname
+		message = "File transfer initiated"
+	}
+
+	// Queue the transfer so it is retried until it gets through
+	options := transferOptions{encrypt: req.Encrypt, delta: req.Delta, streams: req.Streams}
+	transfer := enqueueTransfer(trusted.PeerID, hostname, paths, options)
 
 	response := FileTransferResponse{
-		Message: "File transfer initiated",
-		Peer:    peer.Hostname,
-		File:    filepath.Base(req.File),
-		Status:  "started",
+		Message:    message,
+		TransferID: transfer.ID,
+		Peer:       hostname,
+		File:       transfer.File,
+		Status:     TransferQueued,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// sendFileToP2P sends a file to a peer via gRPC streaming
-func sendFileToP2P(peer *Peer, filePath string) error {
-	// Connect to peer's gRPC server
+// outgoingFile carries the state of one file being streamed to a peer
+type outgoingFile struct {
+	client     pb.FileTransferServiceClient
+	peer       *Peer
+	transfer   *Transfer
+	entry      transferEntry
+	file       *os.File
+	transferID string
+	offerID    string
+
+	// Receiver's encryption key when chunks are sealed end to end
+	recipientKey *ecdh.PublicKey
+
+	// Codec the receiver agreed to, or empty, and whether this file is
+	// worth compressing with it
+	compression  string
+	compressFile bool
+}
+
+// dialPeer opens a mutually authenticated connection to a peer's gRPC server
+func dialPeer(peer *Peer) (*grpc.ClientConn, error) {
 	conn, err := grpc.Dial(
 		fmt.Sprintf("%s:%d", peer.IP, peer.Port),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(credentials.NewTLS(clientTLSConfig(peer))),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to connect to peer %s: %v", peer.Hostname, err)
+		return nil, fmt.Errorf("failed to connect to peer %s: %v", peer.Hostname, err)
+	}
+	return conn, nil
+}
+
+// sendFileToP2P sends the files and directories of a transfer to a peer via
+// gRPC streaming, resuming from the receiver's partial copy whenever a stream
+// breaks. Progress is recorded on transfer; cancelling ctx with
+// errTransferCancelled stops the transfer and tells the receiver.
+func sendFileToP2P(ctx context.Context, peer *Peer, transfer *Transfer) error {
+	// Connect to peer's gRPC server
+	conn, err := dialPeer(peer)
+	if err != nil {
+		return err
 	}
 	defer conn.Close()
 
-	client := pb.NewFileTransferServiceClient(conn)
+	return sendTransfer(ctx, pb.NewFileTransferServiceClient(conn), peer, transfer)
+}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+// sendTransfer runs a transfer over a connection already open to the peer
+func sendTransfer(ctx context.Context, client pb.FileTransferServiceClient, peer *Peer, transfer *Transfer) error {
+	// There is no fixed deadline: the stall watchdog aborts a transfer that
+	// stops moving, and an optional cap bounds the total duration. The
+	// context cause records which of them fired.
+	cfg := GetConfig()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
-	// Start streaming
+	if cfg.MaxTransferSeconds > 0 {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithTimeoutCause(ctx, time.Duration(cfg.MaxTransferSeconds)*time.Second, errTransferDeadline)
+		defer cancelDeadline()
+	}
+
+	// Expand directories into the list of entries to send
+	entries, err := buildTransferPlan(transfer.Paths)
+	if err != nil {
+		return err
+	}
+	transfer.setFiles(entries)
+
+	// Get the receiver's key before asking the user there to accept
+	var recipientKey *ecdh.PublicKey
+	if transfer.Encrypted {
+		recipientKey, err = fetchRecipientKey(ctx, client, peer.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Ask the receiver for consent before any data flows
+	offerID, compression, err := offerFilesToPeer(ctx, client, peer, transfer.File, entries)
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return cause
+		}
+		return err
+	}
+	transfer.setCompression(compression)
+
+	// Waiting for the user to accept is not a stall, so start watching now
+	if cfg.StallTimeoutSeconds > 0 {
+		watchdog := startStallWatchdog(transfer, time.Duration(cfg.StallTimeoutSeconds)*time.Second, cancel)
+		defer watchdog.stop()
+	}
+
+	for i, entry := range entries {
+		transfer.startFile(i)
+
+		if entry.IsDir || entry.SymlinkTarget != "" || entry.HardlinkTarget != "" {
+			err = sendEntry(ctx, client, entry, offerID, false)
+			if err != nil && ctx.Err() != nil {
+				err = abortedSendError(ctx, client, peer, "", offerID, err)
+			}
+		} else {
+			out := &outgoingFile{
+				client:   client,
+				peer:     peer,
+				transfer: transfer,
+				entry:    entry,
+				offerID:  offerID,
+
+				recipientKey: recipientKey,
+				compression:  compression,
+			}
+			err = out.send(ctx)
+		}
+
+		transfer.finishFile(i, err)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.RelPath, err)
+		}
+	}
+
+	// Directory modes and mtimes go last, deepest first, so writing the
+	// contents doesn't undo them and read-only directories can be filled
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].IsDir {
+			continue
+		}
+		if err := sendEntry(ctx, client, entries[i], offerID, true); err != nil {
+			if ctx.Err() != nil {
+				err = abortedSendError(ctx, client, peer, "", offerID, err)
+			}
+			return fmt.Errorf("%s: %w", entries[i].RelPath, err)
+		}
+	}
+
+	log.Printf("Sent %d entries to %s", len(entries), peer.Hostname)
+	if compression != "" {
+		snapshot := transfer.copy()
+		log.Printf("Compressed %d bytes of file data to %d with %s", snapshot.RawBytes, snapshot.CompressedBytes, compression)
+	}
+
+	return nil
+}
+
+// sendEntry asks the receiver to create a directory or link, which is a
+// single chunk with no data. This is how empty directories survive the
+// transfer.
+func sendEntry(ctx context.Context, client pb.FileTransferServiceClient, entry transferEntry, offerID string, withMetadata bool) error {
 	stream, err := client.SendFile(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create stream: %v", err)
+		return fmt.Errorf("failed to create stream: %w", err)
 	}
 
+	chunk := &pb.FileChunk{
+		FileName:       entry.RelPath,
+		OfferId:        offerID,
+		IsDirectory:    entry.IsDir,
+		SymlinkTarget:  entry.SymlinkTarget,
+		HardlinkTarget: entry.HardlinkTarget,
+	}
+	if withMetadata {
+		chunk.Mode = entry.Metadata.Mode
+		chunk.Mtime = entry.Metadata.ModTime
+	}
+
+	if err := stream.Send(chunk); err != nil {
+		return fmt.Errorf("failed to send entry: %w", streamError(stream, err))
+	}
+
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return fmt.Errorf("failed to create entry: %w", err)
+	}
+
+	return nil
+}
+
+// send streams the file, retrying and resuming when the stream breaks
+func (o *outgoingFile) send(ctx context.Context) error {
 	// Open file for reading
-	file, err := os.Open(filePath)
+	file, err := os.Open(o.entry.SourcePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
 	}
@@ -213,53 +745,365 @@ func sendFileToP2P(peer *Peer, filePath string) error {
 		return fmt.Errorf("failed to get file info: %v", err)
 	}
 
-	fileName := filepath.Base(filePath)
-	fileSize := fileInfo.Size()
-	chunkSize := int64(1024 * 64) // 64KB chunks
-	totalChunks := (fileSize + chunkSize - 1) / chunkSize
+	if fileInfo.Size() != o.entry.Size {
+		return fmt.Errorf("file changed size since the transfer was offered")
+	}
+
+	o.file = file
+	o.transferID = computeTransferID(o.entry.SourcePath, fileInfo)
+	o.compressFile = o.compression != "" && worthCompressing(o.entry.RelPath, file)
+
+	var lastErr error
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("Retrying transfer of %s to %s (attempt %d/%d) after: %v",
+				o.entry.RelPath, o.peer.Hostname, attempt, maxSendAttempts, lastErr)
+
+			select {
+			case <-ctx.Done():
+				return abortedSendError(ctx, o.client, o.peer, o.transferID, o.offerID, lastErr)
+			case <-time.After(resumeRetryDelay):
+			}
+		}
+
+		if o.useParallel() {
+			lastErr = o.attemptParallel(ctx)
+		} else {
+			lastErr = o.attempt(ctx)
+		}
+		if lastErr == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return abortedSendError(ctx, o.client, o.peer, o.transferID, o.offerID, lastErr)
+		}
+
+		if !isRetryableSendError(lastErr) {
+			return lastErr
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %v", maxSendAttempts, lastErr)
+}
+
+// abortedSendError explains why a transfer's context ended: a user cancel
+// (which the receiver is told about), a stall, or the overall deadline. The
+// partial data is left on the receiver after a stall or deadline so a later
+// send can resume.
+func abortedSendError(ctx context.Context, client pb.FileTransferServiceClient, peer *Peer, transferID, offerID string, lastErr error) error {
+	cause := context.Cause(ctx)
+
+	switch {
+	case errors.Is(cause, errTransferCancelled):
+		notifyCancel(client, peer, transferID, offerID)
+		return errTransferCancelled
+	case errors.Is(cause, errTransferStalled), errors.Is(cause, errTransferDeadline):
+		log.Printf("Transfer to %s aborted: %v", peer.Hostname, cause)
+		return fmt.Errorf("%w (last error: %v)", cause, lastErr)
+	}
+
+	return lastErr
+}
+
+// notifyCancel tells the receiver a transfer was cancelled so it can revoke
+// the offer and apply its partial-file policy. The transfer context is
+// already done, so this uses a fresh one.
+func notifyCancel(client pb.FileTransferServiceClient, peer *Peer, transferID, offerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelNotifyTimeout)
+	defer cancel()
+
+	response, err := client.CancelTransfer(ctx, &pb.CancelRequest{
+		TransferId: transferID,
+		OfferId:    offerID,
+	})
+	if err != nil {
+		log.Printf("Failed to notify %s of cancellation: %v", peer.Hostname, err)
+		return
+	}
+
+	log.Printf("Offer %s cancelled on %s (partial kept: %t)", offerID, peer.Hostname, response.PartialKept)
+}
+
+// offerFilesToPeer describes the transfer to the receiver and waits for the
+// user there to accept or reject it, returning the offer ID streams must
+// carry and the compression codec the receiver chose
+func offerFilesToPeer(ctx context.Context, client pb.FileTransferServiceClient, peer *Peer, name string, entries []transferEntry) (string, string, error) {
+	systemInfo := GetSystemInfoStruct()
+	offerID := generateRandomID()
+
+	files := make([]*pb.OfferedFile, len(entries))
+	var totalSize int64
+	for i, entry := range entries {
+		files[i] = &pb.OfferedFile{
+			Path:           entry.RelPath,
+			Size:           entry.Size,
+			IsDirectory:    entry.IsDir,
+			SymlinkTarget:  entry.SymlinkTarget,
+			HardlinkTarget: entry.HardlinkTarget,
+		}
+		totalSize += entry.Size
+	}
+
+	log.Printf("Offering %s (%d entries) to %s, waiting for acceptance", name, len(entries), peer.Hostname)
+
+	response, err := client.OfferFile(ctx, &pb.FileOffer{
+		OfferId:        offerID,
+		FileName:       name,
+		FileSize:       totalSize,
+		SenderPeerId:   systemInfo.PeerID,
+		SenderHostname: systemInfo.Hostname,
+		Files:          files,
+		Compression:    offeredCompression(),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to offer files to %s: %w", peer.Hostname, err)
+	}
+
+	if response.TimedOut {
+		return "", "", fmt.Errorf("%s %w for %s", peer.Hostname, errOfferTimedOut, name)
+	}
+	if !response.Accepted {
+		return "", "", fmt.Errorf("%s %w %s: %s", peer.Hostname, errOfferDeclined, name, response.Message)
+	}
+
+	// An older receiver or one with compression off leaves this empty
+	compression := response.Compression
+	if compression != "" && !slices.Contains(supportedCompression, compression) {
+		return "", "", fmt.Errorf("%s chose unsupported compression %q", peer.Hostname, compression)
+	}
+
+	return offerID, compression, nil
+}
+
+// attempt asks the receiver where to resume and streams the rest of the file
+func (o *outgoingFile) attempt(ctx context.Context) error {
+	fileName := o.entry.RelPath
+	fileSize := o.entry.Size
+
+	// Only an estimate, since the chunk size adapts as the transfer runs
+	totalChunks := (fileSize + initialChunkSize - 1) / initialChunkSize
 
-	log.Printf("Sending file %s to %s (%d bytes, %d chunks)", fileName, peer.Hostname, fileSize, totalChunks)
+	// Ask the receiver how much it already has, timing the round trip to
+	// size the first chunks
+	queryStart := time.Now()
+	resume, err := o.client.QueryResume(ctx, &pb.ResumeRequest{
+		TransferId: o.transferID,
+		FileName:   fileName,
+		FileSize:   fileSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query resume point: %w", err)
+	}
+	rtt := time.Since(queryStart)
 
-	// Send file in chunks
-	buffer := make([]byte, chunkSize)
-	chunkNumber := int64(0)
+	offset := resume.BytesReceived
+	if offset < 0 || offset > fileSize {
+		offset = 0
+	}
 
-	for {
-		bytesRead, err := file.Read(buffer)
-		if err == io.EOF {
-			break
+	// Hash the part the receiver already holds; this also leaves the file
+	// positioned at the resume offset. It moves no bytes, so it is no stall.
+	hasher := sha256.New()
+	if _, err := o.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek file: %v", err)
+	}
+	releaseHash := o.transfer.holdStall()
+	_, err = io.CopyN(hasher, o.file, offset)
+	releaseHash()
+	if err != nil {
+		return fmt.Errorf("failed to hash file: %v", err)
+	}
+	o.transfer.resetProgress(offset)
+
+	// In delta mode a transfer starting from scratch sends only what the
+	// receiver's existing copy lacks
+	var sigs *blockSignatures
+	if o.transfer.Delta && offset == 0 && fileSize >= minDeltaFileSize {
+		sigs, err = fetchBlockSignatures(ctx, o.client, o.offerID, fileName, fileSize)
+		if err != nil {
+			log.Printf("Sending %s whole, no block signatures: %v", fileName, err)
+			sigs = nil
 		}
+	}
+
+	// Start streaming
+	stream, err := o.client.SendFile(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	// Each attempt seals with a fresh key when encrypting end to end; the
+	// first chunk carries the header the receiver derives it from
+	var sealer *chunkCipher
+	var header *pb.EncryptionHeader
+	if o.recipientKey != nil {
+		sealer, header, err = newSendCipher(o.recipientKey, o.peer.ID, o.transferID, o.offerID)
 		if err != nil {
-			return fmt.Errorf("failed to read file: %v", err)
+			return fmt.Errorf("failed to set up encryption: %v", err)
 		}
+	}
 
-		chunkNumber++
+	// setData compresses and seals a chunk's data if needed and checksums
+	// what is sent. Send has copied a chunk out by the time it returns, so
+	// one buffer of each serves every chunk.
+	packBuffer := getChunkBuffer()
+	defer putChunkBuffer(packBuffer)
+	sealBuffer := getChunkBuffer()
+	defer putChunkBuffer(sealBuffer)
 
-		chunk := &pb.FileChunk{
-			FileName:    fileName,
-			Data:        buffer[:bytesRead],
-			ChunkNumber: chunkNumber,
-			TotalChunks: totalChunks,
+	setData := func(chunk *pb.FileChunk, data []byte) {
+		o.compress(chunk, data, *packBuffer)
+		if sealer != nil {
+			sealer.seal(chunk, chunk.Data, *sealBuffer)
+			chunk.Encryption, header = header, nil
 		}
+		chunk.Crc32C = crc32.Checksum(chunk.Data, crc32cTable)
+	}
 
+	// sendChunk fills in a chunk's common fields and sends it; length is
+	// the number of bytes of the file it stands for
+	chunkNumber := resume.ChunksReceived
+	sendChunk := func(chunk *pb.FileChunk, data []byte, length int64) error {
+		chunkNumber++
+		chunk.FileName = fileName
+		chunk.ChunkNumber = chunkNumber
+		chunk.TotalChunks = totalChunks
+		chunk.TransferId = o.transferID
+		chunk.Offset = offset
+		chunk.FileSize = fileSize
+		chunk.OfferId = o.offerID
+		setData(chunk, data)
+
+		if err := throttleTransfer(ctx, o.transfer, rateUpload, o.peer.ID, len(chunk.Data)); err != nil {
+			return err
+		}
 		if err := stream.Send(chunk); err != nil {
-			return fmt.Errorf("failed to send chunk %d: %v", chunkNumber, err)
+			return fmt.Errorf("failed to send chunk %d: %w", chunkNumber, streamError(stream, err))
 		}
 
-		log.Printf("Sent chunk %d/%d (%d bytes)", chunkNumber, totalChunks, bytesRead)
+		offset += length
+		o.transfer.setProgress(offset)
+		return nil
 	}
 
+	if sigs != nil {
+		log.Printf("Sending file %s to %s as a delta against %d bytes it holds", fileName, o.peer.Hostname, sigs.basisSize)
+
+		// The reader is hashed as it is read, which covers the whole file
+		err := writeDelta(io.TeeReader(o.file, hasher), sigs, initialChunkSize,
+			func(data []byte) error {
+				return sendChunk(sigs.deltaChunk(nil), data, int64(len(data)))
+			},
+			func(index, count int64) error {
+				run := &pb.BlockCopy{Index: index, Count: count}
+				return sendChunk(sigs.deltaChunk(run), nil, sigs.runLength(index, count))
+			})
+		if err != nil {
+			return err
+		}
+	} else {
+		if offset > 0 {
+			log.Printf("Resuming file %s to %s at byte %d of %d", fileName, o.peer.Hostname, offset, fileSize)
+		} else {
+			log.Printf("Sending file %s to %s (%d bytes)", fileName, o.peer.Hostname, fileSize)
+		}
+
+		// Send file in chunks sized to the link
+		buffer := getChunkBuffer()
+		defer putChunkBuffer(buffer)
+		sizer := newChunkSizer(rtt)
+
+		for {
+			bytesRead, err := o.file.Read((*buffer)[:sizer.next()])
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read file: %v", err)
+			}
+
+			data := (*buffer)[:bytesRead]
+			hasher.Write(data)
+
+			if err := sendChunk(&pb.FileChunk{}, data, int64(bytesRead)); err != nil {
+				return err
+			}
+			sizer.record(bytesRead)
+		}
+	}
+
+	// Finish with a data-less chunk carrying the whole-file digest and the
+	// file's mode and mtime. This also opens the stream for empty files.
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	trailer := &pb.FileChunk{
+		FileName:    fileName,
+		ChunkNumber: chunkNumber,
+		TotalChunks: totalChunks,
+		TransferId:  o.transferID,
+		Offset:      offset,
+		FileSize:    fileSize,
+		Sha256:      digest,
+		OfferId:     o.offerID,
+		Mode:        o.entry.Metadata.Mode,
+		Mtime:       o.entry.Metadata.ModTime,
+	}
+	setData(trailer, nil)
+
+	if err := stream.Send(trailer); err != nil {
+		return fmt.Errorf("failed to send digest: %w", streamError(stream, err))
+	}
+
+	// The receiver verifies and flushes the file before it answers, which
+	// moves no bytes
+	release := o.transfer.holdStall()
+	defer release()
+
 	// Close stream and get response
 	response, err := stream.CloseAndRecv()
 	if err != nil {
-		return fmt.Errorf("failed to close stream: %v", err)
+		return fmt.Errorf("failed to close stream: %w", err)
 	}
 
-	if response.Success {
-		log.Printf("File transfer successful: %s", response.Message)
-	} else {
+	return o.checkResponse(response, digest)
+}
+
+// checkResponse turns the receiver's verdict on a whole file into an error
+func (o *outgoingFile) checkResponse(response *pb.FileTransferResponse, digest string) error {
+	fileName := o.entry.RelPath
+
+	if !response.Success || !response.Verified {
 		log.Printf("File transfer failed: %s", response.Message)
+		return fmt.Errorf("receiver rejected %s: %s (sha256 %s, expected %s)",
+			fileName, response.Message, response.Sha256, digest)
+	}
+
+	log.Printf("File transfer successful: %s (sha256 %s)", response.Message, response.Sha256)
+	if response.BytesSaved > 0 {
+		log.Printf("Delta transfer of %s reused %d of %d bytes", fileName, response.BytesSaved, o.entry.Size)
+		o.transfer.addBytesSaved(response.BytesSaved)
 	}
 
 	return nil
 }
+
+// isRetryableSendError reports whether a failed attempt is worth resuming
+func isRetryableSendError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DataLoss, codes.Internal, codes.FailedPrecondition:
+		return true
+	}
+	return false
+}
+
+// streamError replaces the io.EOF returned by Send when the receiver has
+// ended the stream with the status the receiver actually reported
+func streamError(stream pb.FileTransferService_SendFileClient, err error) error {
+	if err != io.EOF {
+		return err
+	}
+	if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+		return recvErr
+	}
+	return err
+}
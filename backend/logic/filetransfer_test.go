@@ -0,0 +1,318 @@
+package logic
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash/crc32"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// withConfig changes the configuration for the rest of a test or benchmark
+func withConfig(tb testing.TB, change func(*Config)) {
+	configMutex.Lock()
+	previous := config
+	change(&config)
+	configMutex.Unlock()
+
+	tb.Cleanup(func() {
+		configMutex.Lock()
+		config = previous
+		configMutex.Unlock()
+	})
+}
+
+// acceptOffers accepts every offer this node receives until the test ends
+func acceptOffers(tb testing.TB) {
+	stop := make(chan struct{})
+	tb.Cleanup(func() { close(stop) })
+
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			offersMutex.Lock()
+			for _, offer := range pendingOffers {
+				select {
+				case offer.decision <- true:
+				default:
+				}
+			}
+			offersMutex.Unlock()
+		}
+	}()
+}
+
+// testNode sets this node up in a scratch directory with a fresh identity,
+// paired with itself and accepting every offer
+func testNode(tb testing.TB) {
+	tb.Chdir(tb.TempDir())
+	withNodeIdentity(tb)
+	withTrustStore(tb)
+	acceptOffers(tb)
+
+	// The node sends to itself, so it must be paired with itself
+	trustPeer(TrustedPeer{
+		PeerID:      systemInfo.PeerID,
+		Name:        "test",
+		IdentityKey: base64.StdEncoding.EncodeToString(identityKey.Public().(ed25519.PublicKey)),
+	})
+}
+
+// testPeer serves a testNode's gRPC services over TLS on loopback and
+// returns it as a peer to send to. Files sent to it land in the downloads
+// directory of the scratch directory.
+func testPeer(tb testing.TB) *Peer {
+	testNode(tb)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return servePeer(tb, lis)
+}
+
+// servePeer serves this node's gRPC services on a loopback listener until
+// the test ends and returns it as a peer to send to
+func servePeer(tb testing.TB, lis net.Listener) *Peer {
+	server := newGRPCServer()
+	go server.Serve(lis)
+	tb.Cleanup(server.Stop)
+
+	return &Peer{
+		ID:       systemInfo.PeerID,
+		Hostname: "test",
+		IP:       "127.0.0.1",
+		Port:     lis.Addr().(*net.TCPAddr).Port,
+		Verified: true,
+	}
+}
+
+// sendPaths sends paths to peer as one transfer and waits for it to finish
+func sendPaths(t *testing.T, peer *Peer, paths ...string) *Transfer {
+	t.Helper()
+
+	transfer := newTransfer(peer, paths, transferOptions{})
+	runTransfer(transfer, peer)
+	return transfer
+}
+
+// writePartial leaves partial data and its sidecar for a transfer in the
+// downloads directory, as an interrupted stream does
+func writePartial(t *testing.T, state *partialState, data []byte) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(downloadsDir, partialDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dataPath, _ := partialPaths(state.TransferID)
+	if err := os.WriteFile(dataPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := savePartialState(state); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// streamFile sends data as a file of an offer in a single chunk
+func streamFile(client pb.FileTransferServiceClient, offerID, fileName string, data []byte) error {
+	stream, err := client.SendFile(context.Background())
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+	stream.Send(&pb.FileChunk{
+		FileName:   fileName,
+		Data:       data,
+		TransferId: generateRandomID(),
+		FileSize:   int64(len(data)),
+		Crc32C:     crc32.Checksum(data, crc32cTable),
+		Sha256:     hex.EncodeToString(digest[:]),
+		OfferId:    offerID,
+	})
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+func TestReceivedFilesConsumeOffer(t *testing.T) {
+	peer := testPeer(t)
+	conn, err := dialPeer(peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewFileTransferServiceClient(conn)
+
+	data := []byte("quarterly numbers")
+	offerID := generateRandomID()
+	offersMutex.Lock()
+	acceptedOffers[offerID] = acceptedOffer{
+		Files: map[string]OfferedFile{
+			"a.txt": {Path: "a.txt", Size: int64(len(data))},
+			"b.txt": {Path: "b.txt", Size: int64(len(data))},
+		},
+		SenderPeerID: peer.ID,
+		AcceptedAt:   time.Now(),
+		Placed:       make(map[string]string),
+	}
+	offersMutex.Unlock()
+
+	tests := []struct {
+		name      string
+		file      string
+		wantCode  codes.Code
+		wantOffer bool // the offer is still remembered afterwards
+	}{
+		{name: "first file", file: "a.txt", wantOffer: true},
+		{name: "finished file again", file: "a.txt", wantCode: codes.PermissionDenied, wantOffer: true},
+		{name: "last file", file: "b.txt"},
+		{name: "last file again", file: "b.txt", wantCode: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := streamFile(client, offerID, tt.file, data); status.Code(err) != tt.wantCode {
+				t.Fatalf("SendFile error = %v, want %s", err, tt.wantCode)
+			}
+
+			offersMutex.Lock()
+			_, ok := acceptedOffers[offerID]
+			offersMutex.Unlock()
+			if ok != tt.wantOffer {
+				t.Fatalf("offer remembered = %v, want %v", ok, tt.wantOffer)
+			}
+		})
+	}
+}
+
+func TestCancelTransfer(t *testing.T) {
+	tests := []struct {
+		name         string
+		keepPartial  bool
+		partialOffer string        // offer the partial data belongs to, "" if none was received
+		writing      time.Duration // how much longer the stream keeps writing
+		wantKept     bool
+		wantOnDisk   bool
+	}{
+		{name: "partial removed", partialOffer: "sender", wantOnDisk: false},
+		{name: "partial kept", keepPartial: true, partialOffer: "sender", wantKept: true, wantOnDisk: true},
+		{name: "waits for the stream to stop", partialOffer: "sender", writing: 200 * time.Millisecond, wantOnDisk: false},
+		{name: "another sender's partial", partialOffer: "other", wantOnDisk: true},
+		{name: "nothing received"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			withConfig(t, func(c *Config) { c.KeepPartialOnCancel = tt.keepPartial })
+			sender, senderID := fromNewPeer(t, context.Background())
+
+			transferID, offerID := generateRandomID(), generateRandomID()
+			offersMutex.Lock()
+			acceptedOffers[offerID] = acceptedOffer{Files: map[string]OfferedFile{"report.pdf": {Path: "report.pdf", Size: 100}}, SenderPeerID: senderID, AcceptedAt: time.Now()}
+			offersMutex.Unlock()
+
+			if tt.partialOffer != "" {
+				owner := offerID
+				if tt.partialOffer == "other" {
+					owner = generateRandomID()
+				}
+				writePartial(t, &partialState{TransferID: transferID, OfferID: owner, FileName: "report.pdf", FileSize: 100, BytesReceived: 40}, make([]byte, 40))
+			}
+
+			if tt.writing > 0 {
+				claimReceive(transferID)
+				time.AfterFunc(tt.writing, func() { releaseReceive(transferID) })
+			}
+
+			response, err := (&fileTransferServer{}).CancelTransfer(sender, &pb.CancelRequest{TransferId: transferID, OfferId: offerID})
+			if err != nil {
+				t.Fatalf("CancelTransfer: %v", err)
+			}
+			if response.PartialKept != tt.wantKept {
+				t.Fatalf("partial kept = %v, want %v", response.PartialKept, tt.wantKept)
+			}
+
+			dataPath, statePath := partialPaths(transferID)
+			for _, path := range []string{dataPath, statePath} {
+				if _, err := os.Stat(path); (err == nil) != tt.wantOnDisk {
+					t.Fatalf("%s on disk = %v, want %v", path, err == nil, tt.wantOnDisk)
+				}
+			}
+
+			// The cancelled offer no longer admits streams
+			if isOfferAccepted(sender, offerID, OfferedFile{Path: "report.pdf", Size: 100}) {
+				t.Fatal("cancelled offer still admits streams")
+			}
+		})
+	}
+}
+
+func TestCancelTransferRejectsInvalidIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *pb.CancelRequest
+	}{
+		{name: "invalid transfer ID", request: &pb.CancelRequest{TransferId: "../x", OfferId: generateRandomID()}},
+		{name: "invalid offer ID", request: &pb.CancelRequest{TransferId: generateRandomID(), OfferId: ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&fileTransferServer{}).CancelTransfer(context.Background(), tt.request)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("CancelTransfer error = %v, want %s", err, codes.InvalidArgument)
+			}
+		})
+	}
+}
+
+func TestCancelTransferChecksSender(t *testing.T) {
+	t.Chdir(t.TempDir())
+	sender, senderID := fromNewPeer(t, context.Background())
+	other, _ := fromNewPeer(t, context.Background())
+
+	offerID := generateRandomID()
+	entry := OfferedFile{Path: "report.pdf", Size: 100}
+	offersMutex.Lock()
+	acceptedOffers[offerID] = acceptedOffer{Files: map[string]OfferedFile{entry.Path: entry}, SenderPeerID: senderID, AcceptedAt: time.Now()}
+	offersMutex.Unlock()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode codes.Code
+	}{
+		{name: "unauthenticated", ctx: context.Background(), wantCode: codes.PermissionDenied},
+		{name: "another peer", ctx: other, wantCode: codes.PermissionDenied},
+		{name: "sender", ctx: sender, wantCode: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&fileTransferServer{}).CancelTransfer(tt.ctx, &pb.CancelRequest{OfferId: offerID})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("CancelTransfer error = %v, want %s", err, tt.wantCode)
+			}
+			if accepted := isOfferAccepted(sender, offerID, entry); accepted != (tt.wantCode != codes.OK) {
+				t.Fatalf("offer still admits streams = %v after %s cancelled", accepted, tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package logic
+
+import "io/fs"
+
+// hardlinkKey reports no hardlinks on platforms without inode numbers
+func hardlinkKey(info fs.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
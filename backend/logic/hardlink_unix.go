@@ -0,0 +1,18 @@
+//go:build unix
+
+package logic
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// hardlinkKey identifies the inode behind a file that has more than one
+// link, so later paths to it can be sent as hardlinks
+func hardlinkKey(info fs.FileInfo) (fileKey, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
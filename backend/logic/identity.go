@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	nodeKeyFile = "node_key.pem"
+
+	// Bytes of the key hash kept in a peer ID; 20 bytes is 32 base32 characters
+	peerIDHashSize = 20
+)
+
+// This node's long-term identity. The peer ID is derived from its public
+// key, and the TLS certificate is signed with it, so a peer ID can only be
+// used by whoever holds the matching private key.
+var identityKey ed25519.PrivateKey
+
+var peerIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// loadIdentityKey reads this node's Ed25519 key from node_key.pem, creating
+// it on first start
+func loadIdentityKey() (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(nodeKeyFile)
+	if os.IsNotExist(err) {
+		log.Println("node_key.pem not found, generating identity key...")
+		return createIdentityKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("node_key.pem holds no PEM data")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("node_key.pem is not an Ed25519 key")
+	}
+
+	return privateKey, nil
+}
+
+// createIdentityKey generates a new Ed25519 key and writes it to node_key.pem
+func createIdentityKey() (ed25519.PrivateKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(nodeKeyFile, keyPEM, 0600); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// peerIDFromKey derives a peer ID from an identity public key: the base32
+// encoding of the start of its SHA-256
+func peerIDFromKey(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return strings.ToLower(peerIDEncoding.EncodeToString(sum[:peerIDHashSize]))
+}
+
+// peerIDFromCertificate checks that a certificate carries an Ed25519
+// identity key and returns the peer ID derived from it
+func peerIDFromCertificate(cert *x509.Certificate) (string, ed25519.PublicKey, error) {
+	publicKey, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return "", nil, fmt.Errorf("peer certificate does not hold an Ed25519 key")
+	}
+	return peerIDFromKey(publicKey), publicKey, nil
+}
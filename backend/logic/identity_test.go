@@ -0,0 +1,60 @@
+package logic
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+func TestIdentityKeyPersists(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	created, err := loadIdentityKey()
+	if err != nil {
+		t.Fatalf("creating the identity key: %v", err)
+	}
+	loaded, err := loadIdentityKey()
+	if err != nil {
+		t.Fatalf("loading the identity key: %v", err)
+	}
+	if !created.Equal(loaded) {
+		t.Fatal("loaded a different identity key than the one created")
+	}
+
+	peerID := peerIDFromKey(created.Public().(ed25519.PublicKey))
+	if len(peerID) != 32 || strings.ToLower(peerID) != peerID {
+		t.Fatalf("peer ID %q, want 32 lower-case base32 characters", peerID)
+	}
+	if again := peerIDFromKey(loaded.Public().(ed25519.PublicKey)); again != peerID {
+		t.Fatalf("peer ID changed from %s to %s across a restart", peerID, again)
+	}
+}
+
+func TestVerifyPeers(t *testing.T) {
+	peer := testPeer(t)
+	_, spoofedID := newPeerKey(t)
+
+	tests := []struct {
+		name         string
+		peer         Peer
+		wantVerified bool
+	}{
+		{name: "holds the key behind its ID", peer: *peer, wantVerified: true},
+		{name: "advertises someone else's ID", peer: Peer{ID: spoofedID, Hostname: "spoofed", IP: peer.IP, Port: peer.Port}},
+		{name: "nothing listening", peer: Peer{ID: peer.ID, Hostname: "gone", IP: "127.0.0.1", Port: 1}},
+	}
+
+	peers := make([]Peer, len(tests))
+	for i, tt := range tests {
+		peers[i] = tt.peer
+	}
+	verifyPeers(peers)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if peers[i].Verified != tt.wantVerified {
+				t.Fatalf("verified = %v, want %v", peers[i].Verified, tt.wantVerified)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type IncomingOffer struct {
+	ID             string        `json:"id"`
+	FileName       string        `json:"file_name"`
+	FileSize       int64         `json:"file_size"`
+	FileCount      int           `json:"file_count"`
+	Files          []OfferedFile `json:"files"`
+	SenderPeerID   string        `json:"sender_peer_id"`
+	SenderHostname string        `json:"sender_hostname"`
+	ReceivedAt     string        `json:"received_at"`
+	ExpiresAt      string        `json:"expires_at"`
+
+	decision chan bool
+}
+
+// OfferedFile is one file, directory or link within an offer
+type OfferedFile struct {
+	Path           string `json:"path"`
+	Size           int64  `json:"size"`
+	IsDir          bool   `json:"is_dir,omitempty"`
+	SymlinkTarget  string `json:"symlink_target,omitempty"`
+	HardlinkTarget string `json:"hardlink_target,omitempty"`
+}
+
+type IncomingOffersResponse struct {
+	Offers []IncomingOffer `json:"offers"`
+	Count  int             `json:"count"`
+}
+
+type OfferDecisionResponse struct {
+	Message string `json:"message"`
+	OfferID string `json:"offer_id"`
+	Status  string `json:"status"`
+}
+
+// acceptedOffer remembers what the user agreed to receive
+type acceptedOffer struct {
+	Files        map[string]OfferedFile
+	SenderPeerID string // only the peer that made the offer may use it
+	AcceptedAt   time.Time
+
+	// Files stored under another name because theirs was taken
+	Placed map[string]string
+}
+
+var (
+	pendingOffers  = make(map[string]*IncomingOffer)
+	acceptedOffers = make(map[string]acceptedOffer)
+	offersMutex    sync.Mutex
+)
+
+const (
+	// How long a sender waits for the user to accept or reject
+	offerTimeout = 2 * time.Minute
+
+	// How long an accepted offer admits streams, covering resumed attempts
+	acceptedOfferTTL = 12 * time.Hour
+
+	// Most entries a single offer may list
+	maxOfferEntries = 100000
+)
+
+// OfferFile parks an incoming offer until the user accepts or rejects it
+// through the REST API, or until it times out
+func (s *fileTransferServer) OfferFile(ctx context.Context, req *pb.FileOffer) (*pb.OfferResponse, error) {
+	if !isValidTransferID(req.OfferId) {
+		return nil, status.Error(codes.InvalidArgument, "invalid offer ID")
+	}
+
+	// The sender must be who its certificate says it is
+	if senderID, _, ok := authenticatedPeer(ctx); !ok || senderID != req.SenderPeerId {
+		log.Printf("Rejecting offer %s: sender claims %q but authenticated as %q", req.OfferId, req.SenderPeerId, senderID)
+		return nil, status.Error(codes.Unauthenticated, "sender peer ID does not match its certificate")
+	}
+
+	fileName, err := sanitizeFileName(req.FileName)
+	if err != nil {
+		log.Printf("Rejecting offer with file name %q: %v", req.FileName, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	files, err := offeredFiles(req)
+	if err != nil {
+		log.Printf("Rejecting offer %s: %v", req.OfferId, err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.Size
+	}
+
+	now := time.Now()
+	offer := &IncomingOffer{
+		ID:             req.OfferId,
+		FileName:       fileName,
+		FileSize:       totalSize,
+		FileCount:      len(files),
+		Files:          files,
+		SenderPeerID:   req.SenderPeerId,
+		SenderHostname: req.SenderHostname,
+		ReceivedAt:     now.Format(time.RFC3339),
+		ExpiresAt:      now.Add(offerTimeout).Format(time.RFC3339),
+		decision:       make(chan bool, 1),
+	}
+
+	offersMutex.Lock()
+	if _, exists := pendingOffers[offer.ID]; exists {
+		offersMutex.Unlock()
+		return nil, status.Error(codes.AlreadyExists, "offer already pending")
+	}
+	pendingOffers[offer.ID] = offer
+	offersMutex.Unlock()
+
+	defer func() {
+		offersMutex.Lock()
+		delete(pendingOffers, offer.ID)
+		offersMutex.Unlock()
+	}()
+
+	log.Printf("Incoming offer %s: %s (%d bytes) from %s", offer.ID, offer.FileName, offer.FileSize, offer.SenderHostname)
+	publishEvent(EventIncomingOffer, *offer)
+
+	timer := time.NewTimer(offerTimeout)
+	defer timer.Stop()
+
+	select {
+	case accepted := <-offer.decision:
+		if !accepted {
+			log.Printf("Offer %s rejected", offer.ID)
+			return &pb.OfferResponse{Accepted: false, Message: "Transfer rejected by receiver"}, nil
+		}
+
+		record := acceptedOffer{
+			Files:        make(map[string]OfferedFile, len(offer.Files)),
+			SenderPeerID: offer.SenderPeerID,
+			AcceptedAt:   time.Now(),
+			Placed:       make(map[string]string),
+		}
+		for _, file := range offer.Files {
+			record.Files[file.Path] = file
+		}
+
+		offersMutex.Lock()
+		acceptedOffers[offer.ID] = record
+		offersMutex.Unlock()
+
+		compression := chooseCompression(req.Compression)
+		log.Printf("Offer %s accepted", offer.ID)
+		return &pb.OfferResponse{Accepted: true, Message: "Transfer accepted", Compression: compression}, nil
+
+	case <-timer.C:
+		log.Printf("Offer %s timed out", offer.ID)
+		return &pb.OfferResponse{Accepted: false, Message: "Receiver did not respond in time", TimedOut: true}, nil
+
+	case <-ctx.Done():
+		log.Printf("Offer %s withdrawn by sender", offer.ID)
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// offeredFiles validates the entries of an offer. Offers without a file list
+// describe a single file by name and size.
+func offeredFiles(req *pb.FileOffer) ([]OfferedFile, error) {
+	if len(req.Files) == 0 {
+		req.Files = []*pb.OfferedFile{{Path: req.FileName, Size: req.FileSize}}
+	}
+
+	if len(req.Files) > maxOfferEntries {
+		return nil, fmt.Errorf("offer lists more than %d entries", maxOfferEntries)
+	}
+
+	files := make([]OfferedFile, 0, len(req.Files))
+	seen := make(map[string]bool, len(req.Files))
+	regularFiles := make(map[string]bool, len(req.Files))
+
+	for _, entry := range req.Files {
+		path, err := sanitizeRelativePath(entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", entry.Path, err)
+		}
+		isLink := entry.SymlinkTarget != "" || entry.HardlinkTarget != ""
+		if entry.Size < 0 || ((entry.IsDirectory || isLink) && entry.Size != 0) {
+			return nil, fmt.Errorf("%q: invalid size", entry.Path)
+		}
+		if (entry.IsDirectory && isLink) || (entry.SymlinkTarget != "" && entry.HardlinkTarget != "") {
+			return nil, fmt.Errorf("%q: conflicting entry types", entry.Path)
+		}
+		if seen[path] {
+			return nil, fmt.Errorf("%q listed twice", entry.Path)
+		}
+		seen[path] = true
+
+		file := OfferedFile{Path: path, Size: entry.Size, IsDir: entry.IsDirectory}
+
+		if entry.SymlinkTarget != "" {
+			if err := validateSymlinkTarget(path, entry.SymlinkTarget); err != nil {
+				return nil, fmt.Errorf("%q: %v", entry.Path, err)
+			}
+			file.SymlinkTarget = entry.SymlinkTarget
+		}
+
+		// A hardlink must point at a regular file listed earlier in the offer
+		if entry.HardlinkTarget != "" {
+			target, err := sanitizeRelativePath(entry.HardlinkTarget)
+			if err != nil {
+				return nil, fmt.Errorf("%q: hardlink target: %v", entry.Path, err)
+			}
+			if !regularFiles[target] {
+				return nil, fmt.Errorf("%q: hardlink target %q is not a file in this offer", entry.Path, target)
+			}
+			file.HardlinkTarget = target
+		}
+
+		if !file.IsDir && !isLink {
+			regularFiles[path] = true
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// isOfferAccepted checks that a stream comes from the sender of an offer the
+// user accepted and carries exactly one of the entries that offer listed
+func isOfferAccepted(ctx context.Context, offerID string, entry OfferedFile) bool {
+	offersMutex.Lock()
+	defer offersMutex.Unlock()
+
+	// Drop expired acceptances while we hold the lock
+	for id, accepted := range acceptedOffers {
+		if time.Since(accepted.AcceptedAt) > acceptedOfferTTL {
+			delete(acceptedOffers, id)
+		}
+	}
+
+	accepted, ok := acceptedOffers[offerID]
+	if !ok {
+		return false
+	}
+
+	// Knowing an offer ID is not enough; the stream must come from its sender
+	if senderID, _, ok := authenticatedPeer(ctx); !ok || senderID != accepted.SenderPeerID {
+		return false
+	}
+
+	file, ok := accepted.Files[entry.Path]
+	return ok && file == entry
+}
+
+// recordPlacement notes that a file of an offer was stored as storedName,
+// so hardlinks to it later in the offer find it
+func recordPlacement(offerID, fileName, storedName string) {
+	offersMutex.Lock()
+	defer offersMutex.Unlock()
+
+	if accepted, ok := acceptedOffers[offerID]; ok {
+		accepted.Placed[fileName] = storedName
+	}
+}
+
+// placedName returns the name a file of an offer was stored under
+func placedName(offerID, fileName string) string {
+	offersMutex.Lock()
+	defer offersMutex.Unlock()
+
+	if storedName, ok := acceptedOffers[offerID].Placed[fileName]; ok {
+		return storedName
+	}
+	return fileName
+}
+
+// consumeOfferEntry marks an entry of an accepted offer as received, so no
+// later stream can write it again, and forgets the offer once every entry
+// is in place
+func consumeOfferEntry(offerID, path string) {
+	offersMutex.Lock()
+	defer offersMutex.Unlock()
+
+	accepted, ok := acceptedOffers[offerID]
+	if !ok {
+		return
+	}
+
+	delete(accepted.Files, path)
+	if len(accepted.Files) == 0 {
+		delete(acceptedOffers, offerID)
+	}
+}
+
+// revokeOffer stops an accepted offer from admitting further streams when
+// the caller is its sender. It reports false for an offer another peer made.
+func revokeOffer(ctx context.Context, offerID string) bool {
+	offersMutex.Lock()
+	defer offersMutex.Unlock()
+
+	accepted, ok := acceptedOffers[offerID]
+	if !ok {
+		return true
+	}
+	if senderID, _, ok := authenticatedPeer(ctx); !ok || senderID != accepted.SenderPeerID {
+		return false
+	}
+
+	delete(acceptedOffers, offerID)
+	return true
+}
+
+// GetIncomingOffers HTTP handler that returns offers awaiting a decision
+func GetIncomingOffers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	offersMutex.Lock()
+	offers := make([]IncomingOffer, 0, len(pendingOffers))
+	for _, offer := range pendingOffers {
+		offers = append(offers, *offer)
+	}
+	offersMutex.Unlock()
+
+	sort.Slice(offers, func(i, j int) bool {
+		return offers[i].ReceivedAt < offers[j].ReceivedAt
+	})
+
+	response := IncomingOffersResponse{
+		Offers: offers,
+		Count:  len(offers),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding incoming offers response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleIncomingDecision HTTP handler that accepts or rejects a pending offer
+func HandleIncomingDecision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	offerID := r.PathValue("id")
+	action := r.PathValue("action")
+
+	var accepted bool
+	switch action {
+	case "accept":
+		accepted = true
+	case "reject":
+		accepted = false
+	default:
+		http.Error(w, "Unknown action: use accept or reject", http.StatusBadRequest)
+		return
+	}
+
+	offersMutex.Lock()
+	offer, ok := pendingOffers[offerID]
+	offersMutex.Unlock()
+
+	if !ok {
+		http.Error(w, "Offer not found", http.StatusNotFound)
+		return
+	}
+
+	// The channel is buffered; a second decision for the same offer is ignored
+	select {
+	case offer.decision <- accepted:
+	default:
+		http.Error(w, "Offer already decided", http.StatusConflict)
+		return
+	}
+
+	response := OfferDecisionResponse{
+		Message: "Offer " + action + "ed",
+		OfferID: offerID,
+		Status:  action + "ed",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
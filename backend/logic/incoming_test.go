@@ -0,0 +1,192 @@
+package logic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// waitForOffer waits until an offer is parked for the user to decide
+func waitForOffer(t *testing.T, offerID string) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		offersMutex.Lock()
+		_, ok := pendingOffers[offerID]
+		offersMutex.Unlock()
+		if ok {
+			return
+		}
+	}
+	t.Fatalf("offer %s never became pending", offerID)
+}
+
+// decideOffer posts the user's decision on an offer to the REST API
+func decideOffer(offerID, action string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/incoming/"+offerID+"/"+action, nil)
+	req.SetPathValue("id", offerID)
+	req.SetPathValue("action", action)
+
+	recorder := httptest.NewRecorder()
+	HandleIncomingDecision(recorder, req)
+	return recorder
+}
+
+func TestOfferConsent(t *testing.T) {
+	tests := []struct {
+		name         string
+		action       string // the user's decision, "" if the sender withdraws first
+		wantAccepted bool
+		wantCode     codes.Code
+	}{
+		{name: "accepted", action: "accept", wantAccepted: true},
+		{name: "rejected", action: "reject"},
+		{name: "withdrawn by sender", wantCode: codes.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, senderID := fromNewPeer(t, context.Background())
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			offer := &pb.FileOffer{OfferId: generateRandomID(), FileName: "report.pdf", FileSize: 1234, SenderHostname: "sender", SenderPeerId: senderID}
+
+			type result struct {
+				response *pb.OfferResponse
+				err      error
+			}
+			done := make(chan result, 1)
+			go func() {
+				response, err := (&fileTransferServer{}).OfferFile(ctx, offer)
+				done <- result{response, err}
+			}()
+			waitForOffer(t, offer.OfferId)
+
+			if tt.action == "" {
+				cancel()
+			} else if recorder := decideOffer(offer.OfferId, tt.action); recorder.Code != http.StatusOK {
+				t.Fatalf("%s returned %d: %s", tt.action, recorder.Code, recorder.Body)
+			}
+
+			got := <-done
+			if status.Code(got.err) != tt.wantCode {
+				t.Fatalf("OfferFile error = %v, want %s", got.err, tt.wantCode)
+			}
+			if got.err == nil && got.response.Accepted != tt.wantAccepted {
+				t.Fatalf("OfferFile accepted = %v, want %v", got.response.Accepted, tt.wantAccepted)
+			}
+
+			if accepted := isOfferAccepted(ctx, offer.OfferId, OfferedFile{Path: offer.FileName, Size: offer.FileSize}); accepted != tt.wantAccepted {
+				t.Fatalf("streams for the offer admitted = %v, want %v", accepted, tt.wantAccepted)
+			}
+
+			// Once answered the offer is no longer pending
+			if recorder := decideOffer(offer.OfferId, "accept"); recorder.Code != http.StatusNotFound {
+				t.Fatalf("second decision returned %d, want %d", recorder.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestOfferFileRejectsInvalidOffers(t *testing.T) {
+	pending := generateRandomID()
+	sender, senderID := fromNewPeer(t, context.Background())
+	ctx, cancel := context.WithCancel(sender)
+	defer cancel()
+	go (&fileTransferServer{}).OfferFile(ctx, &pb.FileOffer{OfferId: pending, FileName: "a.txt", SenderPeerId: senderID})
+	waitForOffer(t, pending)
+
+	tests := []struct {
+		name     string
+		offer    *pb.FileOffer
+		wantCode codes.Code
+	}{
+		{name: "invalid offer ID", offer: &pb.FileOffer{OfferId: "../offer", FileName: "a.txt"}, wantCode: codes.InvalidArgument},
+		{name: "path as file name", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "../a.txt"}, wantCode: codes.InvalidArgument},
+		{name: "negative size", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a.txt", FileSize: -1}, wantCode: codes.InvalidArgument},
+		{name: "already pending", offer: &pb.FileOffer{OfferId: pending, FileName: "a.txt"}, wantCode: codes.AlreadyExists},
+		{name: "entry outside the downloads", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/../../b.txt"}}}, wantCode: codes.InvalidArgument},
+		{name: "entry listed twice", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/b.txt"}, {Path: "a/b.txt"}}}, wantCode: codes.InvalidArgument},
+		{name: "directory with a size", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a", IsDirectory: true, Size: 1}}}, wantCode: codes.InvalidArgument},
+		{name: "symlink out of the transfer", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/link", SymlinkTarget: "../../etc/passwd"}}}, wantCode: codes.InvalidArgument},
+		{name: "absolute symlink", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/link", SymlinkTarget: "/etc/passwd"}}}, wantCode: codes.InvalidArgument},
+		{name: "hardlink to an unlisted file", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/link", HardlinkTarget: "b/secret"}}}, wantCode: codes.InvalidArgument},
+		{name: "hardlink to a later file", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/link", HardlinkTarget: "a/file"}, {Path: "a/file", Size: 1}}}, wantCode: codes.InvalidArgument},
+		{name: "hardlink to a symlink", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/sym", SymlinkTarget: "x"}, {Path: "a/link", HardlinkTarget: "a/sym"}}}, wantCode: codes.InvalidArgument},
+		{name: "link with a size", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/link", SymlinkTarget: "x", Size: 3}}}, wantCode: codes.InvalidArgument},
+		{name: "symlink and hardlink", offer: &pb.FileOffer{OfferId: generateRandomID(), FileName: "a", Files: []*pb.OfferedFile{{Path: "a/file", Size: 1}, {Path: "a/link", SymlinkTarget: "file", HardlinkTarget: "a/file"}}}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.offer.SenderPeerId = senderID
+			_, err := (&fileTransferServer{}).OfferFile(sender, tt.offer)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("OfferFile error = %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestIsOfferAccepted(t *testing.T) {
+	current, expired := generateRandomID(), generateRandomID()
+	sender, senderID := fromNewPeer(t, context.Background())
+	other, _ := fromNewPeer(t, context.Background())
+	files := map[string]OfferedFile{
+		"project":        {Path: "project", IsDir: true},
+		"project/a.txt":  {Path: "project/a.txt", Size: 1234},
+		"project/b/c.go": {Path: "project/b/c.go", Size: 10},
+		"project/link":   {Path: "project/link", SymlinkTarget: "a.txt"},
+		"project/copy":   {Path: "project/copy", HardlinkTarget: "project/a.txt"},
+	}
+
+	offersMutex.Lock()
+	acceptedOffers[current] = acceptedOffer{Files: files, SenderPeerID: senderID, AcceptedAt: time.Now()}
+	acceptedOffers[expired] = acceptedOffer{Files: files, SenderPeerID: senderID, AcceptedAt: time.Now().Add(-acceptedOfferTTL - time.Minute)}
+	offersMutex.Unlock()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context // the stream's caller, the sender if nil
+		offerID string
+		entry   OfferedFile
+		want    bool
+	}{
+		{name: "offered file", offerID: current, entry: OfferedFile{Path: "project/a.txt", Size: 1234}, want: true},
+		{name: "nested offered file", offerID: current, entry: OfferedFile{Path: "project/b/c.go", Size: 10}, want: true},
+		{name: "offered directory", offerID: current, entry: OfferedFile{Path: "project", IsDir: true}, want: true},
+		{name: "offered symlink", offerID: current, entry: OfferedFile{Path: "project/link", SymlinkTarget: "a.txt"}, want: true},
+		{name: "offered hardlink", offerID: current, entry: OfferedFile{Path: "project/copy", HardlinkTarget: "project/a.txt"}, want: true},
+		{name: "directory sent as a file", offerID: current, entry: OfferedFile{Path: "project"}},
+		{name: "file sent as a directory", offerID: current, entry: OfferedFile{Path: "project/a.txt", Size: 1234, IsDir: true}},
+		{name: "symlink retargeted", offerID: current, entry: OfferedFile{Path: "project/link", SymlinkTarget: "../../etc/passwd"}},
+		{name: "symlink sent as a hardlink", offerID: current, entry: OfferedFile{Path: "project/link", HardlinkTarget: "project/a.txt"}},
+		{name: "file sent as a symlink", offerID: current, entry: OfferedFile{Path: "project/a.txt", SymlinkTarget: "b/c.go"}},
+		{name: "file not offered", offerID: current, entry: OfferedFile{Path: "project/other.txt", Size: 1234}},
+		{name: "other size", offerID: current, entry: OfferedFile{Path: "project/a.txt", Size: 9999}},
+		{name: "unknown offer", offerID: generateRandomID(), entry: OfferedFile{Path: "project/a.txt", Size: 1234}},
+		{name: "no offer", entry: OfferedFile{Path: "project/a.txt", Size: 1234}},
+		{name: "expired acceptance", offerID: expired, entry: OfferedFile{Path: "project/a.txt", Size: 1234}},
+		{name: "another peer", ctx: other, offerID: current, entry: OfferedFile{Path: "project/a.txt", Size: 1234}},
+		{name: "unauthenticated", ctx: context.Background(), offerID: current, entry: OfferedFile{Path: "project/a.txt", Size: 1234}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = sender
+			}
+			if got := isOfferAccepted(ctx, tt.offerID, tt.entry); got != tt.want {
+				t.Fatalf("isOfferAccepted(%q, %+v) = %v, want %v", tt.offerID, tt.entry, got, tt.want)
+			}
+		})
+	}
+}
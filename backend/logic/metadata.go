@@ -0,0 +1,155 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	pb "backend/proto"
+)
+
+// POSIX special permission bits, as carried in FileChunk.mode
+const (
+	posixSetuid = 0o4000
+	posixSetgid = 0o2000
+	posixSticky = 0o1000
+)
+
+// fileMetadata is the mode and modification time a sender attached to an
+// entry. Senders that predate metadata leave ModTime at zero, in which case
+// nothing is applied.
+type fileMetadata struct {
+	Mode    uint32 // POSIX permission and special bits
+	ModTime int64  // Unix nanoseconds
+}
+
+// entryMetadata captures the metadata of a local file for sending
+func entryMetadata(info fs.FileInfo) fileMetadata {
+	return fileMetadata{
+		Mode:    posixMode(info.Mode()),
+		ModTime: info.ModTime().UnixNano(),
+	}
+}
+
+// chunkMetadata reads the metadata carried on a chunk
+func chunkMetadata(chunk *pb.FileChunk) fileMetadata {
+	return fileMetadata{Mode: chunk.Mode, ModTime: chunk.Mtime}
+}
+
+// posixMode converts Go's file mode into portable POSIX mode bits
+func posixMode(mode fs.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= posixSetuid
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= posixSetgid
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= posixSticky
+	}
+	return bits
+}
+
+// fileModeFromPOSIX converts POSIX mode bits back into Go's file mode
+func fileModeFromPOSIX(bits uint32) fs.FileMode {
+	mode := fs.FileMode(bits) & fs.ModePerm
+	if bits&posixSetuid != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if bits&posixSetgid != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if bits&posixSticky != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// applyMetadata sets the mode and modification time of a received file or
+// directory. Setuid, setgid and sticky bits are dropped unless the
+// strip_special_mode_bits setting is turned off.
+func applyMetadata(filePath string, meta fileMetadata) error {
+	if meta.ModTime == 0 {
+		return nil
+	}
+
+	mode := fileModeFromPOSIX(meta.Mode)
+	if GetConfig().StripSpecialModeBits {
+		mode &^= fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+	}
+
+	if err := os.Chmod(filePath, mode); err != nil {
+		return fmt.Errorf("failed to set mode: %v", err)
+	}
+
+	modTime := time.Unix(0, meta.ModTime)
+	if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+		return fmt.Errorf("failed to set modification time: %v", err)
+	}
+
+	return nil
+}
+
+// validateSymlinkTarget checks that a symlink at linkPath (relative to the
+// downloads directory) points somewhere inside the downloads directory.
+// Absolute targets and targets that climb out with ".." are rejected.
+func validateSymlinkTarget(linkPath, target string) error {
+	switch {
+	case target == "":
+		return fmt.Errorf("%w: empty symlink target", errInvalidFileName)
+	case !utf8.ValidString(target):
+		return fmt.Errorf("%w: symlink target is not valid UTF-8", errInvalidFileName)
+	case strings.ContainsAny(target, "\x00\\"):
+		return fmt.Errorf("%w: symlink target contains NUL or backslash", errInvalidFileName)
+	case len(target) > maxRelativePathLength:
+		return fmt.Errorf("%w: symlink target longer than %d bytes", errInvalidFileName, maxRelativePathLength)
+	case path.IsAbs(target) || filepath.IsAbs(target) || filepath.VolumeName(target) != "":
+		return fmt.Errorf("%w: absolute symlink target", errInvalidFileName)
+	}
+
+	resolved := path.Join(path.Dir(linkPath), target)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return fmt.Errorf("%w: symlink target escapes the transfer", errInvalidFileName)
+	}
+
+	return nil
+}
+
+// receivedPath maps a sanitized relative path onto the downloads directory,
+// refusing paths whose parent directories include a symlink. Together with
+// validateSymlinkTarget this stops a sender from planting a symlink and then
+// writing through it to somewhere outside the downloads directory.
+func receivedPath(relPath string) (string, error) {
+	return pathWithin(downloadsDir, relPath)
+}
+
+// pathWithin maps a sanitized relative path onto baseDir, refusing paths
+// whose parent directories include a symlink
+func pathWithin(baseDir, relPath string) (string, error) {
+	components := strings.Split(relPath, "/")
+	current := baseDir
+
+	for _, component := range components[:len(components)-1] {
+		current = filepath.Join(current, component)
+
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s is a symlink", current)
+		}
+	}
+
+	return filepath.Join(baseDir, filepath.FromSlash(relPath)), nil
+}
@@ -0,0 +1,252 @@
+package logic
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPOSIXMode(t *testing.T) {
+	tests := []struct {
+		name string
+		mode fs.FileMode
+		want uint32
+	}{
+		{name: "plain", mode: 0644, want: 0o644},
+		{name: "executable", mode: 0755, want: 0o755},
+		{name: "setuid", mode: 0755 | fs.ModeSetuid, want: 0o4755},
+		{name: "setgid", mode: 0750 | fs.ModeSetgid, want: 0o2750},
+		{name: "sticky directory", mode: 0777 | fs.ModeSticky | fs.ModeDir, want: 0o1777},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := posixMode(tt.mode)
+			if got != tt.want {
+				t.Fatalf("posixMode(%v) = %o, want %o", tt.mode, got, tt.want)
+			}
+			if back := fileModeFromPOSIX(got); back != tt.mode&^fs.ModeDir {
+				t.Fatalf("fileModeFromPOSIX(%o) = %v, want %v", got, back, tt.mode&^fs.ModeDir)
+			}
+		})
+	}
+}
+
+func TestApplyMetadata(t *testing.T) {
+	modTime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		strip     bool
+		meta      fileMetadata
+		wantMode  fs.FileMode
+		wantMTime time.Time
+	}{
+		{name: "mode and mtime", strip: true, meta: fileMetadata{Mode: 0o750, ModTime: modTime.UnixNano()}, wantMode: 0750, wantMTime: modTime},
+		{name: "special bits stripped", strip: true, meta: fileMetadata{Mode: 0o4755, ModTime: modTime.UnixNano()}, wantMode: 0755, wantMTime: modTime},
+		{name: "special bits kept", meta: fileMetadata{Mode: 0o4755, ModTime: modTime.UnixNano()}, wantMode: 0755 | fs.ModeSetuid, wantMTime: modTime},
+		{name: "sender without metadata", strip: true, meta: fileMetadata{Mode: 0o777}, wantMode: 0600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.StripSpecialModeBits = tt.strip })
+
+			filePath := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(filePath, nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+			before, err := os.Stat(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := applyMetadata(filePath, tt.meta); err != nil {
+				t.Fatalf("applyMetadata: %v", err)
+			}
+
+			info, err := os.Stat(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode() != tt.wantMode {
+				t.Fatalf("mode = %v, want %v", info.Mode(), tt.wantMode)
+			}
+			wantMTime := tt.wantMTime
+			if wantMTime.IsZero() {
+				wantMTime = before.ModTime()
+			}
+			if !info.ModTime().Equal(wantMTime) {
+				t.Fatalf("mtime = %v, want %v", info.ModTime(), wantMTime)
+			}
+		})
+	}
+}
+
+func TestValidateSymlinkTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		linkPath string
+		target   string
+		wantErr  bool
+	}{
+		{name: "sibling", linkPath: "project/link", target: "main.go"},
+		{name: "into subdirectory", linkPath: "project/link", target: "src/util.go"},
+		{name: "up within the transfer", linkPath: "project/src/link", target: "../main.go"},
+		{name: "to the transfer root", linkPath: "project/link", target: ".."},
+		{name: "dangling but inside", linkPath: "project/link", target: "missing"},
+
+		{name: "out of the transfer", linkPath: "project/link", target: "../../etc/passwd", wantErr: true},
+		{name: "out via a detour", linkPath: "project/link", target: "src/../../../outside", wantErr: true},
+		{name: "top-level link climbing out", linkPath: "link", target: "..", wantErr: true},
+		{name: "absolute", linkPath: "project/link", target: "/etc/passwd", wantErr: true},
+		{name: "backslash", linkPath: "project/link", target: `..\..\outside`, wantErr: true},
+		{name: "NUL", linkPath: "project/link", target: "main\x00.go", wantErr: true},
+		{name: "empty", linkPath: "project/link", target: "", wantErr: true},
+		{name: "invalid UTF-8", linkPath: "project/link", target: "main\xff.go", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSymlinkTarget(tt.linkPath, tt.target)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidFileName) {
+					t.Fatalf("validateSymlinkTarget(%q, %q) = %v, want errInvalidFileName", tt.linkPath, tt.target, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateSymlinkTarget(%q, %q): %v", tt.linkPath, tt.target, err)
+			}
+		})
+	}
+}
+
+func TestReceivedPath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	makeTree(t, ".", map[string]int{"downloads/project/": 0, "outside/": 0})
+	if err := os.Symlink(filepath.Join("..", "outside"), filepath.Join(downloadsDir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("project", filepath.Join(downloadsDir, "alias")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		relPath string
+		wantErr bool
+	}{
+		{name: "top level", relPath: "file.txt"},
+		{name: "existing directory", relPath: "project/file.txt"},
+		{name: "new directories", relPath: "new/deeper/file.txt"},
+		{name: "the link itself", relPath: "escape"},
+		{name: "through a link out of downloads", relPath: "escape/file.txt", wantErr: true},
+		{name: "through a link inside downloads", relPath: "alias/file.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := receivedPath(tt.relPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("receivedPath(%q) = %q, want error", tt.relPath, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("receivedPath(%q): %v", tt.relPath, err)
+			}
+			if want := filepath.Join(downloadsDir, filepath.FromSlash(tt.relPath)); got != want {
+				t.Fatalf("receivedPath(%q) = %q, want %q", tt.relPath, got, want)
+			}
+		})
+	}
+}
+
+func TestSendPreservesMetadata(t *testing.T) {
+	peer := testPeer(t)
+	withConfig(t, func(c *Config) { c.StripSpecialModeBits = true })
+
+	makeTree(t, "src", map[string]int{
+		"project/run.sh":       100,
+		"project/docs/a.md":    10,
+		"project/docs/private": 20,
+	})
+	if err := os.Symlink("run.sh", filepath.Join("src", "project", "start")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join("src", "project", "docs", "a.md"), filepath.Join("src", "project", "docs", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	modes := map[string]fs.FileMode{
+		"project/run.sh":       0755 | fs.ModeSetuid,
+		"project/docs/private": 0600,
+		"project/docs":         0750,
+	}
+	for name, mode := range modes {
+		path := filepath.Join("src", filepath.FromSlash(name))
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if transfer := sendPaths(t, peer, filepath.Join("src", "project")); transfer.State != TransferSucceeded {
+		t.Fatalf("transfer %s: %s", transfer.State, transfer.Error)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		wantMode  fs.FileMode
+		wantMTime bool
+	}{
+		{name: "setuid dropped", path: "project/run.sh", wantMode: 0755, wantMTime: true},
+		{name: "private file", path: "project/docs/private", wantMode: 0600, wantMTime: true},
+		{name: "directory set after its contents", path: "project/docs", wantMode: fs.ModeDir | 0750, wantMTime: true},
+		{name: "symlink", path: "project/start", wantMode: fs.ModeSymlink},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := os.Lstat(filepath.Join(downloadsDir, filepath.FromSlash(tt.path)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			mode := info.Mode()
+			if mode&fs.ModeSymlink != 0 {
+				mode = fs.ModeSymlink
+			}
+			if mode != tt.wantMode {
+				t.Fatalf("mode = %v, want %v", mode, tt.wantMode)
+			}
+			if tt.wantMTime && !info.ModTime().Equal(modTime) {
+				t.Fatalf("mtime = %v, want %v", info.ModTime(), modTime)
+			}
+		})
+	}
+
+	if target, err := os.Readlink(filepath.Join(downloadsDir, "project", "start")); err != nil || target != "run.sh" {
+		t.Fatalf("symlink points at %q (%v), want run.sh", target, err)
+	}
+
+	a, err := os.Stat(filepath.Join(downloadsDir, "project", "docs", "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(filepath.Join(downloadsDir, "project", "docs", "b.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(a, b) {
+		t.Fatal("a.md and b.md were received as separate files, want one file with two links")
+	}
+}
@@ -0,0 +1,237 @@
+package logic
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subdirectories of each peer's outbox that sent and failed entries are moved to
+const (
+	outboxSentDir   = "sent"
+	outboxFailedDir = "failed"
+)
+
+// How often the outbox is checked for new or changed entries
+const outboxPollInterval = time.Second
+
+// outboxEntry tracks one file or directory waiting in a peer's outbox
+type outboxEntry struct {
+	signature   outboxSignature
+	stableSince time.Time
+	sending     bool // queued, until it is filed
+}
+
+// outboxSignature summarises an entry's size and latest write so a change
+// anywhere inside a directory restarts its stability timer
+type outboxSignature struct {
+	files   int
+	size    int64
+	modTime time.Time
+}
+
+var (
+	outboxEntries = make(map[string]*outboxEntry)
+	outboxUnknown = make(map[string]bool) // peer directories already logged as unpaired
+	outboxMutex   sync.Mutex
+)
+
+// StartOutboxWatcher queues anything written to <outbox>/<peer>/ for that
+// peer once it has stopped changing. The entry moves to sent/ when the
+// transfer succeeds and to failed/ when the queue gives up on it.
+func StartOutboxWatcher() {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		scanOutbox()
+	}
+}
+
+// outboxDir returns the configured outbox directory with ~ expanded
+func outboxDir() (string, error) {
+	return expandHome(GetConfig().OutboxDir)
+}
+
+// scanOutbox checks every peer directory in the outbox and queues a transfer
+// for each entry that has been stable long enough
+func scanOutbox() {
+	dir, err := outboxDir()
+	if err != nil {
+		log.Printf("Error resolving outbox directory: %v", err)
+		return
+	}
+
+	peerDirs, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Error reading outbox %s: %v", dir, err)
+		}
+		return
+	}
+
+	stableFor := time.Duration(GetConfig().OutboxStableSeconds) * time.Second
+	now := time.Now()
+	seen := make(map[string]bool)
+
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	for _, peerDir := range peerDirs {
+		if !peerDir.IsDir() || strings.HasPrefix(peerDir.Name(), ".") {
+			continue
+		}
+
+		trusted, ok := resolveOutboxPeer(peerDir.Name())
+		if !ok {
+			if !outboxUnknown[peerDir.Name()] {
+				log.Printf("Outbox directory %s does not match a paired peer", peerDir.Name())
+				outboxUnknown[peerDir.Name()] = true
+			}
+			continue
+		}
+		delete(outboxUnknown, peerDir.Name())
+
+		peerPath := filepath.Join(dir, peerDir.Name())
+		entries, err := os.ReadDir(peerPath)
+		if err != nil {
+			log.Printf("Error reading outbox %s: %v", peerPath, err)
+			continue
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if name == outboxSentDir || name == outboxFailedDir || strings.HasPrefix(name, ".") {
+				continue
+			}
+
+			entryPath := filepath.Join(peerPath, name)
+			seen[entryPath] = true
+
+			state := outboxEntries[entryPath]
+			if state == nil {
+				state = &outboxEntry{}
+				outboxEntries[entryPath] = state
+			}
+			if state.sending {
+				continue
+			}
+
+			signature, err := statOutboxEntry(entryPath)
+			if err != nil {
+				continue
+			}
+			if signature != state.signature || state.stableSince.IsZero() {
+				state.signature = signature
+				state.stableSince = now
+				continue
+			}
+			if now.Sub(state.stableSince) < stableFor {
+				continue
+			}
+
+			state.sending = true
+
+			// An entry queued before a restart is already on its way
+			if isOutboxQueued(entryPath) {
+				continue
+			}
+
+			// The queue waits for the peer and retries failed attempts
+			transfer := queueTransfer(trusted.PeerID, trusted.Hostname, []string{entryPath}, transferOptions{}, entryPath)
+			log.Printf("Queued outbox entry %s for %s as transfer %s", entryPath, peerDir.Name(), transfer.ID)
+		}
+	}
+
+	// Forget entries that were removed by hand
+	for entryPath, state := range outboxEntries {
+		if !seen[entryPath] && !state.sending {
+			delete(outboxEntries, entryPath)
+		}
+	}
+}
+
+// resolveOutboxPeer matches an outbox directory name against the paired
+// peers' IDs, names and hostnames
+func resolveOutboxPeer(name string) (TrustedPeer, bool) {
+	trustMutex.RLock()
+	defer trustMutex.RUnlock()
+
+	if trusted, ok := trustedPeers[name]; ok {
+		return trusted, true
+	}
+	for _, trusted := range trustedPeers {
+		if strings.EqualFold(trusted.Name, name) {
+			return trusted, true
+		}
+	}
+	for _, trusted := range trustedPeers {
+		if strings.EqualFold(trusted.Hostname, name) {
+			return trusted, true
+		}
+	}
+	return TrustedPeer{}, false
+}
+
+// statOutboxEntry returns the signature of a file or directory tree
+func statOutboxEntry(entryPath string) (outboxSignature, error) {
+	var signature outboxSignature
+
+	err := filepath.WalkDir(entryPath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		signature.files++
+		if info.Mode().IsRegular() {
+			signature.size += info.Size()
+		}
+		if info.ModTime().After(signature.modTime) {
+			signature.modTime = info.ModTime()
+		}
+		return nil
+	})
+
+	return signature, err
+}
+
+// fileOutboxEntry moves an entry whose transfer has left the queue to sent/
+// or failed/ and stops tracking it
+func fileOutboxEntry(entryPath string, succeeded bool) {
+	destDir := outboxFailedDir
+	if succeeded {
+		destDir = outboxSentDir
+	}
+
+	destPath, err := moveOutboxEntry(entryPath, destDir)
+	if err != nil {
+		log.Printf("Error moving outbox entry %s to %s: %v", entryPath, destDir, err)
+	} else {
+		log.Printf("Moved outbox entry %s to %s", entryPath, destPath)
+	}
+
+	outboxMutex.Lock()
+	delete(outboxEntries, entryPath)
+	outboxMutex.Unlock()
+}
+
+// moveOutboxEntry moves an entry into a subdirectory of its peer's outbox,
+// numbering the name if an earlier entry of the same name is already there
+func moveOutboxEntry(entryPath, subdir string) (string, error) {
+	destDir := filepath.Join(filepath.Dir(entryPath), subdir)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", err
+	}
+
+	destPath := uniquePath(filepath.Join(destDir, filepath.Base(entryPath)))
+	return destPath, os.Rename(entryPath, destPath)
+}
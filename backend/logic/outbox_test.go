@@ -0,0 +1,271 @@
+package logic
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// withOutbox points the outbox at ./outbox with entries stable after
+// stableSeconds, forgetting entries tracked by earlier tests
+func withOutbox(tb testing.TB, stableSeconds int) {
+	withConfig(tb, func(c *Config) {
+		c.OutboxDir = "outbox"
+		c.OutboxStableSeconds = stableSeconds
+	})
+
+	outboxMutex.Lock()
+	previousEntries, previousUnknown := outboxEntries, outboxUnknown
+	outboxEntries, outboxUnknown = make(map[string]*outboxEntry), make(map[string]bool)
+	outboxMutex.Unlock()
+
+	tb.Cleanup(func() {
+		outboxMutex.Lock()
+		outboxEntries, outboxUnknown = previousEntries, previousUnknown
+		outboxMutex.Unlock()
+	})
+}
+
+// withPeers replaces the discovered peers for the rest of a test
+func withPeers(tb testing.TB, peers ...Peer) {
+	peersMutex.Lock()
+	previous := discoveredPeers
+	discoveredPeers = peers
+	peersMutex.Unlock()
+
+	tb.Cleanup(func() {
+		peersMutex.Lock()
+		discoveredPeers = previous
+		peersMutex.Unlock()
+	})
+}
+
+// outboxState returns a copy of the tracked state of an outbox entry
+func outboxState(entryPath string) (outboxEntry, bool) {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+
+	abs, _ := filepath.Abs(entryPath)
+	state, ok := outboxEntries[abs]
+	if !ok {
+		return outboxEntry{}, false
+	}
+	return *state, true
+}
+
+// queuedOutbox returns the queued transfers that send an outbox entry
+func queuedOutbox(entryPath string) []QueuedTransfer {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	abs, _ := filepath.Abs(entryPath)
+	var queued []QueuedTransfer
+	for _, item := range transferQueue {
+		if item.Outbox == abs {
+			queued = append(queued, *item)
+		}
+	}
+	return queued
+}
+
+func TestScanOutboxQueuesStableEntries(t *testing.T) {
+	t.Chdir(t.TempDir())
+	withTrustStore(t)
+	withPeers(t) // the queue is not running, so queued entries stay queued
+	trustPeer(TrustedPeer{PeerID: "peer-1", Name: "laptop", Hostname: "laptop.local"})
+
+	tests := []struct {
+		name       string
+		file       string // file written under outbox/
+		entry      string // entry of the peer's outbox it belongs to, "" if ignored
+		change     bool   // written to between the scans
+		queued     bool   // already queued before a restart
+		wantQueued bool
+	}{
+		{name: "stable file", file: "peer-1/report.pdf", entry: "peer-1/report.pdf", wantQueued: true},
+		{name: "stable directory", file: "laptop/photos/a.jpg", entry: "laptop/photos", wantQueued: true},
+		{name: "by hostname", file: "LAPTOP.LOCAL/notes.txt", entry: "LAPTOP.LOCAL/notes.txt", wantQueued: true},
+		{name: "queued before a restart", file: "peer-1/report.pdf", entry: "peer-1/report.pdf", queued: true, wantQueued: true},
+		{name: "still being written", file: "peer-1/growing.bin", entry: "peer-1/growing.bin", change: true},
+		{name: "unpaired peer", file: "stranger/report.pdf"},
+		{name: "hidden file", file: "peer-1/.partial"},
+		{name: "already sent", file: "peer-1/sent/old.txt"},
+		{name: "already failed", file: "peer-1/failed/old.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			withOutbox(t, 1)
+			withQueue(t)
+			withoutTransfers(t)
+			makeTree(t, "outbox", map[string]int{tt.file: 10})
+			if tt.queued {
+				abs, _ := filepath.Abs(filepath.Join("outbox", filepath.FromSlash(tt.entry)))
+				transferQueue = append(transferQueue, &QueuedTransfer{ID: generateRandomID(), PeerID: "peer-1", Paths: []string{abs}, State: QueuePending, Outbox: abs})
+			}
+
+			scanOutbox()
+			if tt.change {
+				if err := os.WriteFile(filepath.Join("outbox", filepath.FromSlash(tt.file)), make([]byte, 20), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Pretend the stability window has passed since the first scan
+			outboxMutex.Lock()
+			for _, state := range outboxEntries {
+				state.stableSince = state.stableSince.Add(-2 * time.Second)
+			}
+			tracked := len(outboxEntries)
+			outboxMutex.Unlock()
+			scanOutbox()
+
+			if tt.entry == "" {
+				if tracked != 0 {
+					t.Fatalf("tracked %d outbox entries, want none", tracked)
+				}
+				return
+			}
+
+			entryPath := filepath.Join("outbox", filepath.FromSlash(tt.entry))
+			state, ok := outboxState(entryPath)
+			if !ok {
+				t.Fatalf("%s is not tracked", tt.entry)
+			}
+			if state.sending != tt.wantQueued {
+				t.Fatalf("%s sending = %v, want %v", tt.entry, state.sending, tt.wantQueued)
+			}
+
+			queued := queuedOutbox(entryPath)
+			if want := map[bool]int{true: 1}[tt.wantQueued]; len(queued) != want {
+				t.Fatalf("%s queued %d times, want %d", tt.entry, len(queued), want)
+			}
+			if tt.wantQueued && (queued[0].PeerID != "peer-1" || queued[0].State != QueuePending) {
+				t.Fatalf("%s queued for %s as %s, want pending for peer-1", tt.entry, queued[0].PeerID, queued[0].State)
+			}
+		})
+	}
+}
+
+// waitForOutbox waits until no outbox entry is tracked any more, which is
+// once every send has been filed
+func waitForOutbox(t *testing.T) {
+	t.Helper()
+
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		outboxMutex.Lock()
+		pending := len(outboxEntries)
+		outboxMutex.Unlock()
+		if pending == 0 {
+			return
+		}
+	}
+	t.Fatal("outbox entries were never filed")
+}
+
+func TestOutboxFilesSentEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		reachable   bool
+		alreadySent bool // an earlier entry of the same name is in sent/
+		attempts    int  // failed attempts the queue has already made
+		want        string
+	}{
+		{name: "sent", reachable: true, want: "sent/report.txt"},
+		{name: "sent again", reachable: true, alreadySent: true, want: "sent/report (1).txt"},
+		{name: "peer unreachable", want: "report.txt"},
+		{name: "out of attempts", attempts: maxQueueAttempts - 1, want: "failed/report.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := testPeer(t)
+			if !tt.reachable {
+				_, peer.ID = newPeerKey(t)
+				peer.Port = closedPort(t)
+				trustPeer(TrustedPeer{PeerID: peer.ID, Name: "gone"})
+			}
+			withPeers(t, *peer)
+			withOutbox(t, 0)
+			withQueue(t)
+
+			peerDir := filepath.Join("outbox", peer.ID)
+			makeTree(t, peerDir, map[string]int{"report.txt": 100})
+			if tt.alreadySent {
+				makeTree(t, peerDir, map[string]int{"sent/report.txt": 1})
+			}
+
+			// The first scan notes the entry, the second queues it
+			scanOutbox()
+			scanOutbox()
+
+			entryPath := filepath.Join(peerDir, "report.txt")
+			queueMutex.Lock()
+			for _, item := range transferQueue {
+				item.Attempts = tt.attempts
+			}
+			queueMutex.Unlock()
+			processTransferQueue()
+
+			if tt.want == "report.txt" {
+				// Left in the outbox while the queue retries it
+				waitForQueue(t, QueuePending)
+				if queued := queuedOutbox(entryPath); len(queued) != 1 || queued[0].Attempts != 1 {
+					t.Fatalf("entry queued as %+v, want one transfer after one attempt", queued)
+				}
+				if state, _ := outboxState(entryPath); !state.sending {
+					t.Fatal("entry no longer tracked as sending")
+				}
+			} else {
+				waitForOutbox(t)
+				if _, err := os.Stat(entryPath); !os.IsNotExist(err) {
+					t.Fatalf("report.txt still in the outbox (%v)", err)
+				}
+				if queued := queuedOutbox(entryPath); len(queued) != 0 {
+					t.Fatalf("entry still queued as %+v", queued)
+				}
+			}
+
+			info, err := os.Stat(filepath.Join(peerDir, filepath.FromSlash(tt.want)))
+			if err != nil {
+				t.Fatalf("entry not filed as %s: %v", tt.want, err)
+			}
+			if info.Size() != 100 {
+				t.Fatalf("%s holds %d bytes, want the 100 byte entry", tt.want, info.Size())
+			}
+		})
+	}
+}
+
+// waitForQueue waits until no queued transfer is running and one is in state
+func waitForQueue(t *testing.T, state string) {
+	t.Helper()
+
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		queueMutex.Lock()
+		done := !slices.ContainsFunc(transferQueue, func(item *QueuedTransfer) bool { return item.State == QueueRunning }) &&
+			slices.ContainsFunc(transferQueue, func(item *QueuedTransfer) bool { return item.State == state })
+		queueMutex.Unlock()
+		if done {
+			return
+		}
+	}
+	t.Fatalf("no queued transfer came to %s", state)
+}
+
+// closedPort returns a loopback port nothing is listening on
+func closedPort(t *testing.T) int {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+	return port
+}
@@ -0,0 +1,599 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	pb "backend/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TrustedPeer is a peer this node has paired with
+type TrustedPeer struct {
+	PeerID      string `json:"peer_id"`
+	Name        string `json:"name"`
+	Hostname    string `json:"hostname"`
+	IdentityKey string `json:"identity_key"` // base64 Ed25519 public key
+	PairedAt    string `json:"paired_at"`
+}
+
+type TrustedPeersResponse struct {
+	Peers []TrustedPeer `json:"peers"`
+	Count int           `json:"count"`
+}
+
+type PairingPINResponse struct {
+	PIN       string `json:"pin"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type PairRequest struct {
+	PeerID string `json:"peerid"`
+	PIN    string `json:"pin"`
+	Name   string `json:"name"`
+}
+
+type RenameTrustedPeerRequest struct {
+	Name string `json:"name"`
+}
+
+// pairingPIN is the code shown on this device while it waits to be paired
+type pairingPIN struct {
+	Code      string
+	ExpiresAt time.Time
+}
+
+var (
+	trustedPeers = make(map[string]TrustedPeer)
+	trustMutex   sync.RWMutex
+
+	activePIN *pairingPIN
+	pinMutex  sync.Mutex
+)
+
+const (
+	trustedPeersFile = "trusted_peers.json"
+
+	// Digits in a pairing PIN and how long it can be used
+	pairingPINDigits  = 6
+	pairingPINTimeout = 2 * time.Minute
+
+	// How long a pairing exchange may take once started
+	pairingTimeout = 30 * time.Second
+
+	// Longest display name accepted for a trusted peer
+	maxPeerNameLength = 64
+
+	pairingNonceSize = 32
+)
+
+// InitTrustStore loads the paired peers from trusted_peers.json
+func InitTrustStore() {
+	data, err := os.ReadFile(trustedPeersFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading %s: %v", trustedPeersFile, err)
+		}
+		return
+	}
+
+	peers := make(map[string]TrustedPeer)
+	if err := json.Unmarshal(data, &peers); err != nil {
+		log.Printf("Error decoding %s: %v", trustedPeersFile, err)
+		return
+	}
+
+	trustMutex.Lock()
+	trustedPeers = peers
+	trustMutex.Unlock()
+
+	log.Printf("Loaded %d trusted peers", len(peers))
+}
+
+// saveTrustStore writes the paired peers to trusted_peers.json. The caller
+// must hold trustMutex.
+func saveTrustStore() {
+	data, err := json.MarshalIndent(trustedPeers, "", "    ")
+	if err != nil {
+		log.Printf("Error encoding %s: %v", trustedPeersFile, err)
+		return
+	}
+
+	tmpPath := trustedPeersFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err == nil {
+		err = os.Rename(tmpPath, trustedPeersFile)
+	}
+	if err != nil {
+		log.Printf("Error saving %s: %v", trustedPeersFile, err)
+	}
+}
+
+// isTrustedPeer reports whether this node has paired with a peer. Peer IDs
+// are derived from identity keys, so a verified peer ID is enough.
+func isTrustedPeer(peerID string) bool {
+	trustMutex.RLock()
+	defer trustMutex.RUnlock()
+
+	_, ok := trustedPeers[peerID]
+	return ok
+}
+
+// getTrustedPeer returns the record made when pairing with a peer
+func getTrustedPeer(peerID string) (TrustedPeer, bool) {
+	trustMutex.RLock()
+	defer trustMutex.RUnlock()
+
+	trusted, ok := trustedPeers[peerID]
+	return trusted, ok
+}
+
+// trustedIdentityKey returns the identity key recorded when pairing
+func trustedIdentityKey(peerID string) (ed25519.PublicKey, bool) {
+	trustMutex.RLock()
+	trusted, ok := trustedPeers[peerID]
+	trustMutex.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	key, err := base64.StdEncoding.DecodeString(trusted.IdentityKey)
+	if err != nil || len(key) != ed25519.PublicKeySize || peerIDFromKey(key) != peerID {
+		return nil, false
+	}
+	return key, true
+}
+
+// trustPeer adds or replaces a paired peer
+func trustPeer(trusted TrustedPeer) {
+	trustMutex.Lock()
+	defer trustMutex.Unlock()
+
+	trustedPeers[trusted.PeerID] = trusted
+	saveTrustStore()
+
+	log.Printf("Paired with %s (%s)", trusted.Name, trusted.PeerID)
+}
+
+// newPairingPIN replaces any active PIN with a fresh random one
+func newPairingPIN() (pairingPIN, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < pairingPINDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return pairingPIN{}, err
+	}
+
+	pin := pairingPIN{
+		Code:      fmt.Sprintf("%0*d", pairingPINDigits, n),
+		ExpiresAt: time.Now().Add(pairingPINTimeout),
+	}
+
+	pinMutex.Lock()
+	activePIN = &pin
+	pinMutex.Unlock()
+
+	return pin, nil
+}
+
+// takePairingPIN returns the active PIN and clears it. A PIN is good for a
+// single pairing attempt, since the exchange reveals enough to guess it
+// offline.
+func takePairingPIN() (string, bool) {
+	pinMutex.Lock()
+	defer pinMutex.Unlock()
+
+	pin := activePIN
+	activePIN = nil
+
+	if pin == nil || time.Now().After(pin.ExpiresAt) {
+		return "", false
+	}
+	return pin.Code, true
+}
+
+// pairingProof binds a PIN to the identity keys of both ends of a pairing
+// exchange. The role keeps the two sides' proofs distinct.
+func pairingProof(pin, role string, ownKey, otherKey ed25519.PublicKey, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(pin))
+	mac.Write([]byte(role))
+	mac.Write([]byte{0})
+	mac.Write(ownKey)
+	mac.Write(otherKey)
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
+
+// pairingCommitment hides the initiator's proof until the responder has
+// proved it knows the PIN
+func pairingCommitment(nonce, proof []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, nonce...), proof...))
+	return sum[:]
+}
+
+// Pair runs the responder side of a pairing exchange. The initiator first
+// commits to its proof of the PIN, then this node proves the PIN, then the
+// initiator reveals its proof. Neither side learns anything useful about
+// the PIN from a peer that doesn't know it.
+func (s *fileTransferServer) Pair(stream pb.FileTransferService_PairServer) error {
+	peerID, peerKey, ok := authenticatedPeer(stream.Context())
+	if !ok {
+		return status.Error(codes.Unauthenticated, "no client certificate")
+	}
+	ownKey := identityKey.Public().(ed25519.PublicKey)
+
+	hello, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if hello.PeerId != peerID || len(hello.Commitment) != sha256.Size {
+		return status.Error(codes.InvalidArgument, "invalid pairing request")
+	}
+
+	pin, ok := takePairingPIN()
+	if !ok {
+		log.Printf("Pairing request from %s with no active PIN", hello.Hostname)
+		return status.Error(codes.FailedPrecondition, "no pairing PIN is active on this device")
+	}
+
+	systemInfo := GetSystemInfoStruct()
+	err = stream.Send(&pb.PairMessage{
+		PeerId:   systemInfo.PeerID,
+		Hostname: systemInfo.Hostname,
+		Proof:    pairingProof(pin, "responder", ownKey, peerKey, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	reveal, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	expected := pairingProof(pin, "initiator", peerKey, ownKey, reveal.Nonce)
+	if len(reveal.Nonce) != pairingNonceSize || !hmac.Equal(reveal.Proof, expected) ||
+		!bytes.Equal(pairingCommitment(reveal.Nonce, reveal.Proof), hello.Commitment) {
+		log.Printf("Pairing with %s failed: wrong PIN", hello.Hostname)
+		return status.Error(codes.PermissionDenied, "wrong pairing PIN")
+	}
+
+	trustPeer(TrustedPeer{
+		PeerID:      peerID,
+		Name:        truncatePeerName(hello.Hostname),
+		Hostname:    hello.Hostname,
+		IdentityKey: base64.StdEncoding.EncodeToString(peerKey),
+		PairedAt:    time.Now().Format(time.RFC3339),
+	})
+
+	return stream.Send(&pb.PairMessage{Accepted: true})
+}
+
+// pairWithPeer runs the initiator side of a pairing exchange using the PIN
+// shown on the other device
+func pairWithPeer(ctx context.Context, peer *Peer, pin, name string) (TrustedPeer, error) {
+	conn, err := dialPeer(peer)
+	if err != nil {
+		return TrustedPeer{}, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, pairingTimeout)
+	defer cancel()
+
+	stream, err := pb.NewFileTransferServiceClient(conn).Pair(ctx)
+	if err != nil {
+		return TrustedPeer{}, err
+	}
+
+	nonce := make([]byte, pairingNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return TrustedPeer{}, err
+	}
+
+	// The handshake has already checked the key behind the peer's ID
+	_, peerKey, ok := authenticatedPeer(stream.Context())
+	if !ok {
+		return TrustedPeer{}, fmt.Errorf("%s did not present an identity key", peer.Hostname)
+	}
+	ownKey := identityKey.Public().(ed25519.PublicKey)
+	proof := pairingProof(pin, "initiator", ownKey, peerKey, nonce)
+
+	systemInfo := GetSystemInfoStruct()
+	err = stream.Send(&pb.PairMessage{
+		PeerId:     systemInfo.PeerID,
+		Hostname:   systemInfo.Hostname,
+		Commitment: pairingCommitment(nonce, proof),
+	})
+	if err != nil {
+		return TrustedPeer{}, streamRecvError(stream, err)
+	}
+
+	challenge, err := stream.Recv()
+	if err != nil {
+		return TrustedPeer{}, err
+	}
+
+	// Don't reveal anything until the peer has shown it knows the PIN
+	expected := pairingProof(pin, "responder", peerKey, ownKey, nil)
+	if !hmac.Equal(challenge.Proof, expected) {
+		return TrustedPeer{}, status.Error(codes.PermissionDenied, "wrong pairing PIN")
+	}
+
+	if err := stream.Send(&pb.PairMessage{Nonce: nonce, Proof: proof}); err != nil {
+		return TrustedPeer{}, streamRecvError(stream, err)
+	}
+
+	result, err := stream.Recv()
+	if err != nil {
+		return TrustedPeer{}, err
+	}
+	if !result.Accepted {
+		return TrustedPeer{}, fmt.Errorf("%s did not accept the pairing", peer.Hostname)
+	}
+
+	if name == "" {
+		name = peer.Hostname
+	}
+
+	trusted := TrustedPeer{
+		PeerID:      peer.ID,
+		Name:        truncatePeerName(name),
+		Hostname:    peer.Hostname,
+		IdentityKey: base64.StdEncoding.EncodeToString(peerKey),
+		PairedAt:    time.Now().Format(time.RFC3339),
+	}
+	trustPeer(trusted)
+
+	return trusted, nil
+}
+
+// streamRecvError replaces the io.EOF returned by Send when the peer has
+// ended the stream with the status the peer actually reported
+func streamRecvError(stream grpc.ClientStream, err error) error {
+	if err != io.EOF {
+		return err
+	}
+	if recvErr := stream.RecvMsg(&pb.PairMessage{}); recvErr != nil && recvErr != io.EOF {
+		return recvErr
+	}
+	return err
+}
+
+// truncatePeerName trims a display name to a sensible length
+func truncatePeerName(name string) string {
+	name = strings.TrimSpace(name)
+	for len(name) > maxPeerNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// requireTrustedPeer rejects unary calls from peers this node hasn't paired
+// with. Pairing itself is the only call open to everyone.
+func requireTrustedPeer(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkTrustedPeer(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// requireTrustedPeerStream is requireTrustedPeer for streaming calls
+func requireTrustedPeerStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if info.FullMethod != pb.FileTransferService_Pair_FullMethodName {
+		if err := checkTrustedPeer(stream.Context()); err != nil {
+			return err
+		}
+	}
+	return handler(srv, stream)
+}
+
+// checkTrustedPeer checks the caller's certificate against the trust store
+func checkTrustedPeer(ctx context.Context) error {
+	peerID, _, ok := authenticatedPeer(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "no client certificate")
+	}
+	if !isTrustedPeer(peerID) {
+		log.Printf("Rejecting call from unpaired peer %s", peerID)
+		return status.Error(codes.PermissionDenied, "peer is not paired with this device")
+	}
+	return nil
+}
+
+// StartPairing HTTP handler that shows a new PIN for another device to enter
+func StartPairing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pin, err := newPairingPIN()
+	if err != nil {
+		log.Printf("Error generating pairing PIN: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Pairing PIN active until %s", pin.ExpiresAt.Format(time.RFC3339))
+
+	response := PairingPINResponse{
+		PIN:       pin.Code,
+		ExpiresAt: pin.ExpiresAt.Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// HandlePairing HTTP handler that pairs with a peer using the PIN it shows
+func HandlePairing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PairRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.PeerID == "" || req.PIN == "" {
+		http.Error(w, "Missing required fields: peerid and pin", http.StatusBadRequest)
+		return
+	}
+
+	peer := GetPeerByID(req.PeerID)
+	if peer == nil {
+		http.Error(w, "Peer not found", http.StatusNotFound)
+		return
+	}
+
+	trusted, err := pairWithPeer(r.Context(), peer, req.PIN, req.Name)
+	if err != nil {
+		log.Printf("Pairing with %s failed: %v", peer.Hostname, err)
+
+		code := http.StatusBadGateway
+		switch status.Code(err) {
+		case codes.PermissionDenied:
+			code = http.StatusForbidden
+		case codes.FailedPrecondition:
+			code = http.StatusConflict
+		}
+		http.Error(w, "Pairing failed: "+err.Error(), code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trusted)
+}
+
+// GetTrustedPeers HTTP handler that lists paired peers
+func GetTrustedPeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	trustMutex.RLock()
+	peers := make([]TrustedPeer, 0, len(trustedPeers))
+	for _, trusted := range trustedPeers {
+		peers = append(peers, trusted)
+	}
+	trustMutex.RUnlock()
+
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].PairedAt < peers[j].PairedAt
+	})
+
+	response := TrustedPeersResponse{
+		Peers: peers,
+		Count: len(peers),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding trusted peers response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleTrustedPeer HTTP handler that renames (PATCH) or revokes (DELETE)
+// a paired peer
+func HandleTrustedPeer(w http.ResponseWriter, r *http.Request) {
+	peerID := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodPatch:
+		var req RenameTrustedPeerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		name := truncatePeerName(req.Name)
+		if name == "" || !utf8.ValidString(req.Name) {
+			http.Error(w, "Missing required field: name", http.StatusBadRequest)
+			return
+		}
+
+		trusted, err := renameTrustedPeer(peerID, name)
+		if err != nil {
+			http.Error(w, "Trusted peer not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(trusted)
+
+	case http.MethodDelete:
+		if !revokeTrustedPeer(peerID) {
+			http.Error(w, "Trusted peer not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// renameTrustedPeer changes the display name of a paired peer
+func renameTrustedPeer(peerID, name string) (TrustedPeer, error) {
+	trustMutex.Lock()
+	defer trustMutex.Unlock()
+
+	trusted, ok := trustedPeers[peerID]
+	if !ok {
+		return TrustedPeer{}, errors.New("not paired")
+	}
+
+	trusted.Name = name
+	trustedPeers[peerID] = trusted
+	saveTrustStore()
+
+	return trusted, nil
+}
+
+// revokeTrustedPeer removes a paired peer, which stops it pushing files
+func revokeTrustedPeer(peerID string) bool {
+	trustMutex.Lock()
+	defer trustMutex.Unlock()
+
+	if _, ok := trustedPeers[peerID]; !ok {
+		return false
+	}
+
+	delete(trustedPeers, peerID)
+	saveTrustStore()
+
+	log.Printf("Revoked trust in %s", peerID)
+	return true
+}
@@ -0,0 +1,134 @@
+package logic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// withTrustStore gives the test an empty trust store and no active PIN.
+// The store is saved to the current directory, so call it from a scratch
+// directory.
+func withTrustStore(tb testing.TB) {
+	trustMutex.Lock()
+	previousPeers := trustedPeers
+	trustedPeers = make(map[string]TrustedPeer)
+	trustMutex.Unlock()
+
+	pinMutex.Lock()
+	previousPIN := activePIN
+	activePIN = nil
+	pinMutex.Unlock()
+
+	tb.Cleanup(func() {
+		trustMutex.Lock()
+		trustedPeers = previousPeers
+		trustMutex.Unlock()
+
+		pinMutex.Lock()
+		activePIN = previousPIN
+		pinMutex.Unlock()
+	})
+}
+
+func TestPairWithPeer(t *testing.T) {
+	tests := []struct {
+		name     string
+		pin      func(shown string) string // the PIN typed on the initiator
+		noPIN    bool                      // the responder shows no PIN
+		expired  bool
+		wantCode codes.Code
+	}{
+		{name: "correct PIN", pin: func(shown string) string { return shown }},
+		{name: "wrong PIN", pin: func(string) string { return "000000x" }, wantCode: codes.PermissionDenied},
+		{name: "no PIN shown", pin: func(string) string { return "123456" }, noPIN: true, wantCode: codes.FailedPrecondition},
+		{name: "expired PIN", pin: func(shown string) string { return shown }, expired: true, wantCode: codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := testPeer(t)
+			revokeTrustedPeer(peer.ID)
+
+			var shown string
+			if !tt.noPIN {
+				pin, err := newPairingPIN()
+				if err != nil {
+					t.Fatal(err)
+				}
+				shown = pin.Code
+			}
+			if tt.expired {
+				pinMutex.Lock()
+				activePIN.ExpiresAt = time.Now().Add(-time.Second)
+				pinMutex.Unlock()
+			}
+
+			trusted, err := pairWithPeer(context.Background(), peer, tt.pin(shown), "my laptop")
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("pairWithPeer error = %v, want %s", err, tt.wantCode)
+			}
+
+			wantTrusted := tt.wantCode == codes.OK
+			if got := isTrustedPeer(peer.ID); got != wantTrusted {
+				t.Fatalf("paired = %v, want %v", got, wantTrusted)
+			}
+			if wantTrusted && trusted.Name != "my laptop" {
+				t.Fatalf("paired as %q, want %q", trusted.Name, "my laptop")
+			}
+
+			// A PIN is good for one attempt, right or wrong
+			if _, ok := takePairingPIN(); ok {
+				t.Fatal("PIN still active after a pairing attempt")
+			}
+		})
+	}
+}
+
+func TestCheckTrustedPeer(t *testing.T) {
+	t.Chdir(t.TempDir())
+	withTrustStore(t)
+
+	paired, pairedID := fromNewPeer(t, context.Background())
+	trustPeer(TrustedPeer{PeerID: pairedID})
+	unpaired, _ := fromNewPeer(t, context.Background())
+
+	// A certificate naming the paired peer but holding another key
+	impostorKey, _ := newPeerKey(t)
+	impostor := authenticatedAs(t, context.Background(), peerCertificate(t, impostorKey, pairedID, time.Now().Add(-time.Hour)))
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode codes.Code
+	}{
+		{name: "paired", ctx: paired},
+		{name: "no certificate", ctx: context.Background(), wantCode: codes.Unauthenticated},
+		{name: "not paired", ctx: unpaired, wantCode: codes.PermissionDenied},
+		{name: "naming a paired peer with another key", ctx: impostor, wantCode: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTrustedPeer(tt.ctx); status.Code(err) != tt.wantCode {
+				t.Fatalf("checkTrustedPeer = %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRevokedPeerCannotPush(t *testing.T) {
+	peer := testPeer(t)
+	makeTree(t, ".", map[string]int{"notes.txt": 10})
+
+	if !revokeTrustedPeer(peer.ID) {
+		t.Fatal("revokeTrustedPeer found nothing to revoke")
+	}
+
+	if transfer := sendPaths(t, peer, "notes.txt"); transfer.State != TransferFailed {
+		t.Fatalf("transfer from a revoked peer %s, want %s", transfer.State, TransferFailed)
+	}
+}
@@ -0,0 +1,583 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Large files can be split into fixed-size ranges that are sent over several
+// concurrent streams. The receiver preallocates the file, writes each range
+// at its offset and records finished ranges in the partial sidecar, so an
+// interrupted transfer resends only the ranges that did not complete. The
+// whole file is hashed and moved into place once every range is in.
+//
+// This is not a throughput improvement. The streams share the one
+// connection to the peer, and the benchmarks in parallel_test.go show them
+// slower than a single stream over loopback and no faster with a 50ms round
+// trip. Leave streams at 1 unless measurements on the actual link say
+// otherwise.
+
+const (
+	// Concurrent streams a transfer may ask for
+	maxParallelStreams = 16
+
+	// Files smaller than this are always sent over a single stream
+	minParallelFileSize = 16 * 1024 * 1024
+
+	// Ranges are this size unless the file would need too many of them
+	parallelRangeSize    = 8 * 1024 * 1024
+	minParallelRangeSize = 1024 * 1024
+	maxParallelRanges    = 4096
+)
+
+// Serialises sidecar updates from the range streams of a file
+var parallelStateMutex sync.Mutex
+
+// rangeCount returns how many ranges a file is split into
+func rangeCount(fileSize, rangeSize int64) int {
+	return int((fileSize + rangeSize - 1) / rangeSize)
+}
+
+// rangeBounds returns the byte range [start, end) covered by a range
+func rangeBounds(index int, rangeSize, fileSize int64) (int64, int64) {
+	start := int64(index) * rangeSize
+	return start, min(start+rangeSize, fileSize)
+}
+
+// rangeKey identifies one range while a stream is writing it
+func rangeKey(transferID string, index int) string {
+	return fmt.Sprintf("%s.%d", transferID, index)
+}
+
+// rangeSizeFor picks the range size for a file, a whole number of the
+// largest chunks. It depends only on the size so every attempt splits the
+// file the same way.
+func rangeSizeFor(fileSize int64) int64 {
+	rangeSize := int64(parallelRangeSize)
+	if needed := (fileSize + maxParallelRanges - 1) / maxParallelRanges; needed > rangeSize {
+		rangeSize = (needed + maxChunkSize - 1) / maxChunkSize * maxChunkSize
+	}
+	return rangeSize
+}
+
+// checkParallelRequest validates the file a parallel call refers to and
+// returns its sanitized name
+func checkParallelRequest(ctx context.Context, transferID, offerID, name string, fileSize int64) (string, error) {
+	if !isValidTransferID(transferID) {
+		return "", status.Error(codes.InvalidArgument, "invalid transfer ID")
+	}
+	if fileSize <= 0 {
+		return "", status.Error(codes.InvalidArgument, "invalid file size")
+	}
+
+	fileName, err := sanitizeRelativePath(name)
+	if err != nil {
+		log.Printf("Rejecting file name %q: %v", name, err)
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if !isOfferAccepted(ctx, offerID, OfferedFile{Path: fileName, Size: fileSize}) {
+		log.Printf("Rejecting parallel stream for %s: offer %q was not accepted", fileName, offerID)
+		return "", status.Error(codes.PermissionDenied, "transfer was not accepted by the receiver")
+	}
+
+	return fileName, nil
+}
+
+// loadParallelState reads the sidecar of a parallel transfer, returning nil
+// if there is none for this file
+func loadParallelState(transferID, fileName string, fileSize int64) *partialState {
+	parallelStateMutex.Lock()
+	defer parallelStateMutex.Unlock()
+
+	state := loadPartialState(transferID)
+	if state == nil || state.RangeSize == 0 || state.FileName != fileName || state.FileSize != fileSize {
+		return nil
+	}
+	return state
+}
+
+// BeginParallel prepares to receive a file as ranges, preallocating the
+// partial file on first use, and reports which ranges are already held
+func (s *fileTransferServer) BeginParallel(ctx context.Context, req *pb.ParallelRequest) (*pb.ParallelStatus, error) {
+	fileName, err := checkParallelRequest(ctx, req.TransferId, req.OfferId, req.FileName, req.FileSize)
+	if err != nil {
+		return nil, err
+	}
+	if req.RangeSize < minParallelRangeSize || rangeCount(req.FileSize, req.RangeSize) > maxParallelRanges {
+		return nil, status.Error(codes.InvalidArgument, "invalid range size")
+	}
+
+	if !claimReceive(req.TransferId) {
+		return nil, status.Error(codes.Aborted, "transfer already in progress")
+	}
+	defer releaseReceive(req.TransferId)
+
+	if err := os.MkdirAll(filepath.Join(downloadsDir, partialDir), 0755); err != nil {
+		log.Printf("Error creating downloads directory: %v", err)
+		return nil, status.Errorf(codes.Internal, "failed to create downloads directory: %v", err)
+	}
+
+	parallelStateMutex.Lock()
+	defer parallelStateMutex.Unlock()
+
+	dataPath, _ := partialPaths(req.TransferId)
+	count := rangeCount(req.FileSize, req.RangeSize)
+
+	// Keep ranges from an earlier attempt only if they were cut the same way
+	state := loadPartialState(req.TransferId)
+	if state != nil {
+		info, statErr := os.Stat(dataPath)
+		if state.FileName != fileName || state.FileSize != req.FileSize || state.RangeSize != req.RangeSize ||
+			len(state.RangesDone) != count || statErr != nil || info.Size() != req.FileSize {
+			log.Printf("Discarding mismatched partial data for transfer %s", req.TransferId)
+			removePartial(req.TransferId)
+			state = nil
+		}
+	}
+
+	if state == nil {
+		file, err := os.OpenFile(dataPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to create partial file: %v", err)
+		}
+		err = preallocateFile(file, req.FileSize)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			removePartial(req.TransferId)
+			return nil, status.Errorf(codes.Internal, "failed to preallocate %d bytes: %v", req.FileSize, err)
+		}
+
+		state = &partialState{
+			TransferID: req.TransferId,
+			FileName:   fileName,
+			FileSize:   req.FileSize,
+			RangeSize:  req.RangeSize,
+			RangesDone: make([]bool, count),
+		}
+		log.Printf("Receiving %s as %d ranges of %d bytes", fileName, count, req.RangeSize)
+	} else {
+		log.Printf("Resuming %s with %d of %d bytes in finished ranges", fileName, state.BytesReceived, state.FileSize)
+	}
+
+	state.OfferID = req.OfferId
+	if err := savePartialState(state); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to save partial state: %v", err)
+	}
+	publishIncomingFile(state, "receiving", nil)
+
+	return &pb.ParallelStatus{RangesDone: state.RangesDone}, nil
+}
+
+// SendRange receives one range of a file started with BeginParallel and
+// writes it in place. The range counts once the stream has delivered all
+// of it.
+func (s *fileTransferServer) SendRange(stream pb.FileTransferService_SendRangeServer) error {
+	chunk, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "no file data received")
+	}
+	if err != nil {
+		return err
+	}
+
+	fileName, err := checkParallelRequest(stream.Context(), chunk.TransferId, chunk.OfferId, chunk.FileName, chunk.FileSize)
+	if err != nil {
+		return err
+	}
+
+	state := loadParallelState(chunk.TransferId, fileName, chunk.FileSize)
+	if state == nil {
+		return status.Error(codes.FailedPrecondition, "no parallel transfer in progress")
+	}
+
+	if chunk.Offset < 0 || chunk.Offset >= state.FileSize || chunk.Offset%state.RangeSize != 0 {
+		return status.Errorf(codes.InvalidArgument, "offset %d is not the start of a range", chunk.Offset)
+	}
+	index := int(chunk.Offset / state.RangeSize)
+	start, end := rangeBounds(index, state.RangeSize, state.FileSize)
+
+	key := rangeKey(state.TransferID, index)
+	if !claimReceive(key) {
+		return status.Error(codes.Aborted, "range already in progress")
+	}
+	defer releaseReceive(key)
+
+	// Each stream of an encrypted transfer brings its own key
+	var opener *chunkCipher
+	if chunk.Encryption != nil {
+		opener, err = newReceiveCipher(chunk.Encryption, chunk.TransferId, chunk.OfferId)
+		if err != nil {
+			log.Printf("Rejecting encrypted range of %s: %v", fileName, err)
+			return err
+		}
+	}
+
+	dataPath, _ := partialPaths(state.TransferID)
+	file, err := os.OpenFile(dataPath, os.O_WRONLY, 0)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "failed to open partial file: %v", err)
+	}
+	defer file.Close()
+
+	peerID, _, _ := authenticatedPeer(stream.Context())
+	offset := start
+	var inflated []byte
+
+	for {
+		if err := throttle(stream.Context(), rateDownload, peerID, len(chunk.Data)); err != nil {
+			return err
+		}
+
+		if chunk.Offset != offset {
+			return status.Errorf(codes.InvalidArgument,
+				"chunk %d at offset %d, expected %d", chunk.ChunkNumber, chunk.Offset, offset)
+		}
+		if checksum := crc32.Checksum(chunk.Data, crc32cTable); checksum != chunk.Crc32C {
+			log.Printf("Chunk %d for %s failed checksum (got %08x, want %08x)",
+				chunk.ChunkNumber, fileName, checksum, chunk.Crc32C)
+			return status.Errorf(codes.DataLoss, "chunk %d failed checksum", chunk.ChunkNumber)
+		}
+
+		data := chunk.Data
+		if opener != nil {
+			data, err = opener.open(chunk)
+			if err != nil {
+				log.Printf("Chunk %d for %s failed authentication", chunk.ChunkNumber, fileName)
+				return status.Errorf(codes.DataLoss, "chunk %d failed authentication", chunk.ChunkNumber)
+			}
+		} else if chunk.Encryption != nil {
+			return status.Error(codes.InvalidArgument, "encryption header after the first chunk")
+		}
+		if data, err = inflateChunk(chunk, data, inflated); err != nil {
+			return err
+		}
+		if chunk.Compression != "" {
+			inflated = data
+		}
+
+		if offset+int64(len(data)) > end {
+			return status.Errorf(codes.InvalidArgument, "chunk %d runs past the end of its range", chunk.ChunkNumber)
+		}
+		if _, err := file.WriteAt(data, offset); err != nil {
+			log.Printf("Error writing to file: %v", err)
+			return status.Errorf(codes.Internal, "failed to write file: %v", err)
+		}
+		offset += int64(len(data))
+
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error receiving range %d of %s: %v", index, fileName, err)
+			return err
+		}
+	}
+
+	if offset != end {
+		return status.Errorf(codes.FailedPrecondition,
+			"incomplete range: received %d of %d bytes", offset-start, end-start)
+	}
+
+	// Record the range against the latest sidecar, which other streams update
+	parallelStateMutex.Lock()
+	state = loadPartialState(state.TransferID)
+	if state != nil && state.RangeSize > 0 && index < len(state.RangesDone) && !state.RangesDone[index] {
+		state.RangesDone[index] = true
+		state.BytesReceived += end - start
+		err = savePartialState(state)
+	}
+	parallelStateMutex.Unlock()
+
+	if state == nil {
+		return status.Error(codes.FailedPrecondition, "parallel transfer was discarded")
+	}
+	if err != nil {
+		log.Printf("Error saving partial state for %s: %v", state.TransferID, err)
+		return status.Errorf(codes.Internal, "failed to save partial state: %v", err)
+	}
+	publishIncomingFile(state, "receiving", nil)
+
+	return stream.SendAndClose(&pb.RangeResponse{BytesReceived: end - start})
+}
+
+// FinishParallel verifies a file whose ranges have all arrived and moves it
+// into the downloads directory
+func (s *fileTransferServer) FinishParallel(ctx context.Context, req *pb.ParallelFinish) (*pb.FileTransferResponse, error) {
+	fileName, err := checkParallelRequest(ctx, req.TransferId, req.OfferId, req.FileName, req.FileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claimReceive(req.TransferId) {
+		return nil, status.Error(codes.Aborted, "transfer already in progress")
+	}
+
+	state := loadParallelState(req.TransferId, fileName, req.FileSize)
+	if state == nil {
+		releaseReceive(req.TransferId)
+		return nil, status.Error(codes.FailedPrecondition, "no parallel transfer in progress")
+	}
+	for index, done := range state.RangesDone {
+		if !done {
+			releaseReceive(req.TransferId)
+			return nil, status.Errorf(codes.FailedPrecondition, "range %d has not arrived", index)
+		}
+	}
+
+	// Ranges arrive out of order, so the digest is taken over the finished file
+	// Opened for writing so the data can be flushed before it is moved
+	dataPath, _ := partialPaths(req.TransferId)
+	file, err := os.OpenFile(dataPath, os.O_RDWR, 0)
+	if err == nil {
+		state.hash = sha256.New()
+		_, err = io.Copy(state.hash, file)
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		releaseReceive(req.TransferId)
+		return nil, status.Errorf(codes.Internal, "failed to hash partial file: %v", err)
+	}
+
+	receiver := &fileReceiver{
+		state:          state,
+		file:           file,
+		expectedDigest: req.Sha256,
+		metadata:       fileMetadata{Mode: req.Mode, ModTime: req.Mtime},
+	}
+	return receiver.complete()
+}
+
+// useParallel reports whether the file goes out as ranges over several streams
+func (o *outgoingFile) useParallel() bool {
+	return o.transfer.Streams > 1 && !o.transfer.Delta && o.entry.Size >= minParallelFileSize
+}
+
+// attemptParallel sends the ranges the receiver lacks over concurrent
+// streams, hashing the file alongside, then asks the receiver to verify it
+func (o *outgoingFile) attemptParallel(ctx context.Context) error {
+	fileName := o.entry.RelPath
+	fileSize := o.entry.Size
+	rangeSize := rangeSizeFor(fileSize)
+	count := rangeCount(fileSize, rangeSize)
+
+	beginStart := time.Now()
+	progress, err := o.client.BeginParallel(ctx, &pb.ParallelRequest{
+		TransferId: o.transferID,
+		OfferId:    o.offerID,
+		FileName:   fileName,
+		FileSize:   fileSize,
+		RangeSize:  rangeSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start parallel transfer: %w", err)
+	}
+	rtt := time.Since(beginStart)
+	if len(progress.RangesDone) != count {
+		return fmt.Errorf("receiver reported %d ranges, expected %d", len(progress.RangesDone), count)
+	}
+
+	var pending []int
+	var bytesHeld int64
+	for index, done := range progress.RangesDone {
+		if done {
+			start, end := rangeBounds(index, rangeSize, fileSize)
+			bytesHeld += end - start
+		} else {
+			pending = append(pending, index)
+		}
+	}
+
+	streams := min(o.transfer.Streams, len(pending))
+	if bytesHeld > 0 {
+		log.Printf("Resuming file %s to %s with %d of %d ranges over %d streams", fileName, o.peer.Hostname, len(pending), count, streams)
+	} else {
+		log.Printf("Sending file %s to %s (%d bytes, %d ranges over %d streams)", fileName, o.peer.Hostname, fileSize, count, streams)
+	}
+	o.transfer.resetProgress(bytesHeld)
+
+	// The whole file is hashed while the ranges go out
+	digests := make(chan string, 1)
+	hashErrors := make(chan error, 1)
+	go func() {
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, io.NewSectionReader(o.file, 0, fileSize)); err != nil {
+			hashErrors <- err
+			return
+		}
+		digests <- hex.EncodeToString(hasher.Sum(nil))
+	}()
+
+	var progressMutex sync.Mutex
+	bytesSent := bytesHeld
+	addProgress := func(n int64) {
+		progressMutex.Lock()
+		defer progressMutex.Unlock()
+
+		bytesSent += n
+		o.transfer.setProgress(bytesSent)
+	}
+
+	// The first failing stream stops the others
+	rangeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int, len(pending))
+	for _, index := range pending {
+		jobs <- index
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	var firstErr error
+	var errOnce sync.Once
+	for range streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				if err := o.sendRange(rangeCtx, index, rangeSize, rtt, addProgress); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Waiting for the local hash and for the receiver to hash and flush the
+	// whole file moves no bytes, however long it takes on a slow disk
+	release := o.transfer.holdStall()
+	defer release()
+
+	var digest string
+	select {
+	case digest = <-digests:
+	case err := <-hashErrors:
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to hash file: %v", err)
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	response, err := o.client.FinishParallel(ctx, &pb.ParallelFinish{
+		TransferId: o.transferID,
+		OfferId:    o.offerID,
+		FileName:   fileName,
+		FileSize:   fileSize,
+		Sha256:     digest,
+		Mode:       o.entry.Metadata.Mode,
+		Mtime:      o.entry.Metadata.ModTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to finish parallel transfer: %w", err)
+	}
+
+	return o.checkResponse(response, digest)
+}
+
+// sendRange streams one range of the file on its own stream
+func (o *outgoingFile) sendRange(ctx context.Context, index int, rangeSize int64, rtt time.Duration, addProgress func(int64)) error {
+	fileSize := o.entry.Size
+	start, end := rangeBounds(index, rangeSize, fileSize)
+	totalChunks := (fileSize + initialChunkSize - 1) / initialChunkSize
+
+	stream, err := o.client.SendRange(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create stream: %w", err)
+	}
+
+	var sealer *chunkCipher
+	var header *pb.EncryptionHeader
+	if o.recipientKey != nil {
+		sealer, header, err = newSendCipher(o.recipientKey, o.peer.ID, o.transferID, o.offerID)
+		if err != nil {
+			return fmt.Errorf("failed to set up encryption: %v", err)
+		}
+	}
+
+	buffer := getChunkBuffer()
+	defer putChunkBuffer(buffer)
+	packBuffer := getChunkBuffer()
+	defer putChunkBuffer(packBuffer)
+	sealBuffer := getChunkBuffer()
+	defer putChunkBuffer(sealBuffer)
+
+	// Numbering from the smallest chunk size keeps ranges' numbers apart
+	reader := io.NewSectionReader(o.file, start, end-start)
+	sizer := newChunkSizer(rtt)
+	chunkNumber := start / minChunkSize
+	offset := start
+
+	for {
+		bytesRead, err := reader.Read((*buffer)[:sizer.next()])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file: %v", err)
+		}
+
+		chunkNumber++
+		data := (*buffer)[:bytesRead]
+		chunk := &pb.FileChunk{
+			FileName:    o.entry.RelPath,
+			ChunkNumber: chunkNumber,
+			TotalChunks: totalChunks,
+			TransferId:  o.transferID,
+			Offset:      offset,
+			FileSize:    fileSize,
+			OfferId:     o.offerID,
+		}
+		o.compress(chunk, data, *packBuffer)
+		if sealer != nil {
+			sealer.seal(chunk, chunk.Data, *sealBuffer)
+			chunk.Encryption, header = header, nil
+		}
+		chunk.Crc32C = crc32.Checksum(chunk.Data, crc32cTable)
+
+		if err := throttleTransfer(ctx, o.transfer, rateUpload, o.peer.ID, len(chunk.Data)); err != nil {
+			return err
+		}
+		if err := stream.Send(chunk); err != nil {
+			if err == io.EOF {
+				if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+					err = recvErr
+				}
+			}
+			return fmt.Errorf("failed to send chunk %d: %w", chunkNumber, err)
+		}
+
+		offset += int64(bytesRead)
+		addProgress(int64(bytesRead))
+		sizer.record(bytesRead)
+	}
+
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return fmt.Errorf("failed to finish range %d: %w", index, err)
+	}
+	return nil
+}
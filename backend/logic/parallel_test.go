@@ -0,0 +1,138 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchmarkFileSize is large enough to be split into several ranges
+const benchmarkFileSize = 64 * 1024 * 1024
+
+// quietLogs drops log output for the rest of a benchmark, which would
+// otherwise log every chunk of every transfer
+func quietLogs(b *testing.B) {
+	output := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(output) })
+}
+
+// latencyListener delays everything its connections write by a round
+// trip, the way a distant link delays the acknowledgements and flow
+// control updates a sender waits for
+type latencyListener struct {
+	net.Listener
+	rtt time.Duration
+}
+
+func (l *latencyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &latencyConn{Conn: conn, rtt: l.rtt, writes: make(chan delayedWrite, 4096), done: make(chan struct{})}
+	go c.deliver()
+	return c, nil
+}
+
+// delayedWrite is data written to a latencyConn and when it may arrive
+type delayedWrite struct {
+	data []byte
+	at   time.Time
+}
+
+// latencyConn queues writes and delivers each a round trip later, so data
+// keeps flowing while it is in flight
+type latencyConn struct {
+	net.Conn
+	rtt       time.Duration
+	writes    chan delayedWrite
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *latencyConn) Write(p []byte) (int, error) {
+	select {
+	case c.writes <- delayedWrite{data: slices.Clone(p), at: time.Now().Add(c.rtt)}:
+		return len(p), nil
+	case <-c.done:
+		return 0, net.ErrClosed
+	}
+}
+
+func (c *latencyConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+// deliver writes queued data to the connection once its delay is up
+func (c *latencyConn) deliver() {
+	for {
+		select {
+		case w := <-c.writes:
+			time.Sleep(time.Until(w.at))
+			if _, err := c.Conn.Write(w.data); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// benchmarkPeer serves this node as a peer a round trip of rtt away, with
+// logging turned off, receiving every iteration's file over the last copy
+func benchmarkPeer(b *testing.B, rtt time.Duration) *Peer {
+	quietLogs(b)
+	testNode(b)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	peer := servePeer(b, &latencyListener{Listener: lis, rtt: rtt})
+
+	withConfig(b, func(c *Config) { c.CollisionPolicy = collisionOverwrite })
+	return peer
+}
+
+// benchmarkSend measures sending one large file over the given number of
+// streams to a peer a round trip of rtt away
+func benchmarkSend(b *testing.B, streams int, rtt time.Duration) {
+	peer := benchmarkPeer(b, rtt)
+
+	data := make([]byte, benchmarkFileSize)
+	rand.Read(data)
+	if err := os.WriteFile("bench.bin", data, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(benchmarkFileSize)
+	for b.Loop() {
+		transfer := newTransfer(peer, []string{"bench.bin"}, transferOptions{streams: streams})
+		if err := sendFileToP2P(context.Background(), peer, transfer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Over loopback one stream already keeps up with the disk, so more streams
+// only add overhead
+func BenchmarkSendSingleStream(b *testing.B) { benchmarkSend(b, 1, 0) }
+func BenchmarkSendParallel2(b *testing.B)    { benchmarkSend(b, 2, 0) }
+func BenchmarkSendParallel4(b *testing.B)    { benchmarkSend(b, 4, 0) }
+func BenchmarkSendParallel8(b *testing.B)    { benchmarkSend(b, 8, 0) }
+
+// A distant link, which parallel streams are usually meant for
+func BenchmarkSendSingleStreamRTT50ms(b *testing.B) { benchmarkSend(b, 1, 50*time.Millisecond) }
+func BenchmarkSendParallel4RTT50ms(b *testing.B)    { benchmarkSend(b, 4, 50*time.Millisecond) }
+func BenchmarkSendParallel8RTT50ms(b *testing.B)    { benchmarkSend(b, 8, 50*time.Millisecond) }
@@ -2,10 +2,13 @@ package logic
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"github.com/grandcat/zeroconf"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +23,12 @@ type Peer struct {
 	RAM      string `json:"ram"`
 	OS       string `json:"os"`
 	Status   string `json:"status"`
+
+	// Whether the peer proved it holds the key behind its peer ID
+	Verified bool `json:"verified"`
+
+	// Whether this device has paired with the peer
+	Trusted bool `json:"trusted"`
 }
 
 type PeersResponse struct {
@@ -38,6 +47,9 @@ const (
 	serviceType = "_p2pfileshare._tcp"
 	domain      = "local."
 	grpcPort    = 9002
+
+	// How long to wait for a peer to prove its identity
+	peerVerifyTimeout = 5 * time.Second
 )
 
 // StartPeerDiscovery initializes mDNS service registration and peer browsing
@@ -159,6 +171,9 @@ func discoverPeers() {
 			}
 		}
 
+		// Anyone can advertise any peer ID, so check who actually answers
+		verifyPeers(tempPeers)
+
 		// Update peers list after processing all entries
 		updatePeersList(tempPeers)
 		log.Printf("Entry processing finished. Total entries: %d, Valid peers: %d", entryCount, len(tempPeers))
@@ -235,10 +250,15 @@ func isOwnPeer(peerID string) bool {
 	return peerID == systemInfo.PeerID
 }
 
-// updatePeersList safely updates the discovered peers list
+// updatePeersList safely updates the discovered peers list and publishes
+// join/leave events for peers that appeared or disappeared
 func updatePeersList(newPeers []Peer) {
 	peersMutex.Lock()
-	defer peersMutex.Unlock()
+
+	previous := make(map[string]Peer, len(discoveredPeers))
+	for _, peer := range discoveredPeers {
+		previous[peer.ID] = peer
+	}
 
 	discoveredPeers = newPeers
 
@@ -246,6 +266,76 @@ func updatePeersList(newPeers []Peer) {
 	for i := range discoveredPeers {
 		discoveredPeers[i].Status = "online"
 	}
+
+	var joined []Peer
+	for _, peer := range discoveredPeers {
+		if _, ok := previous[peer.ID]; !ok {
+			joined = append(joined, peer)
+		}
+		delete(previous, peer.ID)
+	}
+
+	peersMutex.Unlock()
+
+	for _, peer := range joined {
+		publishEvent(EventPeerJoined, peer)
+	}
+	for _, peer := range previous {
+		peer.Status = "offline"
+		publishEvent(EventPeerLeft, peer)
+	}
+}
+
+// verifyPeers checks that each discovered peer holds the identity key
+// behind its advertised peer ID by completing a TLS handshake with it.
+// Peers already verified at the same address are not checked again.
+func verifyPeers(peers []Peer) {
+	key := func(peer Peer) string {
+		return peer.ID + "@" + net.JoinHostPort(peer.IP, strconv.Itoa(peer.Port))
+	}
+
+	peersMutex.RLock()
+	verified := make(map[string]bool, len(discoveredPeers))
+	for _, peer := range discoveredPeers {
+		if peer.Verified {
+			verified[key(peer)] = true
+		}
+	}
+	peersMutex.RUnlock()
+
+	var wg sync.WaitGroup
+	for i := range peers {
+		peer := &peers[i]
+		if verified[key(*peer)] {
+			peer.Verified = true
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := verifyPeerIdentity(peer); err != nil {
+				log.Printf("Could not verify peer %s (%s): %v", peer.Hostname, peer.ID, err)
+				return
+			}
+			peer.Verified = true
+		}()
+	}
+	wg.Wait()
+}
+
+// verifyPeerIdentity completes a TLS handshake with a peer's gRPC server,
+// which only succeeds if it holds the key its peer ID was derived from
+func verifyPeerIdentity(peer *Peer) error {
+	dialer := &net.Dialer{Timeout: peerVerifyTimeout}
+	addr := net.JoinHostPort(peer.IP, strconv.Itoa(peer.Port))
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, clientTLSConfig(peer))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
 }
 
 // GetPeers HTTP handler that returns the list of discovered peers
@@ -270,6 +360,10 @@ func GetPeers(w http.ResponseWriter, r *http.Request) {
 	copy(currentPeers, discoveredPeers)
 	peersMutex.RUnlock()
 
+	for i := range currentPeers {
+		currentPeers[i].Trusted = currentPeers[i].Verified && isTrustedPeer(currentPeers[i].ID)
+	}
+
 	response := PeersResponse{
 		Peers: currentPeers,
 		Count: len(currentPeers),
@@ -286,13 +380,14 @@ func GetPeers(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Returned %d peers to client", len(currentPeers))
 }
 
-// GetPeerByID finds a peer by their ID (for internal use)
+// GetPeerByID finds a verified peer by their ID (for internal use). Peers
+// that could not prove their ID are never returned.
 func GetPeerByID(peerID string) *Peer {
 	peersMutex.RLock()
 	defer peersMutex.RUnlock()
 
 	for i := range discoveredPeers {
-		if discoveredPeers[i].ID == peerID {
+		if discoveredPeers[i].ID == peerID && discoveredPeers[i].Verified {
 			return &discoveredPeers[i]
 		}
 	}
@@ -0,0 +1,124 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Received files are staged under the hidden partial directory and only
+// renamed into the downloads directory once they are complete, verified and
+// flushed to disk. A name that is already taken there is resolved by the
+// collision policy.
+
+// Collision policies for a received entry whose name is already taken
+const (
+	collisionRename    = "rename"    // store the new one as "name (1).ext"
+	collisionOverwrite = "overwrite" // replace the existing one
+	collisionSkip      = "skip"      // keep the existing one and drop the new one
+	collisionVersion   = "version"   // move the existing one to "name.~1~" first
+)
+
+// Serialises placing received entries, so two streams finishing with the
+// same name can't both claim it
+var placementMutex sync.Mutex
+
+// collisionPolicy returns the configured policy, renaming when it is unset
+// or unknown
+func collisionPolicy() string {
+	switch policy := GetConfig().CollisionPolicy; policy {
+	case collisionOverwrite, collisionSkip, collisionVersion:
+		return policy
+	default:
+		return collisionRename
+	}
+}
+
+// uniquePath returns filePath, or "name (n).ext" with the lowest n that does
+// not exist yet
+func uniquePath(filePath string) string {
+	if _, err := os.Lstat(filePath); errors.Is(err, fs.ErrNotExist) {
+		return filePath
+	}
+
+	ext := filepath.Ext(filePath)
+	base := strings.TrimSuffix(filePath, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// versionPath returns "name.~n~" with the lowest n that does not exist yet,
+// as numbered backups are named
+func versionPath(filePath string) string {
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s.~%d~", filePath, n)
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// resolveCollision returns where a received entry meant for filePath goes
+// under policy, moving the existing entry aside when versioning, or "" if
+// the existing entry is kept. Callers hold placementMutex until the entry
+// is in place.
+func resolveCollision(filePath, policy string) (string, error) {
+	if _, err := os.Lstat(filePath); errors.Is(err, fs.ErrNotExist) {
+		return filePath, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	switch policy {
+	case collisionOverwrite:
+		return filePath, nil
+	case collisionSkip:
+		return "", nil
+	case collisionVersion:
+		if err := os.Rename(filePath, versionPath(filePath)); err != nil {
+			return "", err
+		}
+		return filePath, nil
+	default:
+		return uniquePath(filePath), nil
+	}
+}
+
+// placeReceivedFile renames a verified, flushed file from the staging area
+// to filePath under policy, returning where it was stored, or "" if the
+// existing file was kept and the staged one is left for the caller to remove
+func placeReceivedFile(stagedPath, filePath, policy string) (string, error) {
+	placementMutex.Lock()
+	defer placementMutex.Unlock()
+
+	storedPath, err := resolveCollision(filePath, policy)
+	if err != nil || storedPath == "" {
+		return "", err
+	}
+
+	if err := os.Rename(stagedPath, storedPath); err != nil {
+		return "", err
+	}
+	syncDir(filepath.Dir(storedPath))
+
+	return storedPath, nil
+}
+
+// syncDir flushes changes to a directory's entries, such as a rename, to
+// disk. Not every platform can sync a directory, so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,139 @@
+package logic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlaceReceivedFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		existing []string          // files already in the downloads directory
+		want     string            // where the received file is stored, "" if dropped
+		wantDir  map[string]string // every file afterwards and its contents
+	}{
+		{
+			name: "free name", policy: collisionRename,
+			want:    "report.txt",
+			wantDir: map[string]string{"report.txt": "new"},
+		},
+		{
+			name: "rename", policy: collisionRename, existing: []string{"report.txt"},
+			want:    "report (1).txt",
+			wantDir: map[string]string{"report.txt": "old report.txt", "report (1).txt": "new"},
+		},
+		{
+			name: "rename past taken numbers", policy: collisionRename, existing: []string{"report.txt", "report (1).txt"},
+			want:    "report (2).txt",
+			wantDir: map[string]string{"report.txt": "old report.txt", "report (1).txt": "old report (1).txt", "report (2).txt": "new"},
+		},
+		{
+			name: "overwrite", policy: collisionOverwrite, existing: []string{"report.txt"},
+			want:    "report.txt",
+			wantDir: map[string]string{"report.txt": "new"},
+		},
+		{
+			name: "skip", policy: collisionSkip, existing: []string{"report.txt"},
+			want:    "",
+			wantDir: map[string]string{"report.txt": "old report.txt"},
+		},
+		{
+			name: "skip free name", policy: collisionSkip,
+			want:    "report.txt",
+			wantDir: map[string]string{"report.txt": "new"},
+		},
+		{
+			name: "version", policy: collisionVersion, existing: []string{"report.txt"},
+			want:    "report.txt",
+			wantDir: map[string]string{"report.txt": "new", "report.txt.~1~": "old report.txt"},
+		},
+		{
+			name: "version past taken numbers", policy: collisionVersion, existing: []string{"report.txt", "report.txt.~1~"},
+			want:    "report.txt",
+			wantDir: map[string]string{"report.txt": "new", "report.txt.~1~": "old report.txt.~1~", "report.txt.~2~": "old report.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			downloads := filepath.Join(dir, "downloads")
+			if err := os.Mkdir(downloads, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(downloads, name), []byte("old "+name), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			staged := filepath.Join(dir, "staged")
+			if err := os.WriteFile(staged, []byte("new"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := placeReceivedFile(staged, filepath.Join(downloads, "report.txt"), tt.policy)
+			if err != nil {
+				t.Fatalf("placeReceivedFile: %v", err)
+			}
+
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(downloads, tt.want)
+			}
+			if stored != want {
+				t.Fatalf("stored at %q, want %q", stored, want)
+			}
+
+			// A dropped file is left staged for the caller to remove
+			if _, err := os.Stat(staged); (err == nil) != (tt.want == "") {
+				t.Fatalf("staged file left behind = %v, want %v", err == nil, tt.want == "")
+			}
+
+			entries, err := os.ReadDir(downloads)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+
+				data, err := os.ReadFile(filepath.Join(downloads, entry.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wantData, ok := tt.wantDir[entry.Name()]; !ok || string(data) != wantData {
+					t.Fatalf("%s holds %q, want %q", entry.Name(), data, wantData)
+				}
+			}
+			if len(names) != len(tt.wantDir) {
+				t.Fatalf("downloads holds %q, want %d files", names, len(tt.wantDir))
+			}
+		})
+	}
+}
+
+func TestCollisionPolicy(t *testing.T) {
+	tests := []struct {
+		setting string
+		want    string
+	}{
+		{setting: "", want: collisionRename},
+		{setting: "rename", want: collisionRename},
+		{setting: "overwrite", want: collisionOverwrite},
+		{setting: "skip", want: collisionSkip},
+		{setting: "version", want: collisionVersion},
+		{setting: "replace", want: collisionRename},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.setting, func(t *testing.T) {
+			withConfig(t, func(c *Config) { c.CollisionPolicy = tt.setting })
+
+			if got := collisionPolicy(); got != tt.want {
+				t.Fatalf("collisionPolicy() with %q = %q, want %q", tt.setting, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package logic
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// preallocateFile reserves size bytes for a file so ranges written out of
+// order neither fragment it nor run out of disk halfway through
+func preallocateFile(file *os.File, size int64) error {
+	err := syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		// Not every filesystem can reserve space; a sparse file still works
+		return file.Truncate(size)
+	}
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package logic
+
+import "os"
+
+// preallocateFile sizes a file so ranges can be written at any offset
+func preallocateFile(file *os.File, size int64) error {
+	return file.Truncate(size)
+}
@@ -0,0 +1,504 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Queued transfer states
+const (
+	QueuePending     = "pending"          // waiting for its next attempt
+	QueueWaitingPeer = "waiting_for_peer" // due, but the peer is not online
+	QueueRunning     = "running"
+	QueueGaveUp      = "gave_up" // out of automatic retries; retry by hand
+)
+
+// QueuedTransfer is an outbound transfer that is retried until it succeeds,
+// is cancelled or dropped, or runs out of attempts. Its ID is the ID of the
+// transfer made for its first attempt; each retry is a new transfer.
+type QueuedTransfer struct {
+	ID          string   `json:"id"`
+	PeerID      string   `json:"peer_id"`
+	Peer        string   `json:"peer"`
+	Paths       []string `json:"paths"`
+	Encrypt     bool     `json:"encrypt"`
+	Delta       bool     `json:"delta"`
+	Streams     int      `json:"streams,omitempty"`
+	State       string   `json:"state"`
+	Attempts    int      `json:"attempts"`
+	NextAttempt string   `json:"next_attempt,omitempty"`
+	LastError   string   `json:"last_error,omitempty"`
+	TransferID  string   `json:"transfer_id,omitempty"` // the latest attempt
+	CreatedAt   string   `json:"created_at"`
+
+	// The outbox entry this sends, filed under sent/ or failed/ once the
+	// transfer leaves the queue
+	Outbox string `json:"outbox,omitempty"`
+
+	transfer *Transfer // the attempt that is queued or running, if any
+}
+
+type QueueResponse struct {
+	Queue []QueuedTransfer `json:"queue"`
+	Count int              `json:"count"`
+}
+
+const transferQueueFile = "transfer_queue.json"
+
+const (
+	// Attempts made before a queued transfer is left for a manual retry
+	maxQueueAttempts = 10
+
+	// Delay before the first retry, doubled after every failed attempt
+	queueRetryBase = 5 * time.Second
+	queueRetryMax  = 10 * time.Minute
+
+	// How often waiting transfers are checked against the discovered peers
+	queuePollInterval = time.Second
+)
+
+var (
+	errQueuedTransferNotFound = errors.New("queued transfer not found")
+	errQueuedTransferRunning  = errors.New("queued transfer is running")
+)
+
+var (
+	transferQueue []*QueuedTransfer
+	queueMutex    sync.Mutex
+	queueWake     = make(chan struct{}, 1)
+)
+
+// InitTransferQueue loads the transfers left queued by the last run
+func InitTransferQueue() {
+	data, err := os.ReadFile(transferQueueFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading %s: %v", transferQueueFile, err)
+		}
+		return
+	}
+
+	var queue []*QueuedTransfer
+	if err := json.Unmarshal(data, &queue); err != nil {
+		log.Printf("Error decoding %s: %v", transferQueueFile, err)
+		return
+	}
+
+	// Attempts cut short by the restart are tried again
+	for _, item := range queue {
+		if item.State == QueueRunning || item.State == QueueWaitingPeer {
+			item.State = QueuePending
+		}
+	}
+
+	queueMutex.Lock()
+	transferQueue = queue
+	queueMutex.Unlock()
+
+	log.Printf("Loaded %d queued transfers", len(queue))
+}
+
+// StartTransferQueue runs queued transfers as they fall due and their peers
+// come online
+func StartTransferQueue() {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		processTransferQueue()
+
+		select {
+		case <-ticker.C:
+		case <-queueWake:
+		}
+	}
+}
+
+// wakeTransferQueue makes the queue look for work without waiting for the
+// next poll
+func wakeTransferQueue() {
+	select {
+	case queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// saveTransferQueue writes the queue to transfer_queue.json. The caller must
+// hold queueMutex.
+func saveTransferQueue() {
+	data, err := json.MarshalIndent(transferQueue, "", "    ")
+	if err != nil {
+		log.Printf("Error encoding %s: %v", transferQueueFile, err)
+		return
+	}
+
+	tmpPath := transferQueueFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err == nil {
+		err = os.Rename(tmpPath, transferQueueFile)
+	}
+	if err != nil {
+		log.Printf("Error saving %s: %v", transferQueueFile, err)
+	}
+}
+
+// enqueueTransfer registers a transfer of paths to a paired peer, who may be
+// offline, and returns the transfer made for its first attempt
+func enqueueTransfer(peerID, hostname string, paths []string, options transferOptions) *Transfer {
+	return queueTransfer(peerID, hostname, paths, options, "")
+}
+
+// queueTransfer is enqueueTransfer for a transfer that may send an outbox
+// entry
+func queueTransfer(peerID, hostname string, paths []string, options transferOptions, outboxPath string) *Transfer {
+	transfer := newTransfer(&Peer{ID: peerID, Hostname: hostname}, paths, options)
+
+	item := &QueuedTransfer{
+		ID:         transfer.ID,
+		PeerID:     peerID,
+		Peer:       hostname,
+		Paths:      paths,
+		Encrypt:    options.encrypt,
+		Delta:      options.delta,
+		Streams:    options.streams,
+		State:      QueuePending,
+		TransferID: transfer.ID,
+		CreatedAt:  transfer.CreatedAt,
+		Outbox:     outboxPath,
+		transfer:   transfer,
+	}
+
+	queueMutex.Lock()
+	transferQueue = append(transferQueue, item)
+	saveTransferQueue()
+	queueMutex.Unlock()
+
+	wakeTransferQueue()
+
+	return transfer
+}
+
+// processTransferQueue starts every due transfer whose peer is online
+func processTransferQueue() {
+	now := time.Now()
+
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	changed := false
+	for _, item := range slices.Clone(transferQueue) {
+		// Cancelled through the transfers API before it got to run
+		if item.transfer != nil && item.transfer.ctx.Err() != nil && item.State != QueueRunning {
+			item.transfer.finish(context.Cause(item.transfer.ctx))
+			log.Printf("Dropping cancelled queued transfer %s", item.ID)
+			removeQueuedTransfer(item.ID)
+			fileQueuedOutbox(item, false)
+			changed = true
+			continue
+		}
+
+		if item.State != QueuePending && item.State != QueueWaitingPeer {
+			continue
+		}
+		if due, err := time.Parse(time.RFC3339, item.NextAttempt); err == nil && now.Before(due) {
+			continue
+		}
+
+		peer := GetPeerByID(item.PeerID)
+		if peer == nil {
+			if item.State != QueueWaitingPeer {
+				log.Printf("Queued transfer %s is waiting for %s to come online", item.ID, item.Peer)
+				item.State = QueueWaitingPeer
+				changed = true
+			}
+			continue
+		}
+
+		transfer := item.transfer
+		if transfer == nil {
+			transfer = newTransfer(peer, item.Paths, transferOptions{encrypt: item.Encrypt, delta: item.Delta, streams: item.Streams})
+			item.transfer = transfer
+			item.TransferID = transfer.ID
+		}
+
+		item.State = QueueRunning
+		changed = true
+		go runQueuedTransfer(item, transfer, peer)
+	}
+
+	if changed {
+		saveTransferQueue()
+	}
+}
+
+// runQueuedTransfer makes one attempt at a queued transfer, then drops it
+// from the queue or schedules the next attempt
+func runQueuedTransfer(item *QueuedTransfer, transfer *Transfer, peer *Peer) {
+	err := runTransfer(transfer, peer)
+
+	transfersMutex.RLock()
+	state, reason, transferErr := transfer.State, transfer.Reason, transfer.Error
+	transfersMutex.RUnlock()
+
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	// Dropped while it was running
+	if !slices.Contains(transferQueue, item) {
+		return
+	}
+	item.transfer = nil
+
+	switch {
+	case state == TransferSucceeded, state == TransferCancelled:
+		removeQueuedTransfer(item.ID)
+		fileQueuedOutbox(item, state == TransferSucceeded)
+	case reason == "declined":
+		// Sending the same offer again would only pester the receiver
+		log.Printf("Dropping queued transfer %s: %s", item.ID, transferErr)
+		removeQueuedTransfer(item.ID)
+		fileQueuedOutbox(item, false)
+	case !isTrustedPeer(peer.ID) || !isRetryableTransferError(err):
+		// Nothing will change by waiting, so leave it for the user to fix
+		// and retry by hand
+		log.Printf("Queued transfer %s gave up: %s", item.ID, transferErr)
+		item.Attempts++
+		item.LastError = transferErr
+		item.State = QueueGaveUp
+		item.NextAttempt = ""
+	default:
+		item.Attempts++
+		item.LastError = transferErr
+		if item.Attempts >= maxQueueAttempts {
+			log.Printf("Queued transfer %s gave up after %d attempts", item.ID, item.Attempts)
+			item.State = QueueGaveUp
+			item.NextAttempt = ""
+		} else {
+			delay := queueRetryDelay(item.Attempts)
+			log.Printf("Queued transfer %s failed (attempt %d/%d), retrying in %s", item.ID, item.Attempts, maxQueueAttempts, delay)
+			item.State = QueuePending
+			item.NextAttempt = time.Now().Add(delay).Format(time.RFC3339)
+		}
+	}
+
+	// An outbox entry is retried by moving it back into the outbox, so one
+	// that has given up leaves the queue for failed/
+	if item.State == QueueGaveUp && item.Outbox != "" {
+		removeQueuedTransfer(item.ID)
+		fileQueuedOutbox(item, false)
+	}
+
+	saveTransferQueue()
+}
+
+// fileQueuedOutbox files the outbox entry of a transfer that has left the
+// queue. The caller holds queueMutex, which the outbox scan takes while
+// holding outboxMutex, so the entry is filed in the background.
+func fileQueuedOutbox(item *QueuedTransfer, succeeded bool) {
+	if item.Outbox != "" {
+		go fileOutboxEntry(item.Outbox, succeeded)
+	}
+}
+
+// isOutboxQueued reports whether an outbox entry already has a transfer in
+// the queue, such as one loaded after a restart
+func isOutboxQueued(entryPath string) bool {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	return slices.ContainsFunc(transferQueue, func(item *QueuedTransfer) bool {
+		return item.Outbox == entryPath
+	})
+}
+
+// isRetryableTransferError reports whether a failed queued transfer is worth
+// another attempt later. A receiver that did not answer, a stall or a lost
+// connection may clear up; a missing source or a request the receiver
+// refused will not.
+func isRetryableTransferError(err error) bool {
+	switch {
+	case errors.Is(err, errOfferTimedOut), errors.Is(err, errTransferStalled), errors.Is(err, errTransferDeadline):
+		return true
+	case errors.Is(err, fs.ErrNotExist):
+		return false
+	}
+
+	switch status.Code(err) {
+	case codes.Unknown, codes.DeadlineExceeded, codes.ResourceExhausted:
+		// Errors without a status, such as running out of resume attempts,
+		// and a slow or busy receiver
+		return true
+	case codes.FailedPrecondition:
+		// Sending already retried these within the attempt
+		return false
+	}
+	return isRetryableSendError(err)
+}
+
+// queueRetryDelay returns the backoff after a number of failed attempts
+func queueRetryDelay(attempts int) time.Duration {
+	delay := queueRetryBase
+	for i := 1; i < attempts && delay < queueRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, queueRetryMax)
+}
+
+// removeQueuedTransfer deletes a transfer from the queue. The caller must
+// hold queueMutex.
+func removeQueuedTransfer(id string) {
+	transferQueue = slices.DeleteFunc(transferQueue, func(item *QueuedTransfer) bool {
+		return item.ID == id
+	})
+}
+
+// findQueuedTransfer returns a queued transfer by ID. The caller must hold
+// queueMutex.
+func findQueuedTransfer(id string) *QueuedTransfer {
+	for _, item := range transferQueue {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// retryQueuedTransfer makes a transfer that is waiting or has given up due
+// now, with a fresh set of attempts
+func retryQueuedTransfer(id string) (QueuedTransfer, error) {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	item := findQueuedTransfer(id)
+	if item == nil {
+		return QueuedTransfer{}, errQueuedTransferNotFound
+	}
+	if item.State == QueueRunning {
+		return QueuedTransfer{}, errQueuedTransferRunning
+	}
+
+	item.State = QueuePending
+	item.Attempts = 0
+	item.NextAttempt = ""
+	saveTransferQueue()
+
+	wakeTransferQueue()
+
+	return *item, nil
+}
+
+// dropQueuedTransfer removes a transfer from the queue, cancelling the
+// attempt in progress
+func dropQueuedTransfer(id string) (QueuedTransfer, error) {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	item := findQueuedTransfer(id)
+	if item == nil {
+		return QueuedTransfer{}, errQueuedTransferNotFound
+	}
+
+	if item.transfer != nil {
+		item.transfer.cancel(errTransferCancelled)
+		if item.State != QueueRunning {
+			item.transfer.finish(errTransferCancelled)
+		}
+	}
+
+	log.Printf("Dropping queued transfer %s", id)
+	removeQueuedTransfer(id)
+	fileQueuedOutbox(item, false)
+	saveTransferQueue()
+
+	return *item, nil
+}
+
+// GetTransferQueue HTTP handler that returns the outbound queue, oldest first
+func GetTransferQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	queueMutex.Lock()
+	list := make([]QueuedTransfer, 0, len(transferQueue))
+	for _, item := range transferQueue {
+		list = append(list, *item)
+	}
+	queueMutex.Unlock()
+
+	response := QueueResponse{
+		Queue: list,
+		Count: len(list),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding queue response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleQueuedTransfer HTTP handler for a single queued transfer: GET
+// returns it and DELETE drops it from the queue
+func HandleQueuedTransfer(w http.ResponseWriter, r *http.Request) {
+	var item QueuedTransfer
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+		queueMutex.Lock()
+		if found := findQueuedTransfer(r.PathValue("id")); found != nil {
+			item = *found
+		} else {
+			err = errQueuedTransferNotFound
+		}
+		queueMutex.Unlock()
+	case http.MethodDelete:
+		item, err = dropQueuedTransfer(r.PathValue("id"))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Queued transfer not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// RetryQueuedTransfer HTTP handler that retries a queued transfer now
+func RetryQueuedTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	item, err := retryQueuedTransfer(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errQueuedTransferNotFound):
+		http.Error(w, "Queued transfer not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errQueuedTransferRunning):
+		http.Error(w, "Queued transfer is already running", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
@@ -0,0 +1,253 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// withQueue gives the test an empty transfer queue, saved to the current
+// directory
+func withQueue(tb testing.TB) {
+	queueMutex.Lock()
+	previous := transferQueue
+	transferQueue = nil
+	queueMutex.Unlock()
+
+	tb.Cleanup(func() {
+		queueMutex.Lock()
+		transferQueue = previous
+		queueMutex.Unlock()
+	})
+}
+
+func TestQueueRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 5 * time.Second},
+		{attempts: 2, want: 10 * time.Second},
+		{attempts: 3, want: 20 * time.Second},
+		{attempts: 7, want: 320 * time.Second},
+		{attempts: 8, want: 10 * time.Minute},
+		{attempts: 50, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := queueRetryDelay(tt.attempts); got != tt.want {
+			t.Fatalf("queueRetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestIsRetryableTransferError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "offer unanswered", err: fmt.Errorf("peer %w for a.txt", errOfferTimedOut), want: true},
+		{name: "stalled", err: errTransferStalled, want: true},
+		{name: "past the deadline", err: errTransferDeadline, want: true},
+		{name: "resume attempts used up", err: errors.New("gave up after 5 attempts"), want: true},
+		{name: "receiver unreachable", err: status.Error(codes.Unavailable, "connection refused"), want: true},
+		{name: "receiver busy", err: status.Error(codes.ResourceExhausted, "too many streams"), want: true},
+		{name: "source missing", err: fmt.Errorf("failed to stat a.txt: %w", fs.ErrNotExist)},
+		{name: "not accepted", err: status.Error(codes.PermissionDenied, "not accepted")},
+		{name: "already retried", err: status.Error(codes.FailedPrecondition, "partial changed")},
+		{name: "invalid request", err: status.Error(codes.InvalidArgument, "invalid file name")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableTransferError(tt.err); got != tt.want {
+				t.Fatalf("isRetryableTransferError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferQueuePersists(t *testing.T) {
+	t.Chdir(t.TempDir())
+	withQueue(t)
+	withoutTransfers(t)
+
+	tests := []struct {
+		state     string
+		wantState string
+	}{
+		{state: QueuePending, wantState: QueuePending},
+		{state: QueueWaitingPeer, wantState: QueuePending},
+		{state: QueueRunning, wantState: QueuePending},
+		{state: QueueGaveUp, wantState: QueueGaveUp},
+	}
+
+	queueMutex.Lock()
+	for i, tt := range tests {
+		transferQueue = append(transferQueue, &QueuedTransfer{
+			ID:       generateRandomID(),
+			PeerID:   "peer-1",
+			Paths:    []string{"report.pdf"},
+			Encrypt:  true,
+			State:    tt.state,
+			Attempts: i,
+		})
+	}
+	saved := slices.Clone(transferQueue)
+	saveTransferQueue()
+	transferQueue = nil
+	queueMutex.Unlock()
+
+	InitTransferQueue()
+
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	if len(transferQueue) != len(tests) {
+		t.Fatalf("loaded %d queued transfers, want %d", len(transferQueue), len(tests))
+	}
+	for i, tt := range tests {
+		got, want := transferQueue[i], saved[i]
+		if got.ID != want.ID || got.Attempts != want.Attempts || !got.Encrypt || !slices.Equal(got.Paths, want.Paths) {
+			t.Fatalf("loaded %+v, want %+v", got, want)
+		}
+		if got.State != tt.wantState {
+			t.Fatalf("%s transfer loaded as %s, want %s", tt.state, got.State, tt.wantState)
+		}
+	}
+}
+
+func TestRunQueuedTransfer(t *testing.T) {
+	tests := []struct {
+		name         string
+		reachable    bool
+		missing      bool // the source file is gone
+		attempts     int  // failed attempts before this one
+		wantQueued   bool
+		wantState    string
+		wantAttempts int
+		wantDelay    time.Duration
+	}{
+		{name: "succeeds", reachable: true},
+		{name: "first failure", wantQueued: true, wantState: QueuePending, wantAttempts: 1, wantDelay: queueRetryBase},
+		{name: "later failure", attempts: 3, wantQueued: true, wantState: QueuePending, wantAttempts: 4, wantDelay: 8 * queueRetryBase},
+		{name: "last attempt", attempts: maxQueueAttempts - 1, wantQueued: true, wantState: QueueGaveUp, wantAttempts: maxQueueAttempts},
+		{name: "source missing", reachable: true, missing: true, wantQueued: true, wantState: QueueGaveUp, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := testPeer(t)
+			withQueue(t)
+			withoutTransfers(t)
+			makeTree(t, ".", map[string]int{"notes.txt": 10})
+			if !tt.reachable {
+				peer.Port = closedPort(t)
+			}
+
+			path := "notes.txt"
+			if tt.missing {
+				path = "missing.txt"
+			}
+			transfer := newTransfer(peer, []string{path}, transferOptions{})
+			item := &QueuedTransfer{ID: transfer.ID, PeerID: peer.ID, Paths: transfer.Paths, State: QueueRunning, Attempts: tt.attempts}
+			queueMutex.Lock()
+			transferQueue = append(transferQueue, item)
+			queueMutex.Unlock()
+
+			start := time.Now()
+			runQueuedTransfer(item, transfer, peer)
+
+			queueMutex.Lock()
+			defer queueMutex.Unlock()
+
+			queued := findQueuedTransfer(item.ID) != nil
+			if queued != tt.wantQueued {
+				t.Fatalf("still queued = %v, want %v (state %s, error %q)", queued, tt.wantQueued, item.State, item.LastError)
+			}
+			if !queued {
+				return
+			}
+			if item.State != tt.wantState || item.Attempts != tt.wantAttempts || item.LastError == "" {
+				t.Fatalf("queued transfer %s after %d attempts with error %q, want %s after %d with an error",
+					item.State, item.Attempts, item.LastError, tt.wantState, tt.wantAttempts)
+			}
+
+			if tt.wantDelay == 0 {
+				if item.NextAttempt != "" {
+					t.Fatalf("next attempt at %s, want none", item.NextAttempt)
+				}
+				return
+			}
+			next, err := time.Parse(time.RFC3339, item.NextAttempt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if delay := next.Sub(start); delay < tt.wantDelay-time.Second || delay > tt.wantDelay+time.Second {
+				t.Fatalf("next attempt in %s, want %s", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestProcessTransferQueue(t *testing.T) {
+	tests := []struct {
+		name        string
+		online      bool
+		nextAttempt time.Duration // from now; 0 when due
+		wantState   string
+	}{
+		{name: "peer offline", wantState: QueueWaitingPeer},
+		{name: "not yet due", online: true, nextAttempt: time.Hour, wantState: QueuePending},
+		{name: "due and online", online: true, wantState: QueueRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := testPeer(t)
+			withQueue(t)
+			withoutTransfers(t)
+			makeTree(t, ".", map[string]int{"notes.txt": 10})
+			if tt.online {
+				withPeers(t, *peer)
+			} else {
+				withPeers(t)
+			}
+
+			item := &QueuedTransfer{ID: generateRandomID(), PeerID: peer.ID, Paths: []string{"notes.txt"}, State: QueuePending}
+			if tt.nextAttempt != 0 {
+				item.NextAttempt = time.Now().Add(tt.nextAttempt).Format(time.RFC3339)
+			}
+			queueMutex.Lock()
+			transferQueue = append(transferQueue, item)
+			queueMutex.Unlock()
+
+			processTransferQueue()
+
+			queueMutex.Lock()
+			state := item.State
+			queueMutex.Unlock()
+			if state != tt.wantState {
+				t.Fatalf("queued transfer %s, want %s", state, tt.wantState)
+			}
+
+			// Let the attempt that was started leave the queue before
+			// cleaning up
+			for state == QueueRunning {
+				time.Sleep(10 * time.Millisecond)
+				queueMutex.Lock()
+				if findQueuedTransfer(item.ID) == nil {
+					state = ""
+				}
+				queueMutex.Unlock()
+			}
+		})
+	}
+}
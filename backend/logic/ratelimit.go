@@ -0,0 +1,304 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimits caps transfer rates in kilobytes (1024 bytes) per second; 0
+// means unlimited. Global limits cover all peers together, per-peer limits
+// apply on top of them, and the first active schedule replaces the global
+// limits while it lasts.
+type RateLimits struct {
+	UploadKBPerSec   int                      `json:"upload_kb_per_sec"`
+	DownloadKBPerSec int                      `json:"download_kb_per_sec"`
+	Peers            map[string]PeerRateLimit `json:"peers,omitempty"` // keyed by peer ID
+	Schedules        []RateSchedule           `json:"schedules,omitempty"`
+}
+
+// PeerRateLimit caps the transfer rates to and from one peer
+type PeerRateLimit struct {
+	UploadKBPerSec   int `json:"upload_kb_per_sec"`
+	DownloadKBPerSec int `json:"download_kb_per_sec"`
+}
+
+// RateSchedule sets the global limits during a daily window, such as
+// working hours. A window whose end is before its start runs past midnight.
+type RateSchedule struct {
+	Days             []string `json:"days,omitempty"` // "mon" to "sun"; empty means every day
+	Start            string   `json:"start"`          // local time, "HH:MM"
+	End              string   `json:"end"`
+	UploadKBPerSec   int      `json:"upload_kb_per_sec"`
+	DownloadKBPerSec int      `json:"download_kb_per_sec"`
+}
+
+// RateLimitsResponse is the configured limits and the global limits in force
+type RateLimitsResponse struct {
+	Limits                 RateLimits `json:"limits"`
+	ActiveUploadKBPerSec   int        `json:"active_upload_kb_per_sec"`
+	ActiveDownloadKBPerSec int        `json:"active_download_kb_per_sec"`
+	ActiveSchedule         *int       `json:"active_schedule,omitempty"` // index into schedules
+}
+
+// Transfer directions a limit applies to
+const (
+	rateUpload = iota
+	rateDownload
+)
+
+const (
+	// Largest number of bytes taken from a bucket at once; bigger chunks
+	// wait for several helpings
+	rateLimitBurst = 64 * 1024
+
+	// How often schedules are checked for a window opening or closing
+	rateScheduleInterval = 30 * time.Second
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Token buckets for all peers together and for each peer
+type peerLimiters struct {
+	upload   *rate.Limiter
+	download *rate.Limiter
+}
+
+var (
+	globalLimiters = peerLimiters{
+		upload:   rate.NewLimiter(rate.Inf, rateLimitBurst),
+		download: rate.NewLimiter(rate.Inf, rateLimitBurst),
+	}
+	peerRateLimiters = make(map[string]peerLimiters)
+	rateLimitMutex   sync.Mutex
+)
+
+// StartRateLimitScheduler applies the configured limits and keeps them in
+// step with the schedules
+func StartRateLimitScheduler() {
+	ticker := time.NewTicker(rateScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		applyRateLimits()
+		<-ticker.C
+	}
+}
+
+// kbLimit converts a limit in KB/s to a token bucket rate
+func kbLimit(kbPerSec int) rate.Limit {
+	if kbPerSec <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(kbPerSec * 1024)
+}
+
+// activeRateSchedule returns the index of the first schedule whose window
+// contains now, or -1
+func activeRateSchedule(schedules []RateSchedule, now time.Time) int {
+	minute := now.Hour()*60 + now.Minute()
+	today := weekdayNames[now.Weekday()]
+	yesterday := weekdayNames[(now.Weekday()+6)%7]
+
+	for i, schedule := range schedules {
+		start, errStart := parseClock(schedule.Start)
+		end, errEnd := parseClock(schedule.End)
+		if errStart != nil || errEnd != nil {
+			continue
+		}
+
+		onDay := func(day string) bool {
+			return len(schedule.Days) == 0 || slices.Contains(schedule.Days, day)
+		}
+
+		// Past midnight, the window belongs to the day it started on
+		if start <= end {
+			if onDay(today) && minute >= start && minute < end {
+				return i
+			}
+		} else if (onDay(today) && minute >= start) || (onDay(yesterday) && minute < end) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseClock converts "HH:MM" to minutes after midnight
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// effectiveRateLimits returns the global limits in force now, in KB/s, and
+// the schedule that set them or -1
+func effectiveRateLimits(limits RateLimits, now time.Time) (int, int, int) {
+	index := activeRateSchedule(limits.Schedules, now)
+	if index < 0 {
+		return limits.UploadKBPerSec, limits.DownloadKBPerSec, -1
+	}
+	schedule := limits.Schedules[index]
+	return schedule.UploadKBPerSec, schedule.DownloadKBPerSec, index
+}
+
+// applyRateLimits sets every token bucket's rate from the configuration
+func applyRateLimits() {
+	limits := GetConfig().RateLimits
+	upload, download, _ := effectiveRateLimits(limits, time.Now())
+
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+
+	globalLimiters.upload.SetLimit(kbLimit(upload))
+	globalLimiters.download.SetLimit(kbLimit(download))
+
+	for peerID, limiters := range peerRateLimiters {
+		peerLimit := limits.Peers[peerID]
+		limiters.upload.SetLimit(kbLimit(peerLimit.UploadKBPerSec))
+		limiters.download.SetLimit(kbLimit(peerLimit.DownloadKBPerSec))
+	}
+}
+
+// bucket returns the token bucket for a direction
+func (l peerLimiters) bucket(direction int) *rate.Limiter {
+	if direction == rateDownload {
+		return l.download
+	}
+	return l.upload
+}
+
+// limitersFor returns a peer's token buckets, creating them on first use
+func limitersFor(peerID string) peerLimiters {
+	rateLimitMutex.Lock()
+	defer rateLimitMutex.Unlock()
+
+	limiters, ok := peerRateLimiters[peerID]
+	if !ok {
+		peerLimit := GetConfig().RateLimits.Peers[peerID]
+		limiters = peerLimiters{
+			upload:   rate.NewLimiter(kbLimit(peerLimit.UploadKBPerSec), rateLimitBurst),
+			download: rate.NewLimiter(kbLimit(peerLimit.DownloadKBPerSec), rateLimitBurst),
+		}
+		peerRateLimiters[peerID] = limiters
+	}
+	return limiters
+}
+
+// throttle waits until n bytes may move to or from a peer under both the
+// global and the peer's limits, or until ctx ends
+func throttle(ctx context.Context, direction int, peerID string, n int) error {
+	global, perPeer := globalLimiters.bucket(direction), limitersFor(peerID).bucket(direction)
+
+	for n > 0 {
+		take := min(n, rateLimitBurst)
+		if err := global.WaitN(ctx, take); err != nil {
+			return err
+		}
+		if err := perPeer.WaitN(ctx, take); err != nil {
+			return err
+		}
+		n -= take
+	}
+	return nil
+}
+
+// throttleTransfer is throttle for a tracked transfer, which may be nil.
+// Waiting on a limit moves no bytes by design, so it is not a stall.
+func throttleTransfer(ctx context.Context, transfer *Transfer, direction int, peerID string, n int) error {
+	if transfer != nil {
+		release := transfer.holdStall()
+		defer release()
+	}
+	return throttle(ctx, direction, peerID, n)
+}
+
+// validateRateLimits checks limits before they replace the configured ones
+func validateRateLimits(limits RateLimits) error {
+	if limits.UploadKBPerSec < 0 || limits.DownloadKBPerSec < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	for peerID, peerLimit := range limits.Peers {
+		if peerLimit.UploadKBPerSec < 0 || peerLimit.DownloadKBPerSec < 0 {
+			return fmt.Errorf("limits for peer %s must not be negative", peerID)
+		}
+	}
+	for i, schedule := range limits.Schedules {
+		if _, err := parseClock(schedule.Start); err != nil {
+			return fmt.Errorf("schedule %d: %v", i, err)
+		}
+		if _, err := parseClock(schedule.End); err != nil {
+			return fmt.Errorf("schedule %d: %v", i, err)
+		}
+		if schedule.Start == schedule.End {
+			return fmt.Errorf("schedule %d: start and end are the same", i)
+		}
+		if schedule.UploadKBPerSec < 0 || schedule.DownloadKBPerSec < 0 {
+			return fmt.Errorf("schedule %d: limits must not be negative", i)
+		}
+		for _, day := range schedule.Days {
+			if !slices.Contains(weekdayNames, day) {
+				return fmt.Errorf("schedule %d: unknown day %q, expected one of %s", i, day, strings.Join(weekdayNames, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// HandleRateLimits HTTP handler for the transfer rate limits: GET returns
+// them and PUT replaces them
+func HandleRateLimits(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var limits RateLimits
+		if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateRateLimits(limits); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		configMutex.Lock()
+		config.RateLimits = limits
+		configMutex.Unlock()
+
+		saveConfigToFile()
+		applyRateLimits()
+		log.Printf("Rate limits updated: upload %d KB/s, download %d KB/s, %d peer limits, %d schedules",
+			limits.UploadKBPerSec, limits.DownloadKBPerSec, len(limits.Peers), len(limits.Schedules))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limits := GetConfig().RateLimits
+	upload, download, index := effectiveRateLimits(limits, time.Now())
+	response := RateLimitsResponse{
+		Limits:                 limits,
+		ActiveUploadKBPerSec:   upload,
+		ActiveDownloadKBPerSec: download,
+	}
+	if index >= 0 {
+		response.ActiveSchedule = &index
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding rate limits response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,149 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// weekTime returns a local time on the week of Monday 2026-03-02
+func weekTime(day string, clock string) time.Time {
+	weekday := map[string]int{"mon": 0, "tue": 1, "wed": 2, "thu": 3, "fri": 4, "sat": 5, "sun": 6}[day]
+	t, err := time.ParseInLocation("2006-01-02 15:04", "2026-03-02 "+clock, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t.AddDate(0, 0, weekday)
+}
+
+func TestActiveRateSchedule(t *testing.T) {
+	workHours := RateSchedule{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}
+	fridayNight := RateSchedule{Days: []string{"fri"}, Start: "22:00", End: "06:00"}
+	everyNight := RateSchedule{Start: "23:30", End: "00:30"}
+
+	tests := []struct {
+		name      string
+		schedules []RateSchedule
+		now       time.Time
+		want      int
+	}{
+		{name: "no schedules", now: weekTime("mon", "12:00"), want: -1},
+		{name: "inside window", schedules: []RateSchedule{workHours}, now: weekTime("wed", "12:00"), want: 0},
+		{name: "start is inclusive", schedules: []RateSchedule{workHours}, now: weekTime("wed", "09:00"), want: 0},
+		{name: "end is exclusive", schedules: []RateSchedule{workHours}, now: weekTime("wed", "17:00"), want: -1},
+		{name: "before window", schedules: []RateSchedule{workHours}, now: weekTime("wed", "08:59"), want: -1},
+		{name: "wrong day", schedules: []RateSchedule{workHours}, now: weekTime("sat", "12:00"), want: -1},
+
+		{name: "overnight before midnight", schedules: []RateSchedule{fridayNight}, now: weekTime("fri", "23:00"), want: 0},
+		{name: "overnight at start", schedules: []RateSchedule{fridayNight}, now: weekTime("fri", "22:00"), want: 0},
+		{name: "overnight after midnight", schedules: []RateSchedule{fridayNight}, now: weekTime("sat", "05:59"), want: 0},
+		{name: "overnight at end", schedules: []RateSchedule{fridayNight}, now: weekTime("sat", "06:00"), want: -1},
+		{name: "overnight morning of start day", schedules: []RateSchedule{fridayNight}, now: weekTime("fri", "05:00"), want: -1},
+		{name: "overnight evening after", schedules: []RateSchedule{fridayNight}, now: weekTime("sat", "23:00"), want: -1},
+		{name: "overnight every day, evening", schedules: []RateSchedule{everyNight}, now: weekTime("sun", "23:45"), want: 0},
+		{name: "overnight every day, into next week", schedules: []RateSchedule{everyNight}, now: weekTime("mon", "00:15"), want: 0},
+		{name: "overnight every day, midday", schedules: []RateSchedule{everyNight}, now: weekTime("mon", "12:00"), want: -1},
+
+		{name: "first match wins", schedules: []RateSchedule{fridayNight, everyNight}, now: weekTime("fri", "23:45"), want: 0},
+		{name: "later match", schedules: []RateSchedule{workHours, everyNight}, now: weekTime("tue", "00:00"), want: 1},
+		{name: "invalid clock skipped", schedules: []RateSchedule{{Start: "9am", End: "17:00"}, workHours}, now: weekTime("mon", "10:00"), want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activeRateSchedule(tt.schedules, tt.now); got != tt.want {
+				t.Fatalf("activeRateSchedule at %s = %d, want %d", tt.now.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveRateLimits(t *testing.T) {
+	limits := RateLimits{
+		UploadKBPerSec:   500,
+		DownloadKBPerSec: 1000,
+		Schedules: []RateSchedule{
+			{Days: []string{"mon"}, Start: "09:00", End: "17:00", UploadKBPerSec: 50},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		wantUpload   int
+		wantDownload int
+		wantIndex    int
+	}{
+		{name: "global outside schedule", now: weekTime("mon", "18:00"), wantUpload: 500, wantDownload: 1000, wantIndex: -1},
+		{name: "schedule replaces both", now: weekTime("mon", "10:00"), wantUpload: 50, wantDownload: 0, wantIndex: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload, download, index := effectiveRateLimits(limits, tt.now)
+			if upload != tt.wantUpload || download != tt.wantDownload || index != tt.wantIndex {
+				t.Fatalf("effectiveRateLimits = %d, %d, %d, want %d, %d, %d",
+					upload, download, index, tt.wantUpload, tt.wantDownload, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestRateLimitBuckets(t *testing.T) {
+	withConfig(t, func(c *Config) {
+		c.RateLimits = RateLimits{
+			UploadKBPerSec: 500,
+			Peers:          map[string]PeerRateLimit{"limited": {UploadKBPerSec: 100, DownloadKBPerSec: 200}},
+		}
+	})
+
+	previousGlobal := globalLimiters
+	globalLimiters = peerLimiters{upload: rate.NewLimiter(rate.Inf, rateLimitBurst), download: rate.NewLimiter(rate.Inf, rateLimitBurst)}
+	peerRateLimiters = make(map[string]peerLimiters)
+	t.Cleanup(func() {
+		globalLimiters = previousGlobal
+		peerRateLimiters = make(map[string]peerLimiters)
+	})
+
+	applyRateLimits()
+
+	tests := []struct {
+		name      string
+		limiters  peerLimiters
+		direction int
+		want      rate.Limit
+	}{
+		{name: "global upload", limiters: globalLimiters, direction: rateUpload, want: 500 * 1024},
+		{name: "global download unlimited", limiters: globalLimiters, direction: rateDownload, want: rate.Inf},
+		{name: "peer upload", limiters: limitersFor("limited"), direction: rateUpload, want: 100 * 1024},
+		{name: "peer download", limiters: limitersFor("limited"), direction: rateDownload, want: 200 * 1024},
+		{name: "other peer upload unlimited", limiters: limitersFor("other"), direction: rateUpload, want: rate.Inf},
+		{name: "other peer download unlimited", limiters: limitersFor("other"), direction: rateDownload, want: rate.Inf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limiters.bucket(tt.direction).Limit(); got != tt.want {
+				t.Fatalf("limit = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if limitersFor("limited") != limitersFor("limited") {
+		t.Fatal("limitersFor made a second set of buckets for the same peer")
+	}
+
+	// Changed limits reach buckets that already exist
+	withConfig(t, func(c *Config) {
+		c.RateLimits.Peers = map[string]PeerRateLimit{"other": {UploadKBPerSec: 10}}
+	})
+	applyRateLimits()
+
+	if got := limitersFor("limited").bucket(rateUpload).Limit(); got != rate.Inf {
+		t.Fatalf("limit of a peer no longer limited = %v, want unlimited", got)
+	}
+	if got := limitersFor("other").bucket(rateUpload).Limit(); got != 10*1024 {
+		t.Fatalf("limit of a newly limited peer = %v, want %v", got, rate.Limit(10*1024))
+	}
+}
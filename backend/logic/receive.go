@@ -0,0 +1,331 @@
+package logic
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// chunkSource is a stream a file's chunks arrive on: a SendFile stream a
+// peer pushes, or a GetFile stream pulled from a peer
+type chunkSource interface {
+	Recv() (*pb.FileChunk, error)
+	Context() context.Context
+}
+
+// fileReceiver writes one file's chunks into its partial file and moves the
+// verified result into the downloads directory
+type fileReceiver struct {
+	state       *partialState
+	file        *os.File
+	opener      *chunkCipher // set for end-to-end encrypted streams
+	resumedFrom int64
+
+	// Where complete puts the file; empty means under the downloads directory
+	target string
+
+	// The existing copy blocks are copied from in a delta stream
+	basis      *os.File
+	basisSize  int64
+	blockSize  int64
+	bytesSaved int64
+	copyBuffer []byte
+
+	// Reused for the data of compressed chunks
+	inflated []byte
+
+	// A download this node started, which tracks the bytes as they arrive
+	transfer *Transfer
+
+	// From the final chunk
+	expectedDigest string
+	metadata       fileMetadata
+}
+
+// newFileReceiver starts receiving into a partial file opened by openPartial
+func newFileReceiver(state *partialState, file *os.File, opener *chunkCipher) *fileReceiver {
+	r := &fileReceiver{
+		state:       state,
+		file:        file,
+		opener:      opener,
+		resumedFrom: state.BytesReceived,
+	}
+
+	if r.resumedFrom > 0 {
+		log.Printf("Resuming file %s at byte %d of %d", state.FileName, r.resumedFrom, state.FileSize)
+	} else {
+		log.Printf("Starting to receive file: %s", state.FileName)
+	}
+	publishIncomingFile(state, "receiving", nil)
+
+	return r
+}
+
+// receive writes chunks from src, starting with one already read, until the
+// stream ends with the whole file and its digest. On failure the partial
+// data is kept so the transfer can resume.
+func (r *fileReceiver) receive(src chunkSource, chunk *pb.FileChunk) error {
+	state := r.state
+
+	// A delta stream announces itself on its first chunk
+	if chunk.DeltaBlockSize > 0 {
+		if err := r.openBasis(chunk); err != nil {
+			return r.abort(err)
+		}
+	}
+
+	// Waiting on the download limit holds back the sender through flow control
+	peerID, _, _ := authenticatedPeer(src.Context())
+
+	for {
+		if err := throttleTransfer(src.Context(), r.transfer, rateDownload, peerID, len(chunk.Data)); err != nil {
+			return r.abort(err)
+		}
+		if err := r.write(chunk); err != nil {
+			return r.abort(err)
+		}
+
+		var err error
+		chunk, err = src.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error receiving chunk: %v", err)
+			return r.abort(err)
+		}
+	}
+
+	if state.BytesReceived != state.FileSize {
+		log.Printf("Transfer %s ended early at %d of %d bytes", state.TransferID, state.BytesReceived, state.FileSize)
+		return r.abort(status.Errorf(codes.FailedPrecondition,
+			"incomplete transfer: received %d of %d bytes", state.BytesReceived, state.FileSize))
+	}
+
+	if r.expectedDigest == "" {
+		return r.abort(status.Error(codes.InvalidArgument, "missing file digest"))
+	}
+
+	return nil
+}
+
+// write checks one chunk and appends its data to the partial file
+func (r *fileReceiver) write(chunk *pb.FileChunk) error {
+	state := r.state
+
+	if chunk.Offset != state.BytesReceived {
+		return status.Errorf(codes.InvalidArgument,
+			"chunk %d at offset %d, expected %d", chunk.ChunkNumber, chunk.Offset, state.BytesReceived)
+	}
+
+	// Reject corrupt chunks before they reach the disk
+	if checksum := crc32.Checksum(chunk.Data, crc32cTable); checksum != chunk.Crc32C {
+		log.Printf("Chunk %d for %s failed checksum (got %08x, want %08x)",
+			chunk.ChunkNumber, state.FileName, checksum, chunk.Crc32C)
+		return status.Errorf(codes.DataLoss, "chunk %d failed checksum", chunk.ChunkNumber)
+	}
+
+	// Sealed chunks must open under the stream's key. The offset is part
+	// of the authenticated data, so reordered or replayed chunks fail.
+	data := chunk.Data
+	if r.opener != nil {
+		var err error
+		data, err = r.opener.open(chunk)
+		if err != nil {
+			log.Printf("Chunk %d for %s failed authentication", chunk.ChunkNumber, state.FileName)
+			return status.Errorf(codes.DataLoss, "chunk %d failed authentication", chunk.ChunkNumber)
+		}
+	} else if chunk.Encryption != nil {
+		return status.Error(codes.InvalidArgument, "encryption header after the first chunk")
+	}
+
+	data, err := inflateChunk(chunk, data, r.inflated)
+	if err != nil {
+		return err
+	}
+	if chunk.Compression != "" {
+		r.inflated = data
+	}
+
+	// The final chunk carries the whole-file digest and metadata
+	if chunk.Sha256 != "" {
+		r.expectedDigest = chunk.Sha256
+		r.metadata = chunkMetadata(chunk)
+	}
+
+	var bytesWritten int64
+	if chunk.Copy != nil {
+		// Blocks the receiver already holds are copied from its own file
+		if len(data) != 0 {
+			return status.Error(codes.InvalidArgument, "block reference with data")
+		}
+
+		copied, err := r.copyBlocks(chunk.Copy)
+		if err != nil {
+			log.Printf("Error copying blocks for %s: %v", state.FileName, err)
+			return err
+		}
+		bytesWritten = copied
+	} else {
+		if len(data) == 0 {
+			return nil
+		}
+
+		// Write chunk data to file
+		n, err := r.file.Write(data)
+		if err != nil {
+			log.Printf("Error writing to file: %v", err)
+			return status.Errorf(codes.Internal, "failed to write file: %v", err)
+		}
+
+		state.hash.Write(data)
+		bytesWritten = int64(n)
+	}
+
+	state.BytesReceived += bytesWritten
+	state.ChunksReceived++
+	if r.transfer != nil {
+		r.transfer.setProgress(state.BytesReceived)
+	}
+
+	if state.ChunksReceived%checkpointInterval == 0 {
+		if err := savePartialState(state); err != nil {
+			log.Printf("Error saving partial state for %s: %v", state.TransferID, err)
+		}
+		publishIncomingFile(state, "receiving", nil)
+	}
+
+	return nil
+}
+
+// abort keeps the partial data and its sidecar so the sender can resume
+func (r *fileReceiver) abort(err error) error {
+	r.closeBasis()
+	r.file.Close()
+	if saveErr := savePartialState(r.state); saveErr != nil {
+		log.Printf("Error saving partial state for %s: %v", r.state.TransferID, saveErr)
+	}
+	releaseReceive(r.state.TransferID)
+
+	if status.Code(err) == codes.Canceled {
+		publishIncomingFile(r.state, "interrupted", err)
+	} else {
+		publishIncomingFile(r.state, "failed", err)
+	}
+	return err
+}
+
+// complete verifies a fully received file, flushes it to disk and moves it
+// into the downloads directory under the collision policy. A digest
+// mismatch is reported in the response rather than as an error.
+func (r *fileReceiver) complete() (*pb.FileTransferResponse, error) {
+	state := r.state
+	defer releaseReceive(state.TransferID)
+
+	r.closeBasis()
+	if err := r.file.Sync(); err != nil {
+		r.file.Close()
+		return nil, status.Errorf(codes.Internal, "failed to flush file: %v", err)
+	}
+	if err := r.file.Close(); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to close file: %v", err)
+	}
+
+	dataPath, statePath := partialPaths(state.TransferID)
+
+	// A bad digest means the partial data cannot be trusted, so start over next time
+	digest := hex.EncodeToString(state.hash.Sum(nil))
+	if digest != r.expectedDigest {
+		log.Printf("Integrity check failed for %s: got %s, want %s", state.FileName, digest, r.expectedDigest)
+		removePartial(state.TransferID)
+		publishIncomingFile(state, "failed", fmt.Errorf("integrity check failed"))
+
+		return &pb.FileTransferResponse{
+			Success:       false,
+			Message:       fmt.Sprintf("File %s failed integrity check", state.FileName),
+			BytesReceived: state.BytesReceived,
+			ResumedFrom:   r.resumedFrom,
+			Verified:      false,
+			Sha256:        digest,
+		}, nil
+	}
+
+	// A sync target and a delta stream both update the copy they are
+	// meant for, though versioning still keeps the one a delta replaces
+	filePath := r.target
+	policy := collisionPolicy()
+	var err error
+	if filePath == "" {
+		filePath, err = receivedPath(state.FileName)
+	} else {
+		policy = collisionOverwrite
+	}
+	if r.blockSize > 0 && policy != collisionVersion {
+		policy = collisionOverwrite
+	}
+	if err != nil {
+		log.Printf("Refusing to store %s: %v", state.FileName, err)
+		removePartial(state.TransferID)
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		log.Printf("Error creating directory for %s: %v", filePath, err)
+		return nil, status.Errorf(codes.Internal, "failed to create directory: %v", err)
+	}
+
+	storedPath, err := placeReceivedFile(dataPath, filePath, policy)
+	if err != nil {
+		log.Printf("Error moving %s into place: %v", filePath, err)
+		return nil, status.Errorf(codes.Internal, "failed to store file: %v", err)
+	}
+
+	message := fmt.Sprintf("File %s received and verified", state.FileName)
+	switch storedPath {
+	case "":
+		removePartial(state.TransferID)
+		log.Printf("Kept the existing %s, discarding the received copy", filePath)
+		message = fmt.Sprintf("File %s received and verified, kept the existing copy", state.FileName)
+	case filePath:
+		os.Remove(statePath)
+	default:
+		os.Remove(statePath)
+		log.Printf("%s already exists, stored the received file as %s", filePath, storedPath)
+		if rel, err := filepath.Rel(downloadsDir, storedPath); err == nil {
+			recordPlacement(state.OfferID, state.FileName, filepath.ToSlash(rel))
+		}
+		message = fmt.Sprintf("File %s received and verified, stored as %s", state.FileName, filepath.Base(storedPath))
+	}
+
+	if storedPath != "" {
+		if err := applyMetadata(storedPath, r.metadata); err != nil {
+			log.Printf("Error applying metadata to %s: %v", storedPath, err)
+		}
+	}
+
+	// The file is in place, so the offer admits no further streams for it
+	consumeOfferEntry(state.OfferID, state.FileName)
+
+	log.Printf("File transfer completed: %s (%d bytes, resumed from %d, %d reused, sha256 %s)",
+		state.FileName, state.BytesReceived, r.resumedFrom, r.bytesSaved, digest)
+	publishIncomingFile(state, "completed", nil)
+
+	return &pb.FileTransferResponse{
+		Success:       true,
+		Message:       message,
+		BytesReceived: state.BytesReceived,
+		ResumedFrom:   r.resumedFrom,
+		Verified:      true,
+		Sha256:        digest,
+		BytesSaved:    r.bytesSaved,
+	}, nil
+}
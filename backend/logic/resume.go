@@ -0,0 +1,224 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// partialState is the sidecar kept next to a partially received file so an
+// interrupted transfer can continue from the last byte written
+type partialState struct {
+	TransferID     string `json:"transfer_id"`
+	OfferID        string `json:"offer_id"`
+	FileName       string `json:"file_name"`
+	FileSize       int64  `json:"file_size"`
+	BytesReceived  int64  `json:"bytes_received"`
+	ChunksReceived int64  `json:"chunks_received"`
+	UpdatedAt      string `json:"updated_at"`
+
+	// Set when the file arrives as ranges over parallel streams
+	RangeSize  int64  `json:"range_size,omitempty"`
+	RangesDone []bool `json:"ranges_done,omitempty"`
+
+	// Running SHA-256 of the bytes written so far, and its saved state
+	// covering BytesReceived so a resume needn't read them back
+	hash      hash.Hash
+	HashState []byte `json:"hash_state,omitempty"`
+}
+
+const (
+	downloadsDir = "./downloads"
+	partialDir   = ".partial"
+
+	// Persist the sidecar every N chunks so a crash loses little progress
+	checkpointInterval = 32
+)
+
+var (
+	transferIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	activeReceives      = make(map[string]bool)
+	activeReceivesMutex sync.Mutex
+)
+
+// computeTransferID derives a stable ID for a file so that a retried send of
+// the same unchanged file maps onto the receiver's partial copy
+func computeTransferID(filePath string, fileInfo os.FileInfo) string {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		absPath = filePath
+	}
+
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%s\x00%s\x00%d\x00%d",
+		GetSystemInfoStruct().PeerID, absPath, fileInfo.Size(), fileInfo.ModTime().UnixNano())
+
+	return hex.EncodeToString(hasher.Sum(nil)[:16])
+}
+
+// isValidTransferID checks that a transfer ID is safe to use in a file name
+func isValidTransferID(transferID string) bool {
+	return transferIDPattern.MatchString(transferID)
+}
+
+// partialPaths returns the data and sidecar paths for a transfer
+func partialPaths(transferID string) (string, string) {
+	base := filepath.Join(downloadsDir, partialDir, transferID)
+	return base + ".part", base + ".json"
+}
+
+// loadPartialState reads the sidecar for a transfer, returning nil if none exists
+func loadPartialState(transferID string) *partialState {
+	_, statePath := partialPaths(transferID)
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil
+	}
+
+	var state partialState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Error decoding partial state %s: %v", statePath, err)
+		return nil
+	}
+
+	return &state
+}
+
+// savePartialState writes the sidecar for a transfer
+func savePartialState(state *partialState) error {
+	_, statePath := partialPaths(state.TransferID)
+	state.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	if marshaler, ok := state.hash.(encoding.BinaryMarshaler); ok {
+		hashState, err := marshaler.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		state.HashState = hashState
+	}
+
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated sidecar
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, statePath)
+}
+
+// restoreHash loads the digest state saved with a transfer's checkpoint
+// into h, reporting whether there was one covering exactly offset bytes
+func restoreHash(h hash.Hash, transferID string, offset int64) bool {
+	saved := loadPartialState(transferID)
+	if saved == nil || saved.BytesReceived != offset || len(saved.HashState) == 0 {
+		return false
+	}
+
+	unmarshaler, ok := h.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return false
+	}
+	if err := unmarshaler.UnmarshalBinary(saved.HashState); err != nil {
+		log.Printf("Error restoring digest state for transfer %s: %v", transferID, err)
+		h.Reset()
+		return false
+	}
+	return true
+}
+
+// removePartial deletes the partial data and sidecar for a transfer
+func removePartial(transferID string) {
+	dataPath, statePath := partialPaths(transferID)
+	os.Remove(dataPath)
+	os.Remove(statePath)
+}
+
+// resumePoint returns how much of a transfer is already on disk. Stale or
+// mismatched partial data is discarded so the transfer starts from zero.
+func resumePoint(transferID, fileName string, fileSize int64) (int64, int64) {
+	state := loadPartialState(transferID)
+	if state == nil {
+		return 0, 0
+	}
+
+	// Ranges written in parallel leave holes a single stream can't resume over
+	if state.RangeSize > 0 {
+		log.Printf("Discarding parallel partial data for transfer %s", transferID)
+		removePartial(transferID)
+		return 0, 0
+	}
+
+	if state.FileName != fileName || state.FileSize != fileSize || state.BytesReceived > fileSize {
+		log.Printf("Discarding mismatched partial data for transfer %s", transferID)
+		removePartial(transferID)
+		return 0, 0
+	}
+
+	// The data file may be longer than the last checkpoint but never shorter
+	dataPath, _ := partialPaths(transferID)
+	info, err := os.Stat(dataPath)
+	if err != nil || info.Size() < state.BytesReceived {
+		log.Printf("Partial data for transfer %s is missing or short, restarting", transferID)
+		removePartial(transferID)
+		return 0, 0
+	}
+
+	return state.BytesReceived, state.ChunksReceived
+}
+
+// waitForReceiveRelease waits until no stream is writing a transfer, giving
+// up after timeout
+func waitForReceiveRelease(transferID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		activeReceivesMutex.Lock()
+		active := activeReceives[transferID]
+		activeReceivesMutex.Unlock()
+
+		if !active {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// claimReceive marks a transfer as being received so two streams cannot
+// write the same partial file at once
+func claimReceive(transferID string) bool {
+	activeReceivesMutex.Lock()
+	defer activeReceivesMutex.Unlock()
+
+	if activeReceives[transferID] {
+		return false
+	}
+	activeReceives[transferID] = true
+	return true
+}
+
+// releaseReceive clears the in-progress mark for a transfer
+func releaseReceive(transferID string) {
+	activeReceivesMutex.Lock()
+	defer activeReceivesMutex.Unlock()
+
+	delete(activeReceives, transferID)
+}
@@ -0,0 +1,137 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResumeAfterBrokenStream(t *testing.T) {
+	const (
+		chunkSize   = 1024
+		totalChunks = 48
+		sentChunks  = 40 // chunks that arrive before the stream breaks
+	)
+
+	tests := []struct {
+		name          string
+		keepHashState bool // false for a sidecar saved without the digest state
+	}{
+		{name: "digest state saved", keepHashState: true},
+		{name: "sidecar without digest state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := testPeer(t)
+			conn, err := dialPeer(peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			client := pb.NewFileTransferServiceClient(conn)
+
+			data := make([]byte, totalChunks*chunkSize)
+			rand.Read(data)
+			digest := sha256.Sum256(data)
+
+			transferID, offerID := generateRandomID(), generateRandomID()
+			offersMutex.Lock()
+			acceptedOffers[offerID] = acceptedOffer{
+				Files:        map[string]OfferedFile{"big.bin": {Path: "big.bin", Size: int64(len(data))}},
+				SenderPeerID: peer.ID,
+				AcceptedAt:   time.Now(),
+				Placed:       make(map[string]string),
+			}
+			offersMutex.Unlock()
+
+			chunkAt := func(i int) *pb.FileChunk {
+				chunk := data[i*chunkSize : (i+1)*chunkSize]
+				return &pb.FileChunk{
+					FileName:    "big.bin",
+					Data:        chunk,
+					ChunkNumber: int64(i + 1),
+					TotalChunks: totalChunks,
+					TransferId:  transferID,
+					Offset:      int64(i * chunkSize),
+					FileSize:    int64(len(data)),
+					Crc32C:      crc32.Checksum(chunk, crc32cTable),
+					OfferId:     offerID,
+				}
+			}
+
+			// A corrupt chunk breaks the first stream partway
+			stream, err := client.SendFile(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range sentChunks {
+				stream.Send(chunkAt(i))
+			}
+			corrupt := chunkAt(sentChunks)
+			corrupt.Crc32C++
+			stream.Send(corrupt)
+			if _, err := stream.CloseAndRecv(); status.Code(err) != codes.DataLoss {
+				t.Fatalf("broken stream error = %v, want %s", err, codes.DataLoss)
+			}
+
+			state := loadPartialState(transferID)
+			if state == nil || len(state.HashState) == 0 {
+				t.Fatal("broken stream left no digest state in its sidecar")
+			}
+			if !tt.keepHashState {
+				state.HashState = nil
+				if err := savePartialState(state); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resume, err := client.QueryResume(context.Background(), &pb.ResumeRequest{TransferId: transferID, FileName: "big.bin", FileSize: int64(len(data))})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resume.BytesReceived != sentChunks*chunkSize {
+				t.Fatalf("receiver resumes from byte %d, want %d", resume.BytesReceived, sentChunks*chunkSize)
+			}
+
+			// The second stream carries only the rest
+			stream, err = client.SendFile(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := sentChunks; i < totalChunks; i++ {
+				chunk := chunkAt(i)
+				if i == totalChunks-1 {
+					chunk.Sha256 = hex.EncodeToString(digest[:])
+				}
+				stream.Send(chunk)
+			}
+			response, err := stream.CloseAndRecv()
+			if err != nil {
+				t.Fatalf("resumed stream: %v", err)
+			}
+			if !response.Verified || response.ResumedFrom != sentChunks*chunkSize {
+				t.Fatalf("resumed from %d, verified %v, want from %d and verified", response.ResumedFrom, response.Verified, sentChunks*chunkSize)
+			}
+
+			got, err := os.ReadFile(filepath.Join(downloadsDir, "big.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("resumed file differs from the one sent")
+			}
+		})
+	}
+}
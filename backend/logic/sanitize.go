@@ -0,0 +1,101 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Longest file name accepted from a peer, in bytes (the common filesystem limit)
+	maxFileNameLength = 255
+
+	// Longest relative path accepted from a peer, in bytes
+	maxRelativePathLength = 4096
+)
+
+var errInvalidFileName = errors.New("invalid file name")
+
+// Device names Windows reserves regardless of extension
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFileName validates a file name received from a peer so it can be
+// joined onto the downloads directory without escaping it. Trailing dots and
+// spaces are trimmed, as Windows would; anything else suspicious is rejected.
+func sanitizeFileName(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", fmt.Errorf("%w: not valid UTF-8", errInvalidFileName)
+	}
+
+	for _, r := range name {
+		switch {
+		case r == 0:
+			return "", fmt.Errorf("%w: contains NUL byte", errInvalidFileName)
+		case r == '/' || r == '\\':
+			return "", fmt.Errorf("%w: contains path separator", errInvalidFileName)
+		case unicode.IsControl(r):
+			return "", fmt.Errorf("%w: contains control character", errInvalidFileName)
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return "", fmt.Errorf("%w: contains reserved character %q", errInvalidFileName, r)
+		}
+	}
+
+	name = strings.TrimRight(name, ". ")
+
+	if name == "" {
+		return "", fmt.Errorf("%w: empty", errInvalidFileName)
+	}
+
+	if len(name) > maxFileNameLength {
+		return "", fmt.Errorf("%w: longer than %d bytes", errInvalidFileName, maxFileNameLength)
+	}
+
+	// Check the stem too, since "CON.txt" is just as reserved as "CON"
+	stem, _, _ := strings.Cut(name, ".")
+	if reservedFileNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		return "", fmt.Errorf("%w: reserved name %q", errInvalidFileName, name)
+	}
+
+	// Keep peers out of the directory used for in-progress transfers
+	if name == partialDir {
+		return "", fmt.Errorf("%w: reserved name %q", errInvalidFileName, name)
+	}
+
+	return name, nil
+}
+
+// sanitizeRelativePath validates a slash-separated relative path received from
+// a peer, such as "project/src/main.go", by sanitizing each component. The
+// cleaned path is returned in slash form.
+func sanitizeRelativePath(relPath string) (string, error) {
+	if relPath == "" {
+		return "", fmt.Errorf("%w: empty", errInvalidFileName)
+	}
+
+	if len(relPath) > maxRelativePathLength {
+		return "", fmt.Errorf("%w: path longer than %d bytes", errInvalidFileName, maxRelativePathLength)
+	}
+
+	if strings.HasPrefix(relPath, "/") {
+		return "", fmt.Errorf("%w: absolute path", errInvalidFileName)
+	}
+
+	components := strings.Split(relPath, "/")
+	for i, component := range components {
+		cleaned, err := sanitizeFileName(component)
+		if err != nil {
+			return "", err
+		}
+		components[i] = cleaned
+	}
+
+	return strings.Join(components, "/"), nil
+}
@@ -0,0 +1,122 @@
+package logic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", input: "report.pdf", want: "report.pdf"},
+		{name: "spaces inside", input: "my report (final).pdf", want: "my report (final).pdf"},
+		{name: "hidden file", input: ".bashrc", want: ".bashrc"},
+		{name: "unicode", input: "résumé 履歴書.txt", want: "résumé 履歴書.txt"},
+		{name: "trailing dot trimmed", input: "notes.txt.", want: "notes.txt"},
+		{name: "trailing space trimmed", input: "notes.txt  ", want: "notes.txt"},
+		{name: "max length", input: strings.Repeat("a", maxFileNameLength), want: strings.Repeat("a", maxFileNameLength)},
+
+		{name: "empty", input: "", wantErr: true},
+		{name: "dot", input: ".", wantErr: true},
+		{name: "dot dot", input: "..", wantErr: true},
+		{name: "only dots and spaces", input: ". . .", wantErr: true},
+		{name: "parent traversal", input: "../../.bashrc", wantErr: true},
+		{name: "nested path", input: "dir/file.txt", wantErr: true},
+		{name: "absolute unix path", input: "/etc/passwd", wantErr: true},
+		{name: "windows separator", input: `..\..\boot.ini`, wantErr: true},
+		{name: "windows absolute path", input: `C:\Windows\win.ini`, wantErr: true},
+		{name: "drive relative", input: "C:evil.txt", wantErr: true},
+		{name: "NUL byte", input: "file.txt\x00.jpg", wantErr: true},
+		{name: "newline", input: "file\n.txt", wantErr: true},
+		{name: "escape sequence", input: "file\x1b[31m.txt", wantErr: true},
+		{name: "invalid utf8", input: "file\xff.txt", wantErr: true},
+		{name: "wildcard", input: "file*.txt", wantErr: true},
+		{name: "reserved CON", input: "CON", wantErr: true},
+		{name: "reserved lowercase", input: "nul", wantErr: true},
+		{name: "reserved with extension", input: "com1.txt", wantErr: true},
+		{name: "reserved with trailing space", input: "LPT9 .log", wantErr: true},
+		{name: "reserved via trailing dot", input: "aux.", wantErr: true},
+		{name: "partial dir", input: partialDir, wantErr: true},
+		{name: "overlong", input: strings.Repeat("a", maxFileNameLength+1), wantErr: true},
+		{name: "overlong multibyte", input: strings.Repeat("é", maxFileNameLength/2+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeFileName(tt.input)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("sanitizeFileName(%q) = %q, want error", tt.input, got)
+				}
+				if !errors.Is(err, errInvalidFileName) {
+					t.Fatalf("sanitizeFileName(%q) error = %v, want errInvalidFileName", tt.input, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("sanitizeFileName(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("sanitizeFileName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeRelativePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "single file", input: "report.pdf", want: "report.pdf"},
+		{name: "nested", input: "project/src/main.go", want: "project/src/main.go"},
+		{name: "hidden directory", input: "project/.git/config", want: "project/.git/config"},
+		{name: "component trailing dot trimmed", input: "project./notes.txt", want: "project/notes.txt"},
+
+		{name: "empty", input: "", wantErr: true},
+		{name: "absolute", input: "/etc/passwd", wantErr: true},
+		{name: "parent at start", input: "../outside.txt", wantErr: true},
+		{name: "parent in middle", input: "project/../../outside.txt", wantErr: true},
+		{name: "current directory component", input: "project/./file.txt", wantErr: true},
+		{name: "empty component", input: "project//file.txt", wantErr: true},
+		{name: "trailing slash", input: "project/", wantErr: true},
+		{name: "backslash separator", input: `project\..\file.txt`, wantErr: true},
+		{name: "NUL in component", input: "project/fi\x00le.txt", wantErr: true},
+		{name: "reserved component", input: "project/CON/file.txt", wantErr: true},
+		{name: "partial dir component", input: partialDir + "/file.part", wantErr: true},
+		{name: "overlong component", input: "project/" + strings.Repeat("a", maxFileNameLength+1), wantErr: true},
+		{name: "overlong path", input: strings.Repeat("abcdefgh/", maxRelativePathLength/9+1) + "x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeRelativePath(tt.input)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("sanitizeRelativePath(%q) = %q, want error", tt.input, got)
+				}
+				if !errors.Is(err, errInvalidFileName) {
+					t.Fatalf("sanitizeRelativePath(%q) error = %v, want errInvalidFileName", tt.input, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("sanitizeRelativePath(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("sanitizeRelativePath(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,567 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SharedFileInfo describes a file or directory in a peer's shared folder
+type SharedFileInfo struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	IsDir      bool   `json:"is_dir,omitempty"`
+	ModTime    string `json:"mod_time"`
+	TransferID string `json:"transfer_id,omitempty"`
+}
+
+type SharedFilesResponse struct {
+	Peer  string           `json:"peer"`
+	Path  string           `json:"path"`
+	Files []SharedFileInfo `json:"files"`
+	Count int              `json:"count"`
+}
+
+type SharedDownloadRequest struct {
+	Path string `json:"path"`
+}
+
+// openSharedDir opens the configured shared folder, creating it on first use.
+// Everything served to peers goes through the returned root, so neither
+// paths nor symlinks inside the folder can reach files outside it.
+func openSharedDir() (*os.Root, string, error) {
+	dir, err := filepath.Abs(GetConfig().SharedDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, "", err
+	}
+
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, dir, nil
+}
+
+// sharedRelPath validates a path a peer asked for within the shared folder.
+// An empty path or "." names the folder itself.
+func sharedRelPath(relPath string) (string, error) {
+	if relPath == "" || relPath == "." {
+		return ".", nil
+	}
+	return sanitizeRelativePath(relPath)
+}
+
+// describeSharedFile converts a shared entry for the wire. Regular files get
+// the transfer ID a download resumes under, which changes with the file.
+func describeSharedFile(sharedDir, relPath string, info fs.FileInfo) *pb.SharedFile {
+	file := &pb.SharedFile{
+		Path:        relPath,
+		IsDirectory: info.IsDir(),
+		Mtime:       info.ModTime().UnixNano(),
+	}
+	if info.Mode().IsRegular() {
+		file.Size = info.Size()
+		file.TransferId = computeTransferID(filepath.Join(sharedDir, filepath.FromSlash(relPath)), info)
+	}
+	return file
+}
+
+// ListSharedFiles lists a directory in the shared folder, or describes a
+// single file. Entries a peer could not download are left out.
+func (s *fileTransferServer) ListSharedFiles(ctx context.Context, req *pb.ListSharedFilesRequest) (*pb.SharedFileList, error) {
+	relPath, err := sharedRelPath(req.Path)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	root, sharedDir, err := openSharedDir()
+	if err != nil {
+		log.Printf("Error opening shared folder: %v", err)
+		return nil, status.Error(codes.Internal, "shared folder is unavailable")
+	}
+	defer root.Close()
+
+	info, err := root.Stat(relPath)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Path)
+	}
+
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() {
+			return nil, status.Errorf(codes.NotFound, "%s not found", req.Path)
+		}
+		return &pb.SharedFileList{Files: []*pb.SharedFile{describeSharedFile(sharedDir, relPath, info)}}, nil
+	}
+
+	entries, err := readSharedDir(root, relPath, false)
+	if err != nil {
+		log.Printf("Error reading shared directory %s: %v", relPath, err)
+		return nil, status.Error(codes.Internal, "failed to read directory")
+	}
+
+	files := make([]*pb.SharedFile, len(entries))
+	for i, entry := range entries {
+		files[i] = describeSharedFile(sharedDir, entry.path, entry.info)
+	}
+
+	return &pb.SharedFileList{Files: files}, nil
+}
+
+// GetFile streams a file from the shared folder starting at the requested
+// offset, in the same chunks a pushed file uses. The final chunk carries the
+// whole-file digest and metadata.
+func (s *fileTransferServer) GetFile(req *pb.GetFileRequest, stream pb.FileTransferService_GetFileServer) error {
+	relPath, err := sanitizeRelativePath(req.Path)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	root, sharedDir, err := openSharedDir()
+	if err != nil {
+		log.Printf("Error opening shared folder: %v", err)
+		return status.Error(codes.Internal, "shared folder is unavailable")
+	}
+	defer root.Close()
+
+	file, err := root.Open(relPath)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "%s not found", req.Path)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return status.Errorf(codes.NotFound, "%s not found", req.Path)
+	}
+
+	// The transfer ID covers size and mtime, so a mismatch means the file
+	// changed since it was listed and the requester's partial data is stale
+	fileSize := info.Size()
+	transferID := describeSharedFile(sharedDir, relPath, info).TransferId
+	if req.TransferId != "" && req.TransferId != transferID {
+		return status.Errorf(codes.FailedPrecondition, "%s changed since it was listed", req.Path)
+	}
+
+	offset := req.Offset
+	if offset < 0 || offset > fileSize {
+		return status.Errorf(codes.InvalidArgument, "offset %d is outside the file", offset)
+	}
+
+	requester, _, _ := authenticatedPeer(stream.Context())
+	log.Printf("Serving shared file %s to %s from byte %d of %d", relPath, requester, offset, fileSize)
+
+	return sendFileChunks(stream, file, info, relPath, transferID, offset, req.ChunksReceived)
+}
+
+// chunkSink is a stream a file's chunks are served on
+type chunkSink interface {
+	Send(*pb.FileChunk) error
+	Context() context.Context
+}
+
+// sendFileChunks streams an open file from offset in the chunks a pushed
+// file uses, finishing with a chunk that carries the whole-file digest and
+// metadata
+func sendFileChunks(stream chunkSink, file *os.File, info fs.FileInfo, fileName, transferID string, offset, chunkNumber int64) error {
+	fileSize := info.Size()
+
+	// Hash the part the requester already holds; this also leaves the file
+	// positioned at the offset
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return status.Errorf(codes.Internal, "failed to seek file: %v", err)
+	}
+	hasher := sha256.New()
+	if _, err := io.CopyN(hasher, file, offset); err != nil {
+		return status.Errorf(codes.Internal, "failed to hash file: %v", err)
+	}
+
+	totalChunks := (fileSize + initialChunkSize - 1) / initialChunkSize
+	buffer := getChunkBuffer()
+	defer putChunkBuffer(buffer)
+	sizer := newChunkSizer(0)
+	requester, _, _ := authenticatedPeer(stream.Context())
+
+	for {
+		bytesRead, err := file.Read((*buffer)[:sizer.next()])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to read file: %v", err)
+		}
+
+		chunkNumber++
+		data := (*buffer)[:bytesRead]
+		hasher.Write(data)
+
+		if err := throttle(stream.Context(), rateUpload, requester, bytesRead); err != nil {
+			return err
+		}
+		if err := stream.Send(&pb.FileChunk{
+			FileName:    fileName,
+			Data:        data,
+			ChunkNumber: chunkNumber,
+			TotalChunks: totalChunks,
+			TransferId:  transferID,
+			Offset:      offset,
+			FileSize:    fileSize,
+			Crc32C:      crc32.Checksum(data, crc32cTable),
+		}); err != nil {
+			return err
+		}
+
+		offset += int64(bytesRead)
+		sizer.record(bytesRead)
+	}
+
+	// A file that grew while being read would not match its transfer ID
+	if offset != fileSize {
+		return status.Errorf(codes.FailedPrecondition, "%s changed while it was being read", fileName)
+	}
+
+	metadata := entryMetadata(info)
+	return stream.Send(&pb.FileChunk{
+		FileName:    fileName,
+		ChunkNumber: chunkNumber,
+		TotalChunks: totalChunks,
+		TransferId:  transferID,
+		Offset:      offset,
+		FileSize:    fileSize,
+		Crc32C:      crc32.Checksum(nil, crc32cTable),
+		Sha256:      hex.EncodeToString(hasher.Sum(nil)),
+		Mode:        metadata.Mode,
+		Mtime:       metadata.ModTime,
+	})
+}
+
+// statSharedFile asks a peer to describe a file in its shared folder
+func statSharedFile(ctx context.Context, client pb.FileTransferServiceClient, remotePath string) (*pb.SharedFile, error) {
+	list, err := client.ListSharedFiles(ctx, &pb.ListSharedFilesRequest{Path: remotePath})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list.Files) != 1 || list.Files[0].IsDirectory || list.Files[0].Path != remotePath {
+		return nil, status.Errorf(codes.InvalidArgument, "%s is not a file", remotePath)
+	}
+	if !isValidTransferID(list.Files[0].TransferId) {
+		return nil, status.Error(codes.InvalidArgument, "peer sent an invalid transfer ID")
+	}
+
+	return list.Files[0], nil
+}
+
+// runDownload pulls a file from a peer's shared folder as a tracked
+// transfer and records its outcome
+func runDownload(transfer *Transfer, peer *Peer, remote *pb.SharedFile) error {
+	defer transfer.cancel(nil)
+
+	transfer.markRunning()
+	transfer.setFiles([]transferEntry{{SourcePath: remote.Path, RelPath: path.Base(remote.Path), Size: remote.Size}})
+	transfer.startFile(0)
+
+	err := downloadSharedFile(transfer.ctx, peer, transfer, remote)
+	if errors.Is(err, errTransferCancelled) {
+		log.Printf("Download %s cancelled", transfer.ID)
+	} else if err != nil {
+		log.Printf("Download of %s from %s failed: %v", remote.Path, peer.Hostname, err)
+	}
+
+	transfer.finishFile(0, err)
+	transfer.finish(err)
+	return err
+}
+
+// downloadSharedFile pulls a file from a peer's shared folder into the
+// downloads directory, resuming from the partial copy whenever the stream
+// breaks. Progress is recorded on transfer and published as incoming-file
+// events; like a send, the download is aborted when it stalls or runs past
+// the maximum transfer duration.
+func downloadSharedFile(ctx context.Context, peer *Peer, transfer *Transfer, remote *pb.SharedFile) error {
+	cfg := GetConfig()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if cfg.MaxTransferSeconds > 0 {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithTimeoutCause(ctx, time.Duration(cfg.MaxTransferSeconds)*time.Second, errTransferDeadline)
+		defer cancelDeadline()
+	}
+	if cfg.StallTimeoutSeconds > 0 {
+		watchdog := startStallWatchdog(transfer, time.Duration(cfg.StallTimeoutSeconds)*time.Second, cancel)
+		defer watchdog.stop()
+	}
+
+	conn, err := dialPeer(peer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := pb.NewFileTransferServiceClient(conn)
+	localName := path.Base(remote.Path)
+
+	if err := os.MkdirAll(filepath.Join(downloadsDir, partialDir), 0755); err != nil {
+		return fmt.Errorf("failed to create downloads directory: %v", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("Retrying download of %s from %s (attempt %d/%d) after: %v",
+				remote.Path, peer.Hostname, attempt, maxSendAttempts, lastErr)
+
+			select {
+			case <-ctx.Done():
+				return abortedDownloadError(ctx, remote, lastErr)
+			case <-time.After(resumeRetryDelay):
+			}
+		}
+
+		lastErr = pullSharedFile(ctx, client, transfer, remote, localName)
+		if lastErr == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return abortedDownloadError(ctx, remote, lastErr)
+		}
+		if !isRetryableSendError(lastErr) {
+			return lastErr
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %v", maxSendAttempts, lastErr)
+}
+
+// abortedDownloadError explains why a download's context ended. A
+// cancelled download's partial data is dealt with as for a cancelled
+// incoming transfer; after a stall or deadline it is kept to resume.
+func abortedDownloadError(ctx context.Context, remote *pb.SharedFile, lastErr error) error {
+	cause := context.Cause(ctx)
+
+	switch {
+	case errors.Is(cause, errTransferCancelled):
+		if !GetConfig().KeepPartialOnCancel {
+			removePartial(remote.TransferId)
+		}
+		return errTransferCancelled
+	case errors.Is(cause, errTransferStalled), errors.Is(cause, errTransferDeadline):
+		return fmt.Errorf("%w (last error: %v)", cause, lastErr)
+	}
+
+	return lastErr
+}
+
+// pullSharedFile makes one attempt at a download, starting from whatever
+// partial data is already on disk
+func pullSharedFile(ctx context.Context, client pb.FileTransferServiceClient, transfer *Transfer, remote *pb.SharedFile, localName string) error {
+	offset, _ := resumePoint(remote.TransferId, localName, remote.Size)
+
+	state, file, err := openPartial(remote.TransferId, "", localName, remote.Size, offset)
+	if err != nil {
+		return err
+	}
+	receiver := newFileReceiver(state, file, nil)
+	receiver.transfer = transfer
+	transfer.resetProgress(state.BytesReceived)
+
+	stream, err := client.GetFile(ctx, &pb.GetFileRequest{
+		Path:           remote.Path,
+		TransferId:     remote.TransferId,
+		Offset:         state.BytesReceived,
+		ChunksReceived: state.ChunksReceived,
+	})
+	if err != nil {
+		return receiver.abort(err)
+	}
+
+	chunk, err := stream.Recv()
+	if err == io.EOF {
+		err = status.Error(codes.DataLoss, "peer sent no file data")
+	}
+	if err != nil {
+		return receiver.abort(err)
+	}
+
+	if err := receiver.receive(stream, chunk); err != nil {
+		return err
+	}
+
+	// Verifying and flushing the whole file moves no bytes
+	release := transfer.holdStall()
+	response, err := receiver.complete()
+	release()
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return status.Error(codes.DataLoss, response.Message)
+	}
+
+	return nil
+}
+
+// sharedErrorStatus maps an error from a peer's shared folder RPCs to an
+// HTTP status
+func sharedErrorStatus(err error) int {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.InvalidArgument, codes.ResourceExhausted:
+		return http.StatusBadRequest
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return http.StatusForbidden
+	}
+	return http.StatusBadGateway
+}
+
+// sharedPeer finds a paired peer for the shared folder endpoints, writing
+// the error response if there is none
+func sharedPeer(w http.ResponseWriter, r *http.Request) *Peer {
+	peer := GetPeerByID(r.PathValue("id"))
+	if peer == nil {
+		http.Error(w, "Peer not found", http.StatusNotFound)
+		return nil
+	}
+
+	if !isTrustedPeer(peer.ID) {
+		http.Error(w, "Peer is not paired with this device", http.StatusForbidden)
+		return nil
+	}
+
+	return peer
+}
+
+// GetSharedFiles HTTP handler that lists a directory in a peer's shared folder
+func GetSharedFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	peer := sharedPeer(w, r)
+	if peer == nil {
+		return
+	}
+
+	conn, err := dialPeer(peer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	remotePath := r.URL.Query().Get("path")
+	list, err := pb.NewFileTransferServiceClient(conn).ListSharedFiles(ctx, &pb.ListSharedFilesRequest{Path: remotePath})
+	if err != nil {
+		log.Printf("Error listing shared files on %s: %v", peer.Hostname, err)
+		http.Error(w, status.Convert(err).Message(), sharedErrorStatus(err))
+		return
+	}
+
+	files := make([]SharedFileInfo, len(list.Files))
+	for i, file := range list.Files {
+		files[i] = SharedFileInfo{
+			Path:       file.Path,
+			Size:       file.Size,
+			IsDir:      file.IsDirectory,
+			ModTime:    time.Unix(0, file.Mtime).UTC().Format(time.RFC3339),
+			TransferID: file.TransferId,
+		}
+	}
+
+	response := SharedFilesResponse{
+		Peer:  peer.Hostname,
+		Path:  remotePath,
+		Files: files,
+		Count: len(files),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DownloadSharedFile HTTP handler that starts downloading a file from a
+// peer's shared folder into the downloads directory
+func DownloadSharedFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SharedDownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	remotePath, err := sanitizeRelativePath(req.Path)
+	if err != nil {
+		http.Error(w, "Invalid path: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	peer := sharedPeer(w, r)
+	if peer == nil {
+		return
+	}
+
+	conn, err := dialPeer(peer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	// Describe the file now so a bad path fails the request rather than
+	// the background download
+	remote, err := statSharedFile(ctx, pb.NewFileTransferServiceClient(conn), remotePath)
+	if err != nil {
+		log.Printf("Error finding shared file %s on %s: %v", remotePath, peer.Hostname, err)
+		http.Error(w, status.Convert(err).Message(), sharedErrorStatus(err))
+		return
+	}
+
+	// Tracked like a send, so it is listed, cancellable and watched for stalls
+	transfer := newTransfer(peer, []string{remote.Path}, transferOptions{download: true})
+	go runDownload(transfer, peer, remote)
+
+	response := FileTransferResponse{
+		Message:    "File download initiated",
+		TransferID: transfer.ID,
+		Peer:       peer.Hostname,
+		File:       remote.Path,
+		Status:     "receiving",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
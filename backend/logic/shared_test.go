@@ -0,0 +1,221 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// makeSharedDir fills a shared folder in the current directory with a file,
+// a directory and symlinks that stay inside or lead out of it, next to a
+// secret outside it
+func makeSharedDir(t *testing.T) {
+	t.Helper()
+
+	withConfig(t, func(c *Config) { c.SharedDir = "shared" })
+	makeTree(t, ".", map[string]int{
+		"secret.txt":        10,
+		"shared/notes.txt":  100,
+		"shared/docs/a.txt": 200*1024 + 7,
+		"shared/empty/":     0,
+	})
+	links := map[string]string{
+		"shared/escape":     filepath.Join("..", "secret.txt"),
+		"shared/escape-dir": "..",
+		"shared/alias.txt":  "notes.txt",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.FromSlash(link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListSharedFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	makeSharedDir(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		want     []string
+		wantCode codes.Code
+	}{
+		{name: "shared folder", path: "", want: []string{"alias.txt", "docs", "empty", "notes.txt"}},
+		{name: "subdirectory", path: "docs", want: []string{"docs/a.txt"}},
+		{name: "single file", path: "docs/a.txt", want: []string{"docs/a.txt"}},
+		{name: "parent directory", path: "..", wantCode: codes.InvalidArgument},
+		{name: "climbing out", path: "docs/../../secret.txt", wantCode: codes.InvalidArgument},
+		{name: "absolute", path: "/etc", wantCode: codes.InvalidArgument},
+		{name: "symlink out of the folder", path: "escape", wantCode: codes.NotFound},
+		{name: "through a symlink out of the folder", path: "escape-dir/secret.txt", wantCode: codes.NotFound},
+		{name: "missing", path: "missing.txt", wantCode: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := (&fileTransferServer{}).ListSharedFiles(context.Background(), &pb.ListSharedFilesRequest{Path: tt.path})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("ListSharedFiles(%q) error = %v, want %s", tt.path, err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+
+			var got []string
+			for _, file := range list.Files {
+				got = append(got, file.Path)
+				if !file.IsDirectory && !isValidTransferID(file.TransferId) {
+					t.Fatalf("%s listed with transfer ID %q", file.Path, file.TransferId)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("ListSharedFiles(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFile(t *testing.T) {
+	peer := testPeer(t)
+	makeSharedDir(t)
+
+	conn, err := dialPeer(peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewFileTransferServiceClient(conn)
+
+	notes, err := statSharedFile(context.Background(), client, "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *pb.GetFileRequest
+		wantSize int64
+		wantCode codes.Code
+	}{
+		{name: "whole file", req: &pb.GetFileRequest{Path: "notes.txt", TransferId: notes.TransferId}, wantSize: 100},
+		{name: "from an offset", req: &pb.GetFileRequest{Path: "notes.txt", Offset: 60}, wantSize: 40},
+		{name: "symlink inside the folder", req: &pb.GetFileRequest{Path: "alias.txt"}, wantSize: 100},
+		{name: "climbing out", req: &pb.GetFileRequest{Path: "../secret.txt"}, wantCode: codes.InvalidArgument},
+		{name: "absolute", req: &pb.GetFileRequest{Path: "/etc/passwd"}, wantCode: codes.InvalidArgument},
+		{name: "symlink out of the folder", req: &pb.GetFileRequest{Path: "escape"}, wantCode: codes.NotFound},
+		{name: "through a symlink out of the folder", req: &pb.GetFileRequest{Path: "escape-dir/secret.txt"}, wantCode: codes.NotFound},
+		{name: "directory", req: &pb.GetFileRequest{Path: "docs"}, wantCode: codes.NotFound},
+		{name: "changed since listed", req: &pb.GetFileRequest{Path: "notes.txt", TransferId: generateRandomID()}, wantCode: codes.FailedPrecondition},
+		{name: "offset past the end", req: &pb.GetFileRequest{Path: "notes.txt", Offset: 101}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := client.GetFile(context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var received int64
+			var digest string
+			for {
+				chunk, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if status.Code(err) != tt.wantCode {
+						t.Fatalf("GetFile(%q) error = %v, want %s", tt.req.Path, err, tt.wantCode)
+					}
+					return
+				}
+				received += int64(len(chunk.Data))
+				digest = chunk.Sha256
+			}
+
+			if tt.wantCode != codes.OK {
+				t.Fatalf("GetFile(%q) succeeded, want %s", tt.req.Path, tt.wantCode)
+			}
+			if received != tt.wantSize || digest == "" {
+				t.Fatalf("GetFile(%q) sent %d bytes and digest %q, want %d bytes and a digest", tt.req.Path, received, digest, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestDownloadSharedFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancelled bool // cancelled by the user before it starts
+		wantErr   error
+		wantState string
+	}{
+		{name: "completes", wantState: TransferSucceeded},
+		{name: "cancelled", cancelled: true, wantErr: errTransferCancelled, wantState: TransferCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := testPeer(t)
+			withoutTransfers(t)
+			makeSharedDir(t)
+
+			conn, err := dialPeer(peer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			remote, err := statSharedFile(context.Background(), pb.NewFileTransferServiceClient(conn), "docs/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			transfer := newTransfer(peer, []string{remote.Path}, transferOptions{download: true})
+			if tt.cancelled {
+				transfer.cancel(errTransferCancelled)
+			}
+			if err := runDownload(transfer, peer, remote); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("runDownload error = %v, want %v", err, tt.wantErr)
+			}
+
+			transfersMutex.RLock()
+			state, sent, total := transfer.State, transfer.BytesSent, transfer.TotalBytes
+			transfersMutex.RUnlock()
+			if state != tt.wantState {
+				t.Fatalf("download %s, want %s", state, tt.wantState)
+			}
+			if tt.wantErr != nil {
+				if _, err := os.Stat(filepath.Join(downloadsDir, "a.txt")); err == nil {
+					t.Fatal("cancelled download left the file behind")
+				}
+				return
+			}
+			if total != remote.Size || sent != remote.Size {
+				t.Fatalf("download moved %d of %d bytes, want %d", sent, total, remote.Size)
+			}
+
+			want, err := os.ReadFile(filepath.Join("shared", "docs", "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(downloadsDir, "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatal("downloaded file differs from the shared one")
+			}
+		})
+	}
+}
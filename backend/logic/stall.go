@@ -0,0 +1,58 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	errTransferStalled  = errors.New("transfer stalled: no data moved within the stall timeout")
+	errTransferDeadline = errors.New("transfer exceeded the maximum transfer duration")
+)
+
+// stallWatchdog cancels a transfer when its progress stops moving for longer
+// than the stall timeout, however long the transfer as a whole takes
+type stallWatchdog struct {
+	done chan struct{}
+}
+
+// startStallWatchdog watches transfer's byte count and cancels with
+// errTransferStalled once it has not changed for timeout. Time under a
+// holdStall does not count.
+func startStallWatchdog(transfer *Transfer, timeout time.Duration, cancel context.CancelCauseFunc) *stallWatchdog {
+	watchdog := &stallWatchdog{done: make(chan struct{})}
+
+	go func() {
+		ticker := time.NewTicker(timeout / 4)
+		defer ticker.Stop()
+
+		lastBytes := transfer.bytesSent()
+		lastProgress := time.Now()
+
+		for {
+			select {
+			case <-watchdog.done:
+				return
+			case now := <-ticker.C:
+				if bytes := transfer.bytesSent(); bytes != lastBytes || transfer.stallHeld() {
+					lastBytes = bytes
+					lastProgress = now
+					continue
+				}
+
+				if now.Sub(lastProgress) >= timeout {
+					cancel(errTransferStalled)
+					return
+				}
+			}
+		}
+	}()
+
+	return watchdog
+}
+
+// stop ends the watchdog
+func (w *stallWatchdog) stop() {
+	close(w.done)
+}
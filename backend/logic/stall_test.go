@@ -0,0 +1,174 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "backend/proto"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+)
+
+func TestStallWatchdog(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name        string
+		progressFor time.Duration // how long bytes keep moving
+		wait        time.Duration
+		wantStalled bool
+	}{
+		{name: "no progress", wait: 3 * timeout, wantStalled: true},
+		{name: "steady progress", progressFor: 5 * timeout, wait: 5 * timeout},
+		{name: "progress stops", progressFor: 2 * timeout, wait: 5 * timeout, wantStalled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutTransfers(t)
+
+			transfer := newTransfer(&Peer{ID: "peer-1", Hostname: "laptop"}, []string{"report.pdf"}, transferOptions{})
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+
+			transfer.setFiles([]transferEntry{{RelPath: "report.pdf", Size: 1 << 30}})
+			transfer.startFile(0)
+
+			watchdog := startStallWatchdog(transfer, timeout, cancel)
+			defer watchdog.stop()
+
+			start := time.Now()
+			for sent := int64(1); time.Since(start) < tt.progressFor; sent++ {
+				transfer.setProgress(sent)
+				time.Sleep(timeout / 10)
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(tt.wait - time.Since(start)):
+			}
+
+			stalled := errors.Is(context.Cause(ctx), errTransferStalled)
+			if stalled != tt.wantStalled {
+				t.Fatalf("stalled = %v, want %v (cause %v)", stalled, tt.wantStalled, context.Cause(ctx))
+			}
+		})
+	}
+}
+
+func TestStallWatchdogStop(t *testing.T) {
+	withoutTransfers(t)
+
+	transfer := newTransfer(&Peer{ID: "peer-1", Hostname: "laptop"}, []string{"report.pdf"}, transferOptions{})
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	startStallWatchdog(transfer, 50*time.Millisecond, cancel).stop()
+
+	time.Sleep(200 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("stopped watchdog cancelled the transfer: %v", context.Cause(ctx))
+	}
+}
+
+// cancelRecorder is a peer that only records the cancels it is told about
+type cancelRecorder struct {
+	pb.FileTransferServiceClient
+	cancelled []string
+}
+
+func (c *cancelRecorder) CancelTransfer(ctx context.Context, req *pb.CancelRequest, opts ...grpc.CallOption) (*pb.CancelResponse, error) {
+	c.cancelled = append(c.cancelled, req.TransferId)
+	return &pb.CancelResponse{}, nil
+}
+
+func TestThrottleIsNotAStall(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	tests := []struct {
+		name        string
+		tracked     bool // the wait is made on behalf of the transfer
+		wantStalled bool
+	}{
+		{name: "waiting on the limit", tracked: true},
+		{name: "waiting on something else", wantStalled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutTransfers(t)
+
+			// An empty bucket that refills one burst in four stall timeouts
+			previous := globalLimiters
+			globalLimiters = peerLimiters{upload: rate.NewLimiter(rate.Limit(rateLimitBurst)/rate.Limit((4*timeout).Seconds()), rateLimitBurst)}
+			globalLimiters.upload.AllowN(time.Now(), rateLimitBurst)
+			t.Cleanup(func() { globalLimiters = previous })
+
+			transfer := newTransfer(&Peer{ID: "peer-1", Hostname: "laptop"}, []string{"report.pdf"}, transferOptions{})
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+
+			transfer.setFiles([]transferEntry{{RelPath: "report.pdf", Size: 1 << 30}})
+			transfer.startFile(0)
+
+			watchdog := startStallWatchdog(transfer, timeout, cancel)
+			defer watchdog.stop()
+
+			var waiting *Transfer
+			if tt.tracked {
+				waiting = transfer
+			}
+			throttleTransfer(ctx, waiting, rateUpload, "peer-1", rateLimitBurst)
+
+			stalled := errors.Is(context.Cause(ctx), errTransferStalled)
+			if stalled != tt.wantStalled {
+				t.Fatalf("stalled = %v, want %v (cause %v)", stalled, tt.wantStalled, context.Cause(ctx))
+			}
+		})
+	}
+}
+
+func TestAbortedSend(t *testing.T) {
+	lastErr := errors.New("stream broke")
+
+	tests := []struct {
+		name       string
+		cause      error
+		wantErr    error
+		wantReason string
+		wantState  string
+		wantNotify bool
+	}{
+		{name: "cancelled", cause: errTransferCancelled, wantErr: errTransferCancelled, wantReason: "cancelled", wantState: TransferCancelled, wantNotify: true},
+		{name: "stalled", cause: errTransferStalled, wantErr: errTransferStalled, wantReason: "stalled", wantState: TransferFailed},
+		{name: "deadline", cause: errTransferDeadline, wantErr: errTransferDeadline, wantReason: "deadline", wantState: TransferFailed},
+		{name: "other", cause: context.Canceled, wantErr: lastErr, wantReason: "error", wantState: TransferFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutTransfers(t)
+
+			ctx, cancel := context.WithCancelCause(context.Background())
+			cancel(tt.cause)
+
+			client := &cancelRecorder{}
+			transferID := generateRandomID()
+			err := abortedSendError(ctx, client, &Peer{Hostname: "laptop"}, transferID, generateRandomID(), lastErr)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("abortedSendError = %v, want %v", err, tt.wantErr)
+			}
+			if notified := len(client.cancelled) == 1 && client.cancelled[0] == transferID; notified != tt.wantNotify {
+				t.Fatalf("receiver told of the cancel = %v, want %v", notified, tt.wantNotify)
+			}
+
+			transfer := newTransfer(&Peer{ID: "peer-1", Hostname: "laptop"}, []string{"report.pdf"}, transferOptions{})
+			transfer.finish(err)
+			if transfer.State != tt.wantState || transfer.Reason != tt.wantReason {
+				t.Fatalf("transfer finished %s for %q, want %s for %q", transfer.State, transfer.Reason, tt.wantState, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,587 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// SyncFolder is a local directory kept in sync with the same folder ID on
+// other paired peers
+type SyncFolder struct {
+	ID    string   `json:"id"`
+	Path  string   `json:"path"`
+	Peers []string `json:"peers"`
+}
+
+// SyncFolderStatus is a sync folder as reported to the frontend
+type SyncFolderStatus struct {
+	SyncFolder
+	Files     int    `json:"files"`
+	Conflicts int    `json:"conflicts"`
+	LastSync  string `json:"last_sync,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type SyncFoldersResponse struct {
+	Folders []SyncFolderStatus `json:"folders"`
+	Count   int                `json:"count"`
+}
+
+// SyncConflictEvent reports a file edited on two peers at once. The losing
+// version is kept next to it as a conflict copy.
+type SyncConflictEvent struct {
+	FolderID     string `json:"folder_id"`
+	Path         string `json:"path"`
+	ConflictCopy string `json:"conflict_copy"`
+	PeerID       string `json:"peer_id"`
+}
+
+// syncFolderState is a running sync folder
+type syncFolderState struct {
+	SyncFolder
+
+	// Guards index, lastSync and lastError
+	mutex     sync.Mutex
+	index     map[string]*syncFile
+	lastSync  time.Time
+	lastError string
+
+	watcher *fsnotify.Watcher
+	changed chan struct{} // local edits, after the watcher's debounce
+	pull    chan string   // peers announcing changes
+
+	ctx    context.Context // cancelled when the folder is stopped
+	cancel context.CancelFunc
+	done   sync.WaitGroup // run and watch
+}
+
+const (
+	syncFoldersFile = "sync_folders.json"
+
+	// How often every peer of a folder is checked for changes
+	syncInterval = time.Minute
+
+	// Quiet period after a local edit before the folder is rescanned
+	syncDebounce = 2 * time.Second
+
+	// Limit on a single RPC to a sync peer other than a file download
+	syncRPCTimeout = 30 * time.Second
+)
+
+var (
+	syncFolderIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+	syncFolders      = make(map[string]*syncFolderState)
+	syncFoldersMutex sync.RWMutex
+
+	// Paths of folders being started or stopped, by ID, which no other
+	// folder may take meanwhile
+	syncFoldersPending = make(map[string]string)
+
+	errSyncFolderExists   = errors.New("sync folder already exists")
+	errSyncFolderNotFound = errors.New("sync folder not found")
+	errInvalidSyncPath    = errors.New("invalid sync folder path")
+)
+
+// InitSync loads the sync folders from sync_folders.json and starts them
+func InitSync() {
+	data, err := os.ReadFile(syncFoldersFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading %s: %v", syncFoldersFile, err)
+		}
+		return
+	}
+
+	var folders []SyncFolder
+	if err := json.Unmarshal(data, &folders); err != nil {
+		log.Printf("Error decoding %s: %v", syncFoldersFile, err)
+		return
+	}
+
+	started := 0
+	for _, folder := range folders {
+		if _, err := addSyncFolder(folder); err != nil {
+			log.Printf("Not starting sync folder %s: %v", folder.ID, err)
+			continue
+		}
+		started++
+	}
+
+	log.Printf("Started %d sync folders", started)
+}
+
+// addSyncFolder checks a folder's path and starts syncing it. Indexing a
+// large folder takes a while, so it happens outside syncFoldersMutex with
+// the folder's ID and path held in syncFoldersPending.
+func addSyncFolder(folder SyncFolder) (*syncFolderState, error) {
+	syncFoldersMutex.Lock()
+	if _, exists := syncFolders[folder.ID]; exists || syncFoldersPending[folder.ID] != "" {
+		syncFoldersMutex.Unlock()
+		return nil, errSyncFolderExists
+	}
+	folderPath, err := resolveSyncPath(folder.ID, folder.Path)
+	if err != nil {
+		syncFoldersMutex.Unlock()
+		return nil, fmt.Errorf("%w: %v", errInvalidSyncPath, err)
+	}
+	folder.Path = folderPath
+	syncFoldersPending[folder.ID] = folderPath
+	syncFoldersMutex.Unlock()
+
+	state, err := startSyncFolder(folder)
+
+	syncFoldersMutex.Lock()
+	defer syncFoldersMutex.Unlock()
+
+	delete(syncFoldersPending, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+	syncFolders[folder.ID] = state
+	return state, nil
+}
+
+// saveSyncFolders writes the sync folders to sync_folders.json. The caller
+// must hold syncFoldersMutex.
+func saveSyncFolders() {
+	folders := make([]SyncFolder, 0, len(syncFolders))
+	for _, state := range syncFolders {
+		folders = append(folders, state.SyncFolder)
+	}
+	slices.SortFunc(folders, func(a, b SyncFolder) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	data, err := json.MarshalIndent(folders, "", "    ")
+	if err != nil {
+		log.Printf("Error encoding %s: %v", syncFoldersFile, err)
+		return
+	}
+
+	tmpPath := syncFoldersFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err == nil {
+		err = os.Rename(tmpPath, syncFoldersFile)
+	}
+	if err != nil {
+		log.Printf("Error saving %s: %v", syncFoldersFile, err)
+	}
+}
+
+// syncBaseDir returns the directory sync folders must be inside, creating
+// it if needed, with symlinks resolved
+func syncBaseDir() (string, error) {
+	dir, err := expandHome(GetConfig().SyncBaseDir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(dir)
+}
+
+// resolveSyncPath turns a requested sync folder path into an absolute one,
+// taking relative paths from the base directory. A peer can read and
+// overwrite anything in a sync folder, so the folder must be inside the
+// base directory and must not overlap the downloads directory or another
+// sync folder, including one being started or stopped. The caller must hold
+// syncFoldersMutex.
+func resolveSyncPath(folderID, requested string) (string, error) {
+	base, err := syncBaseDir()
+	if err != nil {
+		return "", fmt.Errorf("sync base directory: %v", err)
+	}
+
+	folderPath := requested
+	if !filepath.IsAbs(folderPath) {
+		folderPath = filepath.Join(base, folderPath)
+	}
+	folderPath, err = resolveExistingPath(filepath.Clean(folderPath))
+	if err != nil {
+		return "", err
+	}
+
+	if !isWithinDir(base, folderPath) {
+		return "", fmt.Errorf("%s is not inside the sync base directory %s", folderPath, base)
+	}
+
+	downloads, err := filepath.Abs(downloadsDir)
+	if err == nil {
+		downloads, err = resolveExistingPath(downloads)
+	}
+	if err != nil {
+		return "", err
+	}
+	if isWithinDir(folderPath, downloads) || isWithinDir(downloads, folderPath) {
+		return "", fmt.Errorf("%s overlaps the downloads directory", folderPath)
+	}
+
+	others := make(map[string]string, len(syncFolders)+len(syncFoldersPending))
+	for id, other := range syncFolders {
+		others[id] = other.Path
+	}
+	maps.Copy(others, syncFoldersPending)
+
+	for id, otherPath := range others {
+		if id != folderID && (isWithinDir(folderPath, otherPath) || isWithinDir(otherPath, folderPath)) {
+			return "", fmt.Errorf("%s overlaps sync folder %s", folderPath, id)
+		}
+	}
+
+	return folderPath, nil
+}
+
+// resolveExistingPath resolves symlinks in the part of an absolute path
+// that already exists, so a link can't lead a new folder out of its base
+func resolveExistingPath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	resolvedParent, err := resolveExistingPath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolvedParent, filepath.Base(path)), nil
+}
+
+// isWithinDir reports whether path is dir or below it
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// getSyncFolder returns a running sync folder by ID
+func getSyncFolder(folderID string) *syncFolderState {
+	syncFoldersMutex.RLock()
+	defer syncFoldersMutex.RUnlock()
+
+	return syncFolders[folderID]
+}
+
+// startSyncFolder indexes a folder and starts watching and syncing it
+func startSyncFolder(folder SyncFolder) (*syncFolderState, error) {
+	if err := os.MkdirAll(folder.Path, 0755); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &syncFolderState{
+		SyncFolder: folder,
+		index:      loadSyncIndex(folder.ID),
+		watcher:    watcher,
+		changed:    make(chan struct{}, 1),
+		pull:       make(chan string, len(folder.Peers)+1),
+	}
+	state.ctx, state.cancel = context.WithCancel(context.Background())
+
+	if _, err := state.scan(); err != nil {
+		state.cancel()
+		watcher.Close()
+		return nil, err
+	}
+
+	state.watchDir(folder.Path)
+	state.done.Add(2)
+	go func() {
+		defer state.done.Done()
+		state.watch()
+	}()
+	go func() {
+		defer state.done.Done()
+		state.run()
+	}()
+
+	log.Printf("Syncing folder %s (%s) with %d peers", folder.ID, folder.Path, len(folder.Peers))
+	return state, nil
+}
+
+// close stops syncing the folder, abandoning any download in progress, and
+// waits until nothing more will touch its files or index
+func (f *syncFolderState) close() {
+	f.cancel()
+	f.watcher.Close()
+	f.done.Wait()
+}
+
+// run syncs the folder until it is stopped: local edits are indexed and
+// announced, and peers are pulled from when they announce changes and on
+// every sync interval
+func (f *syncFolderState) run() {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	// Catch up with whatever changed while this node was down
+	f.pullFromPeers(f.Peers)
+
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+
+		case <-f.changed:
+			changed, err := f.scan()
+			if err != nil {
+				log.Printf("Error scanning sync folder %s: %v", f.ID, err)
+				continue
+			}
+			if changed {
+				f.notifyPeers()
+			}
+
+		case peerID := <-f.pull:
+			f.pullFromPeers([]string{peerID})
+
+		case <-ticker.C:
+			f.pullFromPeers(f.Peers)
+		}
+	}
+}
+
+// pullFromPeers brings in changes from each peer that is online
+func (f *syncFolderState) pullFromPeers(peerIDs []string) {
+	for _, peerID := range peerIDs {
+		if f.ctx.Err() != nil {
+			return
+		}
+
+		peer := GetPeerByID(peerID)
+		if peer == nil || !isTrustedPeer(peerID) {
+			continue
+		}
+
+		err := f.syncWithPeer(peer)
+
+		f.mutex.Lock()
+		f.lastSync = time.Now()
+		f.lastError = ""
+		if err != nil {
+			f.lastError = err.Error()
+		}
+		f.mutex.Unlock()
+
+		if err != nil {
+			log.Printf("Error syncing folder %s with %s: %v", f.ID, peer.Hostname, err)
+		}
+	}
+}
+
+// watchDir adds a directory and everything below it to the watcher
+func (f *syncFolderState) watchDir(dir string) {
+	filepath.WalkDir(dir, func(dirPath string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if err := f.watcher.Add(dirPath); err != nil {
+			log.Printf("Error watching %s: %v", dirPath, err)
+		}
+		return nil
+	})
+}
+
+// watch turns filesystem events into rescans, waiting for a quiet period so
+// a burst of writes costs one scan
+func (f *syncFolderState) watch() {
+	debounce := time.NewTimer(syncDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case event, ok := <-f.watcher.Events:
+			if !ok {
+				return
+			}
+
+			// New directories need watching too; inotify is not recursive
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+					f.watchDir(event.Name)
+				}
+			}
+			debounce.Reset(syncDebounce)
+
+		case err, ok := <-f.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error watching sync folder %s: %v", f.ID, err)
+
+		case <-debounce.C:
+			select {
+			case f.changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// status reports the folder's state to the frontend
+func (f *syncFolderState) status() SyncFolderStatus {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	status := SyncFolderStatus{SyncFolder: f.SyncFolder, Error: f.lastError}
+	for _, entry := range f.index {
+		if entry.Deleted {
+			continue
+		}
+		status.Files++
+		if isSyncConflictCopy(entry.Path) {
+			status.Conflicts++
+		}
+	}
+	if !f.lastSync.IsZero() {
+		status.LastSync = f.lastSync.Format(time.RFC3339)
+	}
+	return status
+}
+
+// HandleSyncFolders HTTP handler that lists sync folders (GET) or adds one
+// (POST {id, path, peers})
+func HandleSyncFolders(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		syncFoldersMutex.RLock()
+		folders := make([]SyncFolderStatus, 0, len(syncFolders))
+		for _, state := range syncFolders {
+			folders = append(folders, state.status())
+		}
+		syncFoldersMutex.RUnlock()
+
+		slices.SortFunc(folders, func(a, b SyncFolderStatus) int {
+			return strings.Compare(a.ID, b.ID)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SyncFoldersResponse{Folders: folders, Count: len(folders)})
+
+	case http.MethodPost:
+		var folder SyncFolder
+		if err := json.NewDecoder(r.Body).Decode(&folder); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if !syncFolderIDPattern.MatchString(folder.ID) {
+			http.Error(w, "Invalid folder id: use up to 64 letters, digits, - or _", http.StatusBadRequest)
+			return
+		}
+		if folder.Path == "" || len(folder.Peers) == 0 {
+			http.Error(w, "Missing required fields: path and peers", http.StatusBadRequest)
+			return
+		}
+		for _, peerID := range folder.Peers {
+			if !isTrustedPeer(peerID) {
+				http.Error(w, "Peer is not paired with this device: "+peerID, http.StatusBadRequest)
+				return
+			}
+		}
+
+		state, err := addSyncFolder(folder)
+		switch {
+		case errors.Is(err, errSyncFolderExists):
+			http.Error(w, "Sync folder already exists", http.StatusConflict)
+			return
+		case errors.Is(err, errInvalidSyncPath):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("Error starting sync folder %s: %v", folder.ID, err)
+			http.Error(w, "Failed to start sync folder: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		syncFoldersMutex.Lock()
+		saveSyncFolders()
+		syncFoldersMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(state.status())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSyncFolder HTTP handler that reports a sync folder (GET) or stops
+// syncing it (DELETE). Stopping leaves the folder's files in place.
+func HandleSyncFolder(w http.ResponseWriter, r *http.Request) {
+	folderID := r.PathValue("id")
+
+	switch r.Method {
+	case http.MethodGet:
+		state := getSyncFolder(folderID)
+		if state == nil {
+			http.Error(w, "Sync folder not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state.status())
+
+	case http.MethodDelete:
+		if err := removeSyncFolder(folderID); err != nil {
+			http.Error(w, "Sync folder not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// removeSyncFolder stops syncing a folder and forgets its index
+func removeSyncFolder(folderID string) error {
+	syncFoldersMutex.Lock()
+	state, ok := syncFolders[folderID]
+	if !ok {
+		syncFoldersMutex.Unlock()
+		return errSyncFolderNotFound
+	}
+	delete(syncFolders, folderID)
+	syncFoldersPending[folderID] = state.Path
+	saveSyncFolders()
+	syncFoldersMutex.Unlock()
+
+	// The folder saves its index as it syncs, so the index is only removed
+	// once it has stopped
+	state.close()
+	os.Remove(syncIndexPath(folderID))
+
+	syncFoldersMutex.Lock()
+	delete(syncFoldersPending, folderID)
+	syncFoldersMutex.Unlock()
+
+	log.Printf("Stopped syncing folder %s", folderID)
+	return nil
+}
@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestVersionVectorCompare(t *testing.T) {
+	tests := []struct {
+		name  string
+		v     versionVector
+		other versionVector
+		want  int
+	}{
+		{name: "both empty", v: versionVector{}, other: versionVector{}, want: versionEqual},
+		{name: "same counters", v: versionVector{"a": 2, "b": 1}, other: versionVector{"a": 2, "b": 1}, want: versionEqual},
+		{name: "zero counter equals missing", v: versionVector{"a": 1, "b": 0}, other: versionVector{"a": 1}, want: versionEqual},
+		{name: "newer on one peer", v: versionVector{"a": 3, "b": 1}, other: versionVector{"a": 2, "b": 1}, want: versionNewer},
+		{name: "newer with extra peer", v: versionVector{"a": 1, "b": 1}, other: versionVector{"a": 1}, want: versionNewer},
+		{name: "newer than empty", v: versionVector{"a": 1}, other: versionVector{}, want: versionNewer},
+		{name: "older on one peer", v: versionVector{"a": 1}, other: versionVector{"a": 2}, want: versionOlder},
+		{name: "older missing peer", v: versionVector{"a": 1}, other: versionVector{"a": 1, "b": 4}, want: versionOlder},
+		{name: "concurrent", v: versionVector{"a": 2, "b": 1}, other: versionVector{"a": 1, "b": 2}, want: versionConcurrent},
+		{name: "concurrent disjoint", v: versionVector{"a": 1}, other: versionVector{"b": 1}, want: versionConcurrent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.compare(tt.other); got != tt.want {
+				t.Fatalf("%v.compare(%v) = %d, want %d", tt.v, tt.other, got, tt.want)
+			}
+
+			// The other side must see the mirror image
+			mirror := map[int]int{
+				versionEqual:      versionEqual,
+				versionNewer:      versionOlder,
+				versionOlder:      versionNewer,
+				versionConcurrent: versionConcurrent,
+			}[tt.want]
+			if got := tt.other.compare(tt.v); got != mirror {
+				t.Fatalf("%v.compare(%v) = %d, want %d", tt.other, tt.v, got, mirror)
+			}
+		})
+	}
+}
+
+func TestVersionVectorMerge(t *testing.T) {
+	tests := []struct {
+		name  string
+		v     versionVector
+		other versionVector
+		want  versionVector
+	}{
+		{name: "equal", v: versionVector{"a": 1}, other: versionVector{"a": 1}, want: versionVector{"a": 1}},
+		{name: "newer", v: versionVector{"a": 3, "b": 1}, other: versionVector{"a": 2, "b": 1}, want: versionVector{"a": 3, "b": 1}},
+		{name: "older", v: versionVector{"a": 1}, other: versionVector{"a": 1, "b": 4}, want: versionVector{"a": 1, "b": 4}},
+		{name: "concurrent", v: versionVector{"a": 2, "b": 1}, other: versionVector{"a": 1, "b": 2}, want: versionVector{"a": 2, "b": 2}},
+		{name: "disjoint", v: versionVector{"a": 1}, other: versionVector{"b": 1}, want: versionVector{"a": 1, "b": 1}},
+		{name: "empty", v: versionVector{}, other: versionVector{"a": 1}, want: versionVector{"a": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := maps.Clone(tt.v)
+
+			got := tt.v.merge(tt.other)
+			if !maps.Equal(got, tt.want) {
+				t.Fatalf("%v.merge(%v) = %v, want %v", before, tt.other, got, tt.want)
+			}
+			if !maps.Equal(tt.v, before) {
+				t.Fatalf("merge modified its receiver: %v, was %v", tt.v, before)
+			}
+
+			// The merged vector has seen both sides' changes
+			for _, side := range []versionVector{tt.v, tt.other} {
+				if c := got.compare(side); c != versionEqual && c != versionNewer {
+					t.Fatalf("merged %v compared to %v = %d, want equal or newer", got, side, c)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoteWinsConflict(t *testing.T) {
+	// Each case is one conflict seen from both peers; want names the peer
+	// whose version both of them must keep
+	type version struct {
+		mtime   int64
+		deleted bool
+	}
+	tests := []struct {
+		name string
+		a, b version
+		want string
+	}{
+		{name: "later edit on a", a: version{mtime: 200}, b: version{mtime: 100}, want: "a"},
+		{name: "later edit on b", a: version{mtime: 100}, b: version{mtime: 200}, want: "b"},
+		{name: "mtime tie goes to the higher peer ID", a: version{mtime: 100}, b: version{mtime: 100}, want: "b"},
+		{name: "edit beats later deletion", a: version{mtime: 100}, b: version{deleted: true}, want: "a"},
+		{name: "edit beats deletion on the other side", a: version{deleted: true}, b: version{mtime: 100}, want: "b"},
+		{name: "old edit beats deletion", a: version{mtime: 1}, b: version{mtime: 1 << 62, deleted: true}, want: "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileA := &syncFile{Path: "f.txt", ModTime: tt.a.mtime, Deleted: tt.a.deleted}
+			fileB := &syncFile{Path: "f.txt", ModTime: tt.b.mtime, Deleted: tt.b.deleted}
+
+			// What a keeps when pulling b's version, and b when pulling a's
+			aTakesB := remoteWinsConflict(fileA, syncFileInfo(fileB), "a", "b")
+			bTakesA := remoteWinsConflict(fileB, syncFileInfo(fileA), "b", "a")
+			if aTakesB == bTakesA {
+				t.Fatalf("peers disagree: a takes b's version = %v, b takes a's = %v", aTakesB, bTakesA)
+			}
+
+			got := "a"
+			if aTakesB {
+				got = "b"
+			}
+			if got != tt.want {
+				t.Fatalf("winner = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "backend/proto"
+)
+
+// versionVector counts the changes each peer has made to a file. One vector
+// is newer than another when it has seen every change the other has; when
+// each has changes the other lacks, the edits were concurrent.
+type versionVector map[string]uint64
+
+// Results of comparing two version vectors
+const (
+	versionEqual = iota
+	versionNewer
+	versionOlder
+	versionConcurrent
+)
+
+// syncFile is the index entry for one file in a synced folder. Deleted
+// entries are kept so the deletion can reach other peers.
+type syncFile struct {
+	Path    string        `json:"path"`
+	Size    int64         `json:"size"`
+	ModTime int64         `json:"mtime"` // unix nanoseconds
+	Mode    uint32        `json:"mode"`
+	Sha256  string        `json:"sha256,omitempty"`
+	Deleted bool          `json:"deleted,omitempty"`
+	Version versionVector `json:"version"`
+}
+
+// Directory holding each synced folder's index
+const syncIndexDir = "sync"
+
+// compare orders v against other
+func (v versionVector) compare(other versionVector) int {
+	newer, older := false, false
+
+	for peerID, counter := range v {
+		if counter > other[peerID] {
+			newer = true
+		}
+	}
+	for peerID, counter := range other {
+		if counter > v[peerID] {
+			older = true
+		}
+	}
+
+	switch {
+	case newer && older:
+		return versionConcurrent
+	case newer:
+		return versionNewer
+	case older:
+		return versionOlder
+	}
+	return versionEqual
+}
+
+// merge returns a vector that has seen every change in v and other
+func (v versionVector) merge(other versionVector) versionVector {
+	merged := v.bump("")
+	for peerID, counter := range other {
+		merged[peerID] = max(merged[peerID], counter)
+	}
+	return merged
+}
+
+// bump returns a copy of v with peerID's counter incremented. An empty
+// peerID just copies the vector.
+func (v versionVector) bump(peerID string) versionVector {
+	bumped := make(versionVector, len(v)+1)
+	for id, counter := range v {
+		bumped[id] = counter
+	}
+	if peerID != "" {
+		bumped[peerID]++
+	}
+	return bumped
+}
+
+// syncIndexPath returns where a folder's index is stored
+func syncIndexPath(folderID string) string {
+	return filepath.Join(syncIndexDir, folderID+".json")
+}
+
+// loadSyncIndex reads a folder's index, returning an empty one if none exists
+func loadSyncIndex(folderID string) map[string]*syncFile {
+	index := make(map[string]*syncFile)
+
+	data, err := os.ReadFile(syncIndexPath(folderID))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading sync index for %s: %v", folderID, err)
+		}
+		return index
+	}
+
+	if err := json.Unmarshal(data, &index); err != nil {
+		log.Printf("Error decoding sync index for %s, rebuilding it: %v", folderID, err)
+		return make(map[string]*syncFile)
+	}
+	return index
+}
+
+// saveSyncIndex writes a folder's index. The caller must hold the folder's mutex.
+func saveSyncIndex(folderID string, index map[string]*syncFile) {
+	if err := os.MkdirAll(syncIndexDir, 0755); err != nil {
+		log.Printf("Error creating %s: %v", syncIndexDir, err)
+		return
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		log.Printf("Error encoding sync index for %s: %v", folderID, err)
+		return
+	}
+
+	indexPath := syncIndexPath(folderID)
+	tmpPath := indexPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err == nil {
+		err = os.Rename(tmpPath, indexPath)
+	}
+	if err != nil {
+		log.Printf("Error saving sync index for %s: %v", folderID, err)
+	}
+}
+
+// hashFile returns the hex SHA-256 of a file's contents
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// scan brings the folder's index up to date with the disk, bumping this
+// node's counter for every file added, changed or deleted. Files are only
+// hashed when their size, mtime or mode moved. It reports whether anything
+// changed.
+func (f *syncFolderState) scan() (bool, error) {
+	ownID := GetSystemInfoStruct().PeerID
+	seen := make(map[string]bool)
+	changed := false
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := filepath.WalkDir(f.Path, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if filePath == f.Path {
+				return err
+			}
+			log.Printf("Error scanning %s: %v", filePath, err)
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.Path, filePath)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Only sync what every peer can store under the same name
+		if clean, err := sanitizeRelativePath(rel); err != nil || clean != rel {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		seen[rel] = true
+
+		meta := entryMetadata(info)
+		entry := f.index[rel]
+		if entry != nil && !entry.Deleted && entry.Size == info.Size() && entry.ModTime == meta.ModTime && entry.Mode == meta.Mode {
+			return nil
+		}
+
+		digest, err := hashFile(filePath)
+		if err != nil {
+			log.Printf("Error hashing %s: %v", filePath, err)
+			return nil
+		}
+
+		if entry == nil {
+			entry = &syncFile{Path: rel}
+			f.index[rel] = entry
+		}
+
+		// A touched file with the same contents and mode is not a change
+		if entry.Deleted || entry.Sha256 != digest || entry.Mode != meta.Mode {
+			entry.Version = entry.Version.bump(ownID)
+			changed = true
+		}
+
+		entry.Size = info.Size()
+		entry.ModTime = meta.ModTime
+		entry.Mode = meta.Mode
+		entry.Sha256 = digest
+		entry.Deleted = false
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for rel, entry := range f.index {
+		if !seen[rel] && !entry.Deleted {
+			entry.Deleted = true
+			entry.Size = 0
+			entry.Sha256 = ""
+			entry.Version = entry.Version.bump(ownID)
+			changed = true
+		}
+	}
+
+	// Keep the stat fields of touched files even when nothing else changed
+	saveSyncIndex(f.ID, f.index)
+
+	return changed, nil
+}
+
+// localFileMatches reports whether the file on disk is still the one the
+// index describes, so it can be replaced or deleted without losing an edit
+// the scanner has not seen yet
+func localFileMatches(filePath string, entry *syncFile) bool {
+	info, err := os.Lstat(filePath)
+	if entry == nil || entry.Deleted {
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() && info.Size() == entry.Size && info.ModTime().UnixNano() == entry.ModTime
+}
+
+// syncFileInfo converts an index entry for the wire
+func syncFileInfo(entry *syncFile) *pb.SyncFileInfo {
+	return &pb.SyncFileInfo{
+		Path:    entry.Path,
+		Size:    entry.Size,
+		Mtime:   entry.ModTime,
+		Mode:    entry.Mode,
+		Sha256:  entry.Sha256,
+		Deleted: entry.Deleted,
+		Version: entry.Version,
+	}
+}
+
+// isSyncConflictCopy reports whether a path is a conflict copy made by
+// resolveConflict
+func isSyncConflictCopy(relPath string) bool {
+	return strings.Contains(filepath.Base(relPath), syncConflictMarker)
+}
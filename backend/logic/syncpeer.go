@@ -0,0 +1,444 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Marks the name of a conflict copy, as in "notes.sync-conflict-20250101-120000-abcdefgh.txt"
+const syncConflictMarker = ".sync-conflict-"
+
+// syncServer lets the peers of a sync folder read its index and files
+type syncServer struct {
+	pb.UnimplementedSyncServiceServer
+}
+
+// syncFolderForCaller returns a sync folder the calling peer is a member of
+func syncFolderForCaller(ctx context.Context, folderID string) (*syncFolderState, string, error) {
+	peerID, _, ok := authenticatedPeer(ctx)
+	if !ok {
+		return nil, "", status.Error(codes.Unauthenticated, "no client certificate")
+	}
+
+	folder := getSyncFolder(folderID)
+	if folder == nil || !slices.Contains(folder.Peers, peerID) {
+		return nil, "", status.Errorf(codes.NotFound, "folder %s is not shared with this peer", folderID)
+	}
+	return folder, peerID, nil
+}
+
+// syncTransferID derives the ID a synced file is received under, so an
+// interrupted download of the same version resumes
+func syncTransferID(folderID, relPath, digest string) string {
+	sum := sha256.Sum256([]byte(folderID + "\x00" + relPath + "\x00" + digest))
+	return hex.EncodeToString(sum[:16])
+}
+
+// GetIndex returns a folder's index, including deletions
+func (s *syncServer) GetIndex(ctx context.Context, req *pb.SyncIndexRequest) (*pb.SyncIndex, error) {
+	folder, _, err := syncFolderForCaller(ctx, req.FolderId)
+	if err != nil {
+		return nil, err
+	}
+
+	folder.mutex.Lock()
+	defer folder.mutex.Unlock()
+
+	index := &pb.SyncIndex{Files: make([]*pb.SyncFileInfo, 0, len(folder.index))}
+	for _, entry := range folder.index {
+		index.Files = append(index.Files, syncFileInfo(entry))
+	}
+	return index, nil
+}
+
+// GetSyncFile streams one version of a file in a sync folder. The request
+// names the version by its digest, and fails if the file has moved on.
+func (s *syncServer) GetSyncFile(req *pb.SyncFileRequest, stream pb.SyncService_GetSyncFileServer) error {
+	folder, peerID, err := syncFolderForCaller(stream.Context(), req.FolderId)
+	if err != nil {
+		return err
+	}
+
+	relPath, err := sanitizeRelativePath(req.Path)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	folder.mutex.Lock()
+	entry := folder.index[relPath]
+	var indexed syncFile
+	if entry != nil {
+		indexed = *entry
+	}
+	folder.mutex.Unlock()
+
+	if entry == nil || indexed.Deleted || indexed.Sha256 != req.Sha256 {
+		return status.Errorf(codes.FailedPrecondition, "%s has changed", relPath)
+	}
+
+	root, err := os.OpenRoot(folder.Path)
+	if err != nil {
+		return status.Error(codes.Internal, "sync folder is unavailable")
+	}
+	defer root.Close()
+
+	file, err := root.Open(relPath)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "%s has changed", relPath)
+	}
+	defer file.Close()
+
+	// An edit the scanner hasn't indexed yet would not match the digest
+	info, err := file.Stat()
+	if err != nil || info.Size() != indexed.Size || info.ModTime().UnixNano() != indexed.ModTime {
+		return status.Errorf(codes.FailedPrecondition, "%s has changed", relPath)
+	}
+
+	if req.Offset < 0 || req.Offset > info.Size() {
+		return status.Errorf(codes.InvalidArgument, "offset %d is outside the file", req.Offset)
+	}
+
+	log.Printf("Serving %s/%s to %s from byte %d", folder.ID, relPath, peerID, req.Offset)
+
+	transferID := syncTransferID(folder.ID, relPath, indexed.Sha256)
+	return sendFileChunks(stream, file, info, relPath, transferID, req.Offset, req.ChunksReceived)
+}
+
+// NotifyChanged tells this node a peer has changes to a folder it should pull
+func (s *syncServer) NotifyChanged(ctx context.Context, req *pb.SyncIndexRequest) (*pb.SyncNotifyResponse, error) {
+	folder, peerID, err := syncFolderForCaller(ctx, req.FolderId)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case folder.pull <- peerID:
+	default:
+	}
+	return &pb.SyncNotifyResponse{}, nil
+}
+
+// notifyPeers tells the folder's online peers it has changed
+func (f *syncFolderState) notifyPeers() {
+	for _, peerID := range f.Peers {
+		peer := GetPeerByID(peerID)
+		if peer == nil {
+			continue
+		}
+
+		go func() {
+			conn, err := dialPeer(peer)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(f.ctx, syncRPCTimeout)
+			defer cancel()
+
+			if _, err := pb.NewSyncServiceClient(conn).NotifyChanged(ctx, &pb.SyncIndexRequest{FolderId: f.ID}); err != nil {
+				log.Printf("Failed to notify %s of changes to %s: %v", peer.Hostname, f.ID, err)
+			}
+		}()
+	}
+}
+
+// syncWithPeer pulls every file the peer has a newer version of. Files
+// edited on both sides since they last agreed are resolved by keeping the
+// newer edit under the file's name and the other as a conflict copy.
+func (f *syncFolderState) syncWithPeer(peer *Peer) error {
+	conn, err := dialPeer(peer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := pb.NewSyncServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(f.ctx, syncRPCTimeout)
+	remote, err := client.GetIndex(ctx, &pb.SyncIndexRequest{FolderId: f.ID})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to get index: %w", err)
+	}
+
+	// Compare against the disk as it is now, not as last scanned
+	if changed, err := f.scan(); err != nil {
+		return err
+	} else if changed {
+		f.notifyPeers()
+	}
+
+	slices.SortFunc(remote.Files, func(a, b *pb.SyncFileInfo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	var firstErr error
+	for _, file := range remote.Files {
+		if f.ctx.Err() != nil {
+			return f.ctx.Err()
+		}
+
+		if relPath, err := sanitizeRelativePath(file.Path); err != nil || relPath != file.Path {
+			log.Printf("Ignoring unsafe path %q in %s's index", file.Path, peer.Hostname)
+			continue
+		}
+
+		if err := f.syncFile(client, peer, file); err != nil {
+			log.Printf("Error syncing %s/%s from %s: %v", f.ID, file.Path, peer.Hostname, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// syncFile brings one file in line with a peer's version of it
+func (f *syncFolderState) syncFile(client pb.SyncServiceClient, peer *Peer, remote *pb.SyncFileInfo) error {
+	remoteVersion := versionVector(remote.Version)
+
+	f.mutex.Lock()
+	var local *syncFile
+	if entry := f.index[remote.Path]; entry != nil {
+		copied := *entry
+		local = &copied
+	}
+	f.mutex.Unlock()
+
+	if local == nil {
+		if remote.Deleted {
+			// Remember the deletion so it can't be undone by a stale peer
+			return f.applyDeletion(remote.Path, local, remoteVersion)
+		}
+		return f.pullFile(client, peer, remote, local, remoteVersion)
+	}
+
+	switch local.Version.compare(remoteVersion) {
+	case versionEqual, versionNewer:
+		return nil
+
+	case versionOlder:
+		return f.applyRemote(client, peer, remote, local, remoteVersion)
+	}
+
+	// Concurrent edits that ended up the same need no resolving
+	merged := local.Version.merge(remoteVersion)
+	if local.Deleted == remote.Deleted && local.Sha256 == remote.Sha256 {
+		return f.setVersion(remote.Path, merged)
+	}
+
+	if !remoteWinsConflict(local, remote, GetSystemInfoStruct().PeerID, peer.ID) {
+		// The peer resolves it when it pulls from this node
+		return nil
+	}
+
+	if !local.Deleted {
+		if err := f.keepConflictCopy(peer, local); err != nil {
+			return err
+		}
+	}
+	return f.applyRemote(client, peer, remote, nil, merged)
+}
+
+// remoteWinsConflict reports whether a peer's version of a file edited on
+// both sides replaces the local one. A deletion always loses to an edit.
+// Otherwise the later edit wins, with the peer ID breaking ties so both
+// sides pick the same winner.
+func remoteWinsConflict(local *syncFile, remote *pb.SyncFileInfo, ownID, peerID string) bool {
+	if local.Deleted || remote.Deleted {
+		return local.Deleted
+	}
+	if remote.Mtime != local.ModTime {
+		return remote.Mtime > local.ModTime
+	}
+	return peerID > ownID
+}
+
+// applyRemote takes a peer's version of a file, which replaces local
+func (f *syncFolderState) applyRemote(client pb.SyncServiceClient, peer *Peer, remote *pb.SyncFileInfo, local *syncFile, version versionVector) error {
+	if remote.Deleted {
+		return f.applyDeletion(remote.Path, local, version)
+	}
+
+	// Same contents under a newer version only needs the version
+	if local != nil && !local.Deleted && local.Sha256 == remote.Sha256 {
+		return f.setVersion(remote.Path, version)
+	}
+
+	return f.pullFile(client, peer, remote, local, version)
+}
+
+// setVersion records a new version for a file whose contents are unchanged
+func (f *syncFolderState) setVersion(relPath string, version versionVector) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if entry := f.index[relPath]; entry != nil {
+		entry.Version = version
+		saveSyncIndex(f.ID, f.index)
+	}
+	return nil
+}
+
+// applyDeletion deletes a file a peer deleted, unless it has been edited
+// here since the last scan
+func (f *syncFolderState) applyDeletion(relPath string, local *syncFile, version versionVector) error {
+	filePath, err := pathWithin(f.Path, relPath)
+	if err != nil {
+		return err
+	}
+
+	if local != nil && !local.Deleted {
+		if !localFileMatches(filePath, local) {
+			return fmt.Errorf("%s changed locally, will retry after the next scan", relPath)
+		}
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		log.Printf("Deleted %s/%s to match a peer", f.ID, relPath)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.index[relPath] = &syncFile{Path: relPath, Deleted: true, Version: version}
+	saveSyncIndex(f.ID, f.index)
+	return nil
+}
+
+// keepConflictCopy renames the local version of a file that lost a conflict
+// to a conflict copy, which the next scan picks up and syncs like any file
+func (f *syncFolderState) keepConflictCopy(peer *Peer, local *syncFile) error {
+	filePath, err := pathWithin(f.Path, local.Path)
+	if err != nil {
+		return err
+	}
+
+	if !localFileMatches(filePath, local) {
+		return fmt.Errorf("%s changed locally, will retry after the next scan", local.Path)
+	}
+
+	ext := filepath.Ext(local.Path)
+	ownID := GetSystemInfoStruct().PeerID
+	copyPath := fmt.Sprintf("%s%s%s-%s%s", strings.TrimSuffix(local.Path, ext), syncConflictMarker,
+		time.Now().Format("20060102-150405"), ownID[:min(8, len(ownID))], ext)
+
+	if err := os.Rename(filePath, filepath.Join(f.Path, filepath.FromSlash(copyPath))); err != nil {
+		return fmt.Errorf("failed to keep conflict copy: %v", err)
+	}
+
+	log.Printf("Conflict on %s/%s with %s, kept local version as %s", f.ID, local.Path, peer.Hostname, copyPath)
+	publishEvent(EventSyncConflict, SyncConflictEvent{
+		FolderID:     f.ID,
+		Path:         local.Path,
+		ConflictCopy: copyPath,
+		PeerID:       peer.ID,
+	})
+	return nil
+}
+
+// pullFile downloads a peer's version of a file through the same resumable
+// chunk path as pushed transfers, then records it under version
+func (f *syncFolderState) pullFile(client pb.SyncServiceClient, peer *Peer, remote *pb.SyncFileInfo, local *syncFile, version versionVector) error {
+	filePath, err := pathWithin(f.Path, remote.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(downloadsDir, partialDir), 0755); err != nil {
+		return err
+	}
+
+	transferID := syncTransferID(f.ID, remote.Path, remote.Sha256)
+	displayName := f.ID + "/" + remote.Path
+	offset, _ := resumePoint(transferID, displayName, remote.Size)
+
+	state, file, err := openPartial(transferID, "", displayName, remote.Size, offset)
+	if err != nil {
+		return err
+	}
+	receiver := newFileReceiver(state, file, nil)
+	receiver.target = filePath
+
+	// Downloads have no overall deadline; a broken stream resumes next round
+	ctx, cancel := context.WithCancel(f.ctx)
+	defer cancel()
+
+	stream, err := client.GetSyncFile(ctx, &pb.SyncFileRequest{
+		FolderId:       f.ID,
+		Path:           remote.Path,
+		Sha256:         remote.Sha256,
+		Offset:         state.BytesReceived,
+		ChunksReceived: state.ChunksReceived,
+	})
+	if err != nil {
+		return receiver.abort(err)
+	}
+
+	chunk, err := stream.Recv()
+	if err == io.EOF {
+		err = status.Error(codes.DataLoss, "peer sent no file data")
+	}
+	if err != nil {
+		return receiver.abort(err)
+	}
+
+	if err := receiver.receive(stream, chunk); err != nil {
+		return err
+	}
+
+	// The data must be the version that was indexed, and the local file
+	// must not have been edited while it downloaded
+	if receiver.expectedDigest != remote.Sha256 {
+		err := receiver.abort(status.Errorf(codes.FailedPrecondition, "%s changed on %s", remote.Path, peer.Hostname))
+		removePartial(transferID)
+		return err
+	}
+	if !localFileMatches(filePath, local) {
+		return receiver.abort(fmt.Errorf("%s changed locally, will retry after the next scan", remote.Path))
+	}
+
+	response, err := receiver.complete()
+	if err != nil {
+		return err
+	}
+	if !response.Success {
+		return fmt.Errorf("%s", response.Message)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	meta := entryMetadata(info)
+	f.index[remote.Path] = &syncFile{
+		Path:    remote.Path,
+		Size:    info.Size(),
+		ModTime: meta.ModTime,
+		Mode:    meta.Mode,
+		Sha256:  remote.Sha256,
+		Version: version,
+	}
+	saveSyncIndex(f.ID, f.index)
+
+	log.Printf("Synced %s/%s from %s", f.ID, remote.Path, peer.Hostname)
+	return nil
+}
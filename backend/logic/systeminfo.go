@@ -1,6 +1,7 @@
 package logic
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -30,6 +31,18 @@ const systemInfoFile = "system_info.json"
 
 // InitSystemInfo initializes system info on server startup
 func InitSystemInfo() {
+	// The peer ID comes from the identity key, so load that first
+	key, err := loadIdentityKey()
+	if err != nil {
+		log.Fatalf("Failed to load identity key: %v", err)
+	}
+	identityKey = key
+
+	encryptionKey, err = deriveEncryptionKey(key)
+	if err != nil {
+		log.Fatalf("Failed to derive encryption key: %v", err)
+	}
+
 	// Check if system_info.json exists
 	if _, err := os.Stat(systemInfoFile); os.IsNotExist(err) {
 		log.Println("system_info.json not found, creating new file...")
@@ -39,6 +52,13 @@ func InitSystemInfo() {
 		loadSystemInfoFromFile()
 	}
 
+	// Replace peer IDs from older versions, or ones that no longer match the key
+	if peerID := generatePeerID(); systemInfo.PeerID != peerID {
+		log.Printf("Peer ID %q does not match the identity key, updating to %s", systemInfo.PeerID, peerID)
+		systemInfo.PeerID = peerID
+		saveSystemInfoToFile()
+	}
+
 	log.Printf("System initialized - Hostname: %s, PeerID: %s",
 		systemInfo.Hostname, systemInfo.PeerID)
 }
@@ -72,8 +92,8 @@ func createSystemInfoFile() {
 		osInfo = fmt.Sprintf("%s %s", hostInfo.Platform, hostInfo.PlatformVersion)
 	}
 
-	// Generate unique peer ID
-	peerID := generatePeerID(hostname)
+	// Derive the peer ID from the identity key
+	peerID := generatePeerID()
 
 	// Create system info struct
 	systemInfo = SystemInfo{
@@ -147,17 +167,21 @@ func GetSystemInfo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// generatePeerID creates a unique peer identifier
-func generatePeerID(hostname string) string {
-	// Generate random bytes
-	bytes := make([]byte, 4)
+// generatePeerID returns this node's peer identifier, a fingerprint of its
+// identity key
+func generatePeerID() string {
+	return peerIDFromKey(identityKey.Public().(ed25519.PublicKey))
+}
+
+// generateRandomID creates a random 32 character hex identifier
+func generateRandomID() string {
+	bytes := make([]byte, 16)
 	if _, err := rand.Read(bytes); err != nil {
 		// Fallback to timestamp if random generation fails
-		return fmt.Sprintf("peer_%s_%d", hostname, time.Now().Unix())
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
 	}
 
-	randomStr := hex.EncodeToString(bytes)
-	return fmt.Sprintf("peer_%s_%s", hostname, randomStr)
+	return hex.EncodeToString(bytes)
 }
 
 // GetSystemInfoStruct returns the current system info struct (for internal use)
@@ -0,0 +1,187 @@
+package logic
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	pb "backend/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// withNodeIdentity gives this node a fresh identity key, peer ID,
+// encryption key and certificate for the rest of a test. The key and certificate are written
+// to the current directory, so call it from a scratch directory.
+func withNodeIdentity(tb testing.TB) {
+	tb.Helper()
+
+	previousInfo, previousKey, previousEncryptionKey, previousCert := systemInfo, identityKey, encryptionKey, nodeCertificate
+	tb.Cleanup(func() {
+		systemInfo, identityKey, encryptionKey, nodeCertificate = previousInfo, previousKey, previousEncryptionKey, previousCert
+	})
+
+	key, err := createIdentityKey()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	identityKey = key
+	systemInfo.PeerID = generatePeerID()
+
+	if encryptionKey, err = deriveEncryptionKey(key); err != nil {
+		tb.Fatal(err)
+	}
+
+	cert, err := createNodeCertificate(key, systemInfo.PeerID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	nodeCertificate = cert
+}
+
+// newPeerKey returns a fresh identity key and the peer ID derived from it
+func newPeerKey(t *testing.T) (ed25519.PrivateKey, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, peerIDFromKey(publicKey)
+}
+
+// peerCertificate returns a self-signed certificate for key naming
+// commonName, valid from notBefore for a day, in DER form
+func peerCertificate(t *testing.T, key crypto.Signer, commonName string, notBefore time.Time) []byte {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// authenticatedAs returns ctx as a gRPC handler would see it for a caller
+// that presented the certificate der
+func authenticatedAs(t *testing.T, ctx context.Context, der []byte) context.Context {
+	t.Helper()
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+	})
+}
+
+// fromNewPeer returns ctx as seen by a gRPC handler called by a new peer,
+// and that peer's ID
+func fromNewPeer(t *testing.T, ctx context.Context) (context.Context, string) {
+	t.Helper()
+
+	key, peerID := newPeerKey(t)
+	return authenticatedAs(t, ctx, peerCertificate(t, key, peerID, time.Now().Add(-time.Hour))), peerID
+}
+
+func TestVerifyPeerCertificate(t *testing.T) {
+	key, peerID := newPeerKey(t)
+	_, otherID := newPeerKey(t)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name           string
+		cert           []byte
+		expectedPeerID string
+		wantErr        bool
+	}{
+		{name: "peer ID from its key", cert: peerCertificate(t, key, peerID, now)},
+		{name: "expected peer", cert: peerCertificate(t, key, peerID, now), expectedPeerID: peerID},
+		{name: "another peer than expected", cert: peerCertificate(t, key, peerID, now), expectedPeerID: otherID, wantErr: true},
+		{name: "names another peer's ID", cert: peerCertificate(t, key, otherID, now), wantErr: true},
+		{name: "not an Ed25519 key", cert: peerCertificate(t, ecdsaKey, peerID, now), wantErr: true},
+		{name: "expired", cert: peerCertificate(t, key, peerID, now.Add(-48*time.Hour)), wantErr: true},
+		{name: "not yet valid", cert: peerCertificate(t, key, peerID, now.Add(48*time.Hour)), wantErr: true},
+		{name: "no certificate", wantErr: true},
+		{name: "garbage", cert: []byte("not a certificate"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rawCerts [][]byte
+			if tt.cert != nil {
+				rawCerts = [][]byte{tt.cert}
+			}
+
+			got, err := verifyPeerCertificate(rawCerts, tt.expectedPeerID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("verifyPeerCertificate accepted %s, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyPeerCertificate: %v", err)
+			}
+			if got != peerID {
+				t.Fatalf("verifyPeerCertificate = %q, want %q", got, peerID)
+			}
+		})
+	}
+}
+
+func TestOfferFileAuthenticatesSender(t *testing.T) {
+	anotherPeer, _ := fromNewPeer(t, context.Background())
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{name: "no certificate", ctx: context.Background()},
+		{name: "certificate for another peer", ctx: anotherPeer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offer := &pb.FileOffer{OfferId: generateRandomID(), FileName: "a.txt", SenderPeerId: "peer-1"}
+			_, err := (&fileTransferServer{}).OfferFile(tt.ctx, offer)
+			if status.Code(err) != codes.Unauthenticated {
+				t.Fatalf("OfferFile error = %v, want %s", err, codes.Unauthenticated)
+			}
+		})
+	}
+}
+
+func TestSendChecksPeerIdentity(t *testing.T) {
+	peer := testPeer(t)
+	makeTree(t, ".", map[string]int{"notes.txt": 10})
+
+	// Someone else answering at the address discovered for a peer
+	_, peer.ID = newPeerKey(t)
+
+	if transfer := sendPaths(t, peer, "notes.txt"); transfer.State != TransferFailed {
+		t.Fatalf("transfer to the wrong peer %s, want %s", transfer.State, TransferFailed)
+	}
+}
@@ -0,0 +1,143 @@
+package logic
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// transferEntry is one file, directory or link in an outgoing transfer
+type transferEntry struct {
+	SourcePath     string
+	RelPath        string // slash-separated path recreated under the receiver's downloads dir
+	IsDir          bool
+	Size           int64
+	Metadata       fileMetadata
+	SymlinkTarget  string // set for symlinks, which are sent rather than followed
+	HardlinkTarget string // RelPath of an earlier entry sharing this file's inode
+}
+
+// fileKey identifies a file by device and inode number
+type fileKey struct {
+	dev, ino uint64
+}
+
+// buildTransferPlan expands the requested files and directories into the
+// ordered list of entries to send. Directories are listed before their
+// contents so empty directories are recreated too. Symlinks inside a
+// directory are sent as links, and files with several links inside the
+// transfer are sent once with the other paths as hardlinks to it.
+func buildTransferPlan(paths []string) ([]transferEntry, error) {
+	var entries []transferEntry
+	seen := make(map[string]bool)
+	inodes := make(map[fileKey]string)
+
+	add := func(entry transferEntry) error {
+		if _, err := sanitizeRelativePath(entry.RelPath); err != nil {
+			return fmt.Errorf("cannot send %s: %v", entry.SourcePath, err)
+		}
+		if seen[entry.RelPath] {
+			return fmt.Errorf("duplicate path in transfer: %s", entry.RelPath)
+		}
+		seen[entry.RelPath] = true
+		entries = append(entries, entry)
+		return nil
+	}
+
+	addFile := func(sourcePath, relPath string, info fs.FileInfo) error {
+		entry := transferEntry{
+			SourcePath: sourcePath,
+			RelPath:    relPath,
+			Size:       info.Size(),
+			Metadata:   entryMetadata(info),
+		}
+		if key, ok := hardlinkKey(info); ok {
+			if first, ok := inodes[key]; ok {
+				entry.Size = 0
+				entry.HardlinkTarget = first
+			} else {
+				inodes[key] = relPath
+			}
+		}
+		return add(entry)
+	}
+
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		rootName := filepath.Base(root)
+
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", root, err)
+		}
+
+		if !info.IsDir() {
+			if !info.Mode().IsRegular() {
+				return nil, fmt.Errorf("%s is not a regular file", root)
+			}
+			if err := addFile(root, rootName, info); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, filePath)
+			if err != nil {
+				return err
+			}
+			relPath := path.Join(rootName, filepath.ToSlash(rel))
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case d.IsDir():
+				return add(transferEntry{SourcePath: filePath, RelPath: relPath, IsDir: true, Metadata: entryMetadata(info)})
+			case d.Type().IsRegular():
+				return addFile(filePath, relPath, info)
+			case d.Type()&fs.ModeSymlink != 0:
+				target, err := os.Readlink(filePath)
+				if err != nil {
+					return err
+				}
+				// The receiver refuses links that leave the transfer, so
+				// don't let one sink the whole job
+				if err := validateSymlinkTarget(relPath, filepath.ToSlash(target)); err != nil {
+					log.Printf("Skipping symlink %s: %v", filePath, err)
+					return nil
+				}
+				return add(transferEntry{SourcePath: filePath, RelPath: relPath, Metadata: entryMetadata(info), SymlinkTarget: filepath.ToSlash(target)})
+			default:
+				log.Printf("Skipping %s: not a regular file, directory or symlink", filePath)
+				return nil
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("nothing to send")
+	}
+
+	return entries, nil
+}
+
+// describePaths returns a short display name for a set of requested paths
+func describePaths(paths []string) string {
+	if len(paths) == 1 {
+		return filepath.Base(paths[0])
+	}
+	return fmt.Sprintf("%s and %d more", filepath.Base(paths[0]), len(paths)-1)
+}
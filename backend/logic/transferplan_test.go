@@ -0,0 +1,124 @@
+package logic
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// makeTree creates files (with their sizes) and directories, named by
+// slash-separated paths ending in "/", under dir
+func makeTree(t *testing.T, dir string, entries map[string]int) {
+	t.Helper()
+
+	for name, size := range entries {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildTransferPlan(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, map[string]int{
+		"notes.txt":                  5,
+		"project/main.go":            10,
+		"project/src/util.go":        20,
+		"project/empty/":             0,
+		"other/notes.txt":            7,
+		"project/docs/readme.md":     3,
+		"project/docs/img/":          0,
+		"project/docs/img/logo.webp": 4,
+	})
+	if err := os.Symlink("main.go", filepath.Join(dir, "project", "link.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../notes.txt", filepath.Join(dir, "project", "outside.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "project", "docs", "readme.md"), filepath.Join(dir, "project", "docs", "copy.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    []string // relative paths, directories ending in "/", symlinks in "-> target" and hardlinks in "=> target"
+		wantErr bool
+	}{
+		{name: "single file", paths: []string{"notes.txt"}, want: []string{"notes.txt"}},
+		{
+			// project/outside.txt links out of the transfer and is skipped
+			name:  "directory tree",
+			paths: []string{"project"},
+			want: []string{
+				"project/", "project/docs/", "project/docs/copy.md", "project/docs/img/", "project/docs/img/logo.webp",
+				"project/docs/readme.md => project/docs/copy.md", "project/empty/", "project/link.go -> main.go",
+				"project/main.go", "project/src/", "project/src/util.go",
+			},
+		},
+		{name: "several paths", paths: []string{"notes.txt", "project/src"}, want: []string{"notes.txt", "src/", "src/util.go"}},
+		{name: "same name twice", paths: []string{"notes.txt", "other/notes.txt"}, wantErr: true},
+		{name: "missing path", paths: []string{"missing.txt"}, wantErr: true},
+		{name: "nothing", paths: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			for _, path := range tt.paths {
+				paths = append(paths, filepath.Join(dir, path))
+			}
+
+			entries, err := buildTransferPlan(paths)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("buildTransferPlan(%q) = %d entries, want error", tt.paths, len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildTransferPlan(%q): %v", tt.paths, err)
+			}
+
+			var got []string
+			for _, entry := range entries {
+				if entry.SymlinkTarget != "" {
+					got = append(got, entry.RelPath+" -> "+entry.SymlinkTarget)
+					continue
+				}
+				if entry.HardlinkTarget != "" {
+					got = append(got, entry.RelPath+" => "+entry.HardlinkTarget)
+					continue
+				}
+				info, err := os.Stat(entry.SourcePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if entry.IsDir {
+					got = append(got, entry.RelPath+"/")
+					continue
+				}
+				if entry.Size != info.Size() {
+					t.Fatalf("%s planned with %d bytes, want %d", entry.RelPath, entry.Size, info.Size())
+				}
+				got = append(got, entry.RelPath)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("buildTransferPlan(%q) = %q, want %q", tt.paths, got, tt.want)
+			}
+		})
+	}
+}
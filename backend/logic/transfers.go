@@ -0,0 +1,496 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Transfer states
+const (
+	TransferQueued    = "queued"
+	TransferRunning   = "running"
+	TransferSucceeded = "succeeded"
+	TransferFailed    = "failed"
+	TransferCancelled = "cancelled"
+)
+
+type Transfer struct {
+	ID         string         `json:"id"`
+	PeerID     string         `json:"peer_id"`
+	Peer       string         `json:"peer"`
+	File       string         `json:"file"` // display name
+	Paths      []string       `json:"paths"`
+	Files      []TransferFile `json:"files"`
+	State      string         `json:"state"`
+	BytesSent  int64          `json:"bytes_sent"`
+	TotalBytes int64          `json:"total_bytes"`
+	Rate       float64        `json:"rate"` // bytes per second
+	Error      string         `json:"error,omitempty"`
+	Reason     string         `json:"reason,omitempty"` // why a transfer did not succeed
+	CreatedAt  string         `json:"created_at"`
+	StartedAt  string         `json:"started_at,omitempty"`
+	FinishedAt string         `json:"finished_at,omitempty"`
+	Encrypted  bool           `json:"encrypted"`   // chunks sealed end to end for the receiver
+	Delta      bool           `json:"delta"`       // only blocks the receiver lacks are sent
+	BytesSaved int64          `json:"bytes_saved"` // bytes the receiver reused from its own copies
+	Streams    int            `json:"streams,omitempty"`
+	Download   bool           `json:"download,omitempty"` // pulled from the peer's shared folder rather than sent
+
+	// Codec the receiver agreed to, and file data sent before and after
+	// compression
+	Compression     string `json:"compression,omitempty"`
+	RawBytes        int64  `json:"raw_bytes"`
+	CompressedBytes int64  `json:"compressed_bytes"`
+
+	currentFile     int
+	stallHolds      int // work in progress that moves no bytes
+	completedBytes  int64
+	lastSampleTime  time.Time
+	lastSampleBytes int64
+	ctx             context.Context
+	cancel          context.CancelCauseFunc
+}
+
+// TransferFile tracks one file or directory within a transfer
+type TransferFile struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	IsDir     bool   `json:"is_dir,omitempty"`
+	BytesSent int64  `json:"bytes_sent"`
+	State     string `json:"state"`
+	Error     string `json:"error,omitempty"`
+}
+
+type TransfersResponse struct {
+	Transfers []Transfer `json:"transfers"`
+	Count     int        `json:"count"`
+}
+
+var (
+	errTransferCancelled = errors.New("transfer cancelled")
+	errTransferNotFound  = errors.New("transfer not found")
+	errTransferFinished  = errors.New("transfer already finished")
+	errOfferDeclined     = errors.New("declined")
+	errOfferTimedOut     = errors.New("did not answer the offer in time")
+)
+
+var (
+	transfers      = make(map[string]*Transfer)
+	transferOrder  []string
+	transfersMutex sync.RWMutex
+)
+
+const (
+	// Finished transfers kept for the API before the oldest are dropped
+	maxTransferHistory = 200
+
+	// Minimum interval between rate samples
+	rateSampleInterval = 500 * time.Millisecond
+)
+
+// transferOptions selects how a transfer's files are sent
+type transferOptions struct {
+	encrypt  bool
+	delta    bool
+	streams  int  // concurrent streams for large files; 0 or 1 sends one at a time
+	download bool // pulls a file from the peer's shared folder
+}
+
+// newTransfer registers a queued transfer of paths to peer
+func newTransfer(peer *Peer, paths []string, options transferOptions) *Transfer {
+	transfer := &Transfer{
+		ID:        generateRandomID(),
+		PeerID:    peer.ID,
+		Peer:      peer.Hostname,
+		File:      describePaths(paths),
+		Paths:     paths,
+		State:     TransferQueued,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Encrypted: options.encrypt,
+		Delta:     options.delta,
+		Streams:   options.streams,
+		Download:  options.download,
+	}
+	transfer.ctx, transfer.cancel = context.WithCancelCause(context.Background())
+
+	transfersMutex.Lock()
+	transfers[transfer.ID] = transfer
+	transferOrder = append(transferOrder, transfer.ID)
+	pruneTransferHistory()
+	snapshot := transfer.copy()
+	transfersMutex.Unlock()
+
+	publishEvent(EventTransferState, snapshot)
+
+	return transfer
+}
+
+// pruneTransferHistory drops the oldest finished transfers beyond the limit.
+// Callers must hold transfersMutex.
+func pruneTransferHistory() {
+	excess := len(transferOrder) - maxTransferHistory
+	if excess <= 0 {
+		return
+	}
+
+	kept := transferOrder[:0]
+	for _, id := range transferOrder {
+		transfer := transfers[id]
+		if excess > 0 && transfer.isFinished() {
+			delete(transfers, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	transferOrder = kept
+}
+
+// runTransfer executes a queued transfer, records its outcome and returns
+// the error it failed with
+func runTransfer(transfer *Transfer, peer *Peer) error {
+	defer transfer.cancel(nil)
+
+	// Cancelled while still queued
+	if transfer.ctx.Err() != nil {
+		err := context.Cause(transfer.ctx)
+		transfer.finish(err)
+		return err
+	}
+
+	transfer.markRunning()
+
+	err := sendFileToP2P(transfer.ctx, peer, transfer)
+	if errors.Is(err, errTransferCancelled) {
+		log.Printf("File transfer %s cancelled", transfer.ID)
+	} else if err != nil {
+		log.Printf("File transfer %s failed: %v", transfer.ID, err)
+	}
+
+	transfer.finish(err)
+	return err
+}
+
+// cancelTransfer stops a queued or running transfer
+func cancelTransfer(id string) (*Transfer, error) {
+	transfersMutex.RLock()
+	transfer, ok := transfers[id]
+	finished := ok && transfer.isFinished()
+	transfersMutex.RUnlock()
+
+	if !ok {
+		return nil, errTransferNotFound
+	}
+	if finished {
+		return nil, errTransferFinished
+	}
+
+	log.Printf("Cancelling transfer %s", id)
+	transfer.cancel(errTransferCancelled)
+
+	return transfer, nil
+}
+
+// isFinished reports whether the transfer has reached a terminal state.
+// Callers must hold transfersMutex.
+func (t *Transfer) isFinished() bool {
+	return t.State == TransferSucceeded || t.State == TransferFailed || t.State == TransferCancelled
+}
+
+// markRunning moves the transfer into the running state
+func (t *Transfer) markRunning() {
+	transfersMutex.Lock()
+	now := time.Now()
+	t.State = TransferRunning
+	t.StartedAt = now.Format(time.RFC3339)
+	t.lastSampleTime = now
+	snapshot := t.copy()
+	transfersMutex.Unlock()
+
+	publishEvent(EventTransferState, snapshot)
+}
+
+// copy returns a snapshot of the transfer that is safe to encode after the
+// lock is released. Callers must hold transfersMutex.
+func (t *Transfer) copy() Transfer {
+	snapshot := *t
+	snapshot.Paths = append([]string(nil), t.Paths...)
+	snapshot.Files = append([]TransferFile(nil), t.Files...)
+	return snapshot
+}
+
+// bytesSent returns the current progress of the transfer
+func (t *Transfer) bytesSent() int64 {
+	transfersMutex.RLock()
+	defer transfersMutex.RUnlock()
+
+	return t.BytesSent
+}
+
+// holdStall stops the stall watchdog counting time until the returned
+// release is called, for work that moves no bytes, such as the receiver
+// verifying and flushing a finished file
+func (t *Transfer) holdStall() func() {
+	transfersMutex.Lock()
+	t.stallHolds++
+	transfersMutex.Unlock()
+
+	return func() {
+		transfersMutex.Lock()
+		t.stallHolds--
+		transfersMutex.Unlock()
+	}
+}
+
+// stallHeld reports whether the transfer is doing work that moves no bytes
+func (t *Transfer) stallHeld() bool {
+	transfersMutex.RLock()
+	defer transfersMutex.RUnlock()
+
+	return t.stallHolds > 0
+}
+
+// setFiles records the files and directories the transfer will send
+func (t *Transfer) setFiles(entries []transferEntry) {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	t.Files = make([]TransferFile, len(entries))
+	t.TotalBytes = 0
+	for i, entry := range entries {
+		t.Files[i] = TransferFile{
+			Path:  entry.RelPath,
+			Size:  entry.Size,
+			IsDir: entry.IsDir,
+			State: TransferQueued,
+		}
+		t.TotalBytes += entry.Size
+	}
+}
+
+// startFile marks the file at index as the one being sent
+func (t *Transfer) startFile(index int) {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	t.currentFile = index
+	t.Files[index].State = TransferRunning
+}
+
+// finishFile records the outcome of the file at index
+func (t *Transfer) finishFile(index int, err error) {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	file := &t.Files[index]
+	switch {
+	case err == nil:
+		file.State = TransferSucceeded
+		file.BytesSent = file.Size
+		t.completedBytes += file.Size
+		t.BytesSent = t.completedBytes
+	case errors.Is(err, errTransferCancelled):
+		file.State = TransferCancelled
+	default:
+		file.State = TransferFailed
+		file.Error = err.Error()
+	}
+}
+
+// resetProgress records where an attempt on the current file starts, e.g.
+// the receiver's resume offset, without counting those bytes towards the rate
+func (t *Transfer) resetProgress(fileOffset int64) {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	t.Files[t.currentFile].BytesSent = fileOffset
+	t.BytesSent = t.completedBytes + fileOffset
+	t.lastSampleTime = time.Now()
+	t.lastSampleBytes = t.BytesSent
+}
+
+// addBytesSaved records bytes a delta transfer did not have to send
+func (t *Transfer) addBytesSaved(n int64) {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	t.BytesSaved += n
+}
+
+// setCompression records the codec the receiver chose for chunk data
+func (t *Transfer) setCompression(codec string) {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	t.Compression = codec
+}
+
+// addCompressedBytes records a chunk's file data before and after compression
+func (t *Transfer) addCompressedBytes(raw, compressed int64) {
+	transfersMutex.Lock()
+	defer transfersMutex.Unlock()
+
+	t.RawBytes += raw
+	t.CompressedBytes += compressed
+}
+
+// setProgress records how much of the current file the receiver holds and
+// updates the smoothed transfer rate, publishing a progress event on each
+// rate sample
+func (t *Transfer) setProgress(fileOffset int64) {
+	transfersMutex.Lock()
+	t.Files[t.currentFile].BytesSent = fileOffset
+	t.BytesSent = t.completedBytes + fileOffset
+	bytesSent := t.BytesSent
+
+	now := time.Now()
+	elapsed := now.Sub(t.lastSampleTime)
+	if elapsed < rateSampleInterval {
+		transfersMutex.Unlock()
+		return
+	}
+
+	current := float64(bytesSent-t.lastSampleBytes) / elapsed.Seconds()
+	if t.Rate == 0 {
+		t.Rate = current
+	} else {
+		t.Rate = 0.3*current + 0.7*t.Rate
+	}
+	t.lastSampleTime = now
+	t.lastSampleBytes = bytesSent
+	snapshot := t.copy()
+	transfersMutex.Unlock()
+
+	publishEvent(EventTransferProgress, snapshot)
+}
+
+// finish moves the transfer into its terminal state
+func (t *Transfer) finish(err error) {
+	transfersMutex.Lock()
+	t.FinishedAt = time.Now().Format(time.RFC3339)
+	t.Rate = 0
+
+	switch {
+	case err == nil:
+		t.State = TransferSucceeded
+		t.BytesSent = t.TotalBytes
+	case errors.Is(err, errTransferCancelled):
+		t.State = TransferCancelled
+		t.Error = err.Error()
+		t.Reason = "cancelled"
+	case errors.Is(err, errTransferStalled):
+		t.State = TransferFailed
+		t.Error = err.Error()
+		t.Reason = "stalled"
+	case errors.Is(err, errTransferDeadline):
+		t.State = TransferFailed
+		t.Error = err.Error()
+		t.Reason = "deadline"
+	case errors.Is(err, errOfferDeclined):
+		t.State = TransferFailed
+		t.Error = err.Error()
+		t.Reason = "declined"
+	default:
+		t.State = TransferFailed
+		t.Error = err.Error()
+		t.Reason = "error"
+	}
+	snapshot := t.copy()
+	transfersMutex.Unlock()
+
+	publishEvent(EventTransferState, snapshot)
+}
+
+// GetTransfers HTTP handler that returns all tracked transfers, newest first
+func GetTransfers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	transfersMutex.RLock()
+	list := make([]Transfer, 0, len(transferOrder))
+	for i := len(transferOrder) - 1; i >= 0; i-- {
+		list = append(list, transfers[transferOrder[i]].copy())
+	}
+	transfersMutex.RUnlock()
+
+	response := TransfersResponse{
+		Transfers: list,
+		Count:     len(list),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding transfers response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleTransfer HTTP handler for a single transfer: GET returns it and
+// DELETE cancels it
+func HandleTransfer(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getTransfer(w, r)
+	case http.MethodDelete:
+		deleteTransfer(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getTransfer returns a single transfer by ID
+func getTransfer(w http.ResponseWriter, r *http.Request) {
+	transfersMutex.RLock()
+	transfer, ok := transfers[r.PathValue("id")]
+	var response Transfer
+	if ok {
+		response = transfer.copy()
+	}
+	transfersMutex.RUnlock()
+
+	if !ok {
+		http.Error(w, "Transfer not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding transfer response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// deleteTransfer cancels a queued or running transfer
+func deleteTransfer(w http.ResponseWriter, r *http.Request) {
+	transfer, err := cancelTransfer(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errTransferNotFound):
+		http.Error(w, "Transfer not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errTransferFinished):
+		http.Error(w, "Transfer already finished", http.StatusConflict)
+		return
+	}
+
+	response := FileTransferResponse{
+		Message:    "File transfer cancellation requested",
+		TransferID: transfer.ID,
+		Peer:       transfer.Peer,
+		File:       transfer.File,
+		Status:     TransferCancelled,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
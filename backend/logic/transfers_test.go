@@ -0,0 +1,231 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// withoutTransfers gives a test an empty transfer list
+func withoutTransfers(t *testing.T) {
+	transfersMutex.Lock()
+	previous, previousOrder := transfers, transferOrder
+	transfers, transferOrder = make(map[string]*Transfer), nil
+	transfersMutex.Unlock()
+
+	t.Cleanup(func() {
+		transfersMutex.Lock()
+		transfers, transferOrder = previous, previousOrder
+		transfersMutex.Unlock()
+	})
+}
+
+// getJSON calls a GET handler and decodes its JSON response into v
+func getJSON(t *testing.T, handler http.HandlerFunc, target string, pathValues map[string]string, v any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range pathValues {
+		req.SetPathValue(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, req)
+
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+			t.Fatalf("decoding %s: %v", target, err)
+		}
+	}
+	return recorder.Code
+}
+
+func TestTransferLifecycle(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error // outcome of the second file
+		wantState     string
+		wantBytes     int64
+		wantError     string
+		wantFileState string
+	}{
+		{name: "succeeded", wantState: TransferSucceeded, wantBytes: 1500, wantFileState: TransferSucceeded},
+		{name: "failed", err: errors.New("peer went away"), wantState: TransferFailed, wantBytes: 1100, wantError: "peer went away", wantFileState: TransferFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutTransfers(t)
+
+			transfer := newTransfer(&Peer{ID: "peer-1", Hostname: "laptop"}, []string{"project", "notes.txt"}, transferOptions{})
+			if transfer.State != TransferQueued || transfer.Peer != "laptop" || transfer.File != "project and 1 more" {
+				t.Fatalf("new transfer = %+v, want project and 1 more queued to laptop", *transfer)
+			}
+
+			transfer.markRunning()
+			transfer.setFiles([]transferEntry{
+				{RelPath: "project/a.bin", Size: 1000},
+				{RelPath: "notes.txt", Size: 500},
+			})
+			if transfer.TotalBytes != 1500 {
+				t.Fatalf("total = %d, want 1500", transfer.TotalBytes)
+			}
+
+			transfer.startFile(0)
+			transfer.setProgress(1000)
+			transfer.finishFile(0, nil)
+
+			transfer.startFile(1)
+			transfer.resetProgress(40) // resumed from the receiver's copy
+			if transfer.State != TransferRunning || transfer.BytesSent != 1040 || transfer.Files[1].BytesSent != 40 {
+				t.Fatalf("resumed transfer = %+v, want running at 1040 bytes", *transfer)
+			}
+			transfer.setProgress(100)
+			transfer.finishFile(1, tt.err)
+			transfer.finish(tt.err)
+
+			if transfer.State != tt.wantState || transfer.BytesSent != tt.wantBytes || transfer.Error != tt.wantError {
+				t.Fatalf("finished transfer = %+v, want %s at %d bytes with error %q",
+					*transfer, tt.wantState, tt.wantBytes, tt.wantError)
+			}
+			if transfer.FinishedAt == "" || transfer.Rate != 0 {
+				t.Fatalf("finished transfer = %+v, want a finish time and no rate", *transfer)
+			}
+			if transfer.Files[0].State != TransferSucceeded || transfer.Files[1].State != tt.wantFileState {
+				t.Fatalf("files = %+v, want the first succeeded and the second %s", transfer.Files, tt.wantFileState)
+			}
+		})
+	}
+}
+
+func TestGetTransfers(t *testing.T) {
+	withoutTransfers(t)
+
+	peer := &Peer{ID: "peer-1", Hostname: "laptop"}
+	first := newTransfer(peer, []string{"first.txt"}, transferOptions{})
+	second := newTransfer(peer, []string{"second.txt"}, transferOptions{})
+
+	var list TransfersResponse
+	if code := getJSON(t, GetTransfers, "/api/transfers", nil, &list); code != http.StatusOK {
+		t.Fatalf("GET /api/transfers returned %d", code)
+	}
+	if list.Count != 2 || list.Transfers[0].ID != second.ID || list.Transfers[1].ID != first.ID {
+		t.Fatalf("GET /api/transfers = %+v, want the second transfer before the first", list)
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+	}{
+		{name: "known", id: first.ID, wantCode: http.StatusOK},
+		{name: "unknown", id: generateRandomID(), wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Transfer
+			code := getJSON(t, HandleTransfer, "/api/transfers/"+tt.id, map[string]string{"id": tt.id}, &got)
+			if code != tt.wantCode {
+				t.Fatalf("GET /api/transfers/%s returned %d, want %d", tt.id, code, tt.wantCode)
+			}
+			if code == http.StatusOK && (got.ID != tt.id || got.File != "first.txt" || got.Paths[0] != "first.txt") {
+				t.Fatalf("GET /api/transfers/%s = %+v", tt.id, got)
+			}
+		})
+	}
+}
+
+func TestPruneTransferHistory(t *testing.T) {
+	withoutTransfers(t)
+
+	peer := &Peer{ID: "peer-1", Hostname: "laptop"}
+	running := newTransfer(peer, []string{"running.txt"}, transferOptions{})
+	running.markRunning()
+
+	var finished []*Transfer
+	for range maxTransferHistory {
+		transfer := newTransfer(peer, []string{"done.txt"}, transferOptions{})
+		transfer.finish(nil)
+		finished = append(finished, transfer)
+	}
+	newTransfer(peer, []string{"queued.txt"}, transferOptions{})
+
+	transfersMutex.RLock()
+	defer transfersMutex.RUnlock()
+
+	if len(transferOrder) != maxTransferHistory || len(transfers) != maxTransferHistory {
+		t.Fatalf("kept %d transfers, want %d", len(transferOrder), maxTransferHistory)
+	}
+	if _, ok := transfers[running.ID]; !ok {
+		t.Fatal("pruned a running transfer")
+	}
+	if _, ok := transfers[finished[0].ID]; ok {
+		t.Fatal("kept the oldest finished transfer")
+	}
+}
+
+func TestDeleteTransfer(t *testing.T) {
+	withoutTransfers(t)
+
+	peer := &Peer{ID: "peer-1", Hostname: "laptop"}
+	queued := newTransfer(peer, []string{"queued.txt"}, transferOptions{})
+	running := newTransfer(peer, []string{"running.txt"}, transferOptions{})
+	running.markRunning()
+	finished := newTransfer(peer, []string{"finished.txt"}, transferOptions{})
+	finished.finish(nil)
+
+	tests := []struct {
+		name          string
+		id            string
+		wantCode      int
+		wantCancelled *Transfer
+	}{
+		{name: "queued", id: queued.ID, wantCode: http.StatusOK, wantCancelled: queued},
+		{name: "running", id: running.ID, wantCode: http.StatusOK, wantCancelled: running},
+		{name: "finished", id: finished.ID, wantCode: http.StatusConflict},
+		{name: "unknown", id: generateRandomID(), wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/transfers/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			recorder := httptest.NewRecorder()
+			HandleTransfer(recorder, req)
+
+			if recorder.Code != tt.wantCode {
+				t.Fatalf("DELETE /api/transfers/%s returned %d, want %d", tt.id, recorder.Code, tt.wantCode)
+			}
+			if tt.wantCancelled != nil && !errors.Is(context.Cause(tt.wantCancelled.ctx), errTransferCancelled) {
+				t.Fatalf("transfer context cause = %v, want errTransferCancelled", context.Cause(tt.wantCancelled.ctx))
+			}
+		})
+	}
+
+	if finished.ctx.Err() != nil {
+		t.Fatal("cancelled a finished transfer")
+	}
+}
+
+func TestRunTransferCancelledWhileQueued(t *testing.T) {
+	withoutTransfers(t)
+
+	// The peer is never contacted, so it needs no address
+	peer := &Peer{ID: "peer-1", Hostname: "laptop"}
+	transfer := newTransfer(peer, []string{"queued.txt"}, transferOptions{})
+	if _, err := cancelTransfer(transfer.ID); err != nil {
+		t.Fatalf("cancelTransfer: %v", err)
+	}
+
+	runTransfer(transfer, peer)
+
+	if transfer.State != TransferCancelled || transfer.StartedAt != "" {
+		t.Fatalf("transfer = %+v, want cancelled without starting", *transfer)
+	}
+	if _, err := cancelTransfer(transfer.ID); !errors.Is(err, errTransferFinished) {
+		t.Fatalf("second cancel error = %v, want errTransferFinished", err)
+	}
+}
@@ -9,10 +9,25 @@ import (
 func main() {
 	// Initialize system info on startup
 	logic.InitSystemInfo()
+	logic.InitConfig()
+	logic.InitAPIToken()
+	logic.InitTLS()
+	logic.InitTrustStore()
+	logic.InitTransferQueue()
+	logic.InitSync()
 
 	// Start peer discovery service
 	go logic.StartPeerDiscovery()
 
+	// Keep the rate limits in step with their schedules
+	go logic.StartRateLimitScheduler()
+
+	// Retry queued transfers as their peers come and go
+	go logic.StartTransferQueue()
+
+	// Send whatever lands in the outbox to its peer
+	go logic.StartOutboxWatcher()
+
 	// Start gRPC server for incoming file transfers
 	go logic.StartGRPCServer(9002)
 
@@ -23,21 +38,42 @@ func main() {
 	mux.HandleFunc("/api/systeminfo", logic.GetSystemInfo)
 	mux.HandleFunc("/api/peers", logic.GetPeers)
 	mux.HandleFunc("/api/filetransfer", logic.HandleFileTransfer)
+	mux.HandleFunc("/api/incoming", logic.GetIncomingOffers)
+	mux.HandleFunc("/api/incoming/{id}/{action}", logic.HandleIncomingDecision)
+	mux.HandleFunc("/api/transfers", logic.GetTransfers)
+	mux.HandleFunc("/api/transfers/{id}", logic.HandleTransfer)
+	mux.HandleFunc("/api/queue", logic.GetTransferQueue)
+	mux.HandleFunc("/api/queue/{id}", logic.HandleQueuedTransfer)
+	mux.HandleFunc("/api/queue/{id}/retry", logic.RetryQueuedTransfer)
+	mux.HandleFunc("/api/limits", logic.HandleRateLimits)
+	mux.HandleFunc("/api/events", logic.HandleEvents)
+	mux.HandleFunc("/api/pairing", logic.HandlePairing)
+	mux.HandleFunc("/api/pairing/pin", logic.StartPairing)
+	mux.HandleFunc("/api/trusted", logic.GetTrustedPeers)
+	mux.HandleFunc("/api/trusted/{id}", logic.HandleTrustedPeer)
+	mux.HandleFunc("/api/peers/{id}/shared", logic.GetSharedFiles)
+	mux.HandleFunc("/api/peers/{id}/shared/download", logic.DownloadSharedFile)
+	mux.HandleFunc("/api/peers/{id}/files", logic.BrowsePeerFiles)
+	mux.HandleFunc("/api/peers/{id}/files/stat", logic.StatPeerFile)
+	mux.HandleFunc("/api/sync", logic.HandleSyncFolders)
+	mux.HandleFunc("/api/sync/{id}", logic.HandleSyncFolder)
 
-	// Add CORS middleware for frontend communication
-	handler := enableCORS(mux)
+	// Only the local frontend, or a caller holding the API token, may use
+	// the API; CORS preflights are answered before that check
+	handler := enableCORS(logic.RequireAPIAuth(mux))
 
 	// Start REST server
-	log.Println("Backend running on port 9001")
-	log.Fatal(http.ListenAndServe(":80", handler))
+	address := logic.GetConfig().APIAddress
+	log.Printf("Backend running on %s", address)
+	log.Fatal(http.ListenAndServe(address, handler))
 }
 
 // CORS middleware for frontend communication
 func enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:9000")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Origin", logic.FrontendOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
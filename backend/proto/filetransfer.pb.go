@@ -22,13 +22,30 @@ const (
 )
 
 type FileChunk struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	FileName      string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
-	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
-	ChunkNumber   int64                  `protobuf:"varint,3,opt,name=chunk_number,json=chunkNumber,proto3" json:"chunk_number,omitempty"`
-	TotalChunks   int64                  `protobuf:"varint,4,opt,name=total_chunks,json=totalChunks,proto3" json:"total_chunks,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	FileName       string                 `protobuf:"bytes,1,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	Data           []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	ChunkNumber    int64                  `protobuf:"varint,3,opt,name=chunk_number,json=chunkNumber,proto3" json:"chunk_number,omitempty"`
+	TotalChunks    int64                  `protobuf:"varint,4,opt,name=total_chunks,json=totalChunks,proto3" json:"total_chunks,omitempty"`
+	TransferId     string                 `protobuf:"bytes,5,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Offset         int64                  `protobuf:"varint,6,opt,name=offset,proto3" json:"offset,omitempty"`
+	FileSize       int64                  `protobuf:"varint,7,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	Crc32C         uint32                 `protobuf:"varint,8,opt,name=crc32c,proto3" json:"crc32c,omitempty"`
+	Sha256         string                 `protobuf:"bytes,9,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	OfferId        string                 `protobuf:"bytes,10,opt,name=offer_id,json=offerId,proto3" json:"offer_id,omitempty"`
+	IsDirectory    bool                   `protobuf:"varint,11,opt,name=is_directory,json=isDirectory,proto3" json:"is_directory,omitempty"`
+	Mode           uint32                 `protobuf:"varint,12,opt,name=mode,proto3" json:"mode,omitempty"`
+	Mtime          int64                  `protobuf:"varint,13,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	SymlinkTarget  string                 `protobuf:"bytes,14,opt,name=symlink_target,json=symlinkTarget,proto3" json:"symlink_target,omitempty"`
+	HardlinkTarget string                 `protobuf:"bytes,15,opt,name=hardlink_target,json=hardlinkTarget,proto3" json:"hardlink_target,omitempty"`
+	Encryption     *EncryptionHeader      `protobuf:"bytes,16,opt,name=encryption,proto3" json:"encryption,omitempty"`
+	DeltaBlockSize int64                  `protobuf:"varint,17,opt,name=delta_block_size,json=deltaBlockSize,proto3" json:"delta_block_size,omitempty"`
+	Copy           *BlockCopy             `protobuf:"bytes,18,opt,name=copy,proto3" json:"copy,omitempty"`
+	Compression    string                 `protobuf:"bytes,19,opt,name=compression,proto3" json:"compression,omitempty"`
+	BasisSize      int64                  `protobuf:"varint,20,opt,name=basis_size,json=basisSize,proto3" json:"basis_size,omitempty"`
+	BasisMtime     int64                  `protobuf:"varint,21,opt,name=basis_mtime,json=basisMtime,proto3" json:"basis_mtime,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *FileChunk) Reset() {
@@ -89,18 +106,253 @@ func (x *FileChunk) GetTotalChunks() int64 {
 	return 0
 }
 
+func (x *FileChunk) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *FileChunk) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *FileChunk) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+func (x *FileChunk) GetCrc32C() uint32 {
+	if x != nil {
+		return x.Crc32C
+	}
+	return 0
+}
+
+func (x *FileChunk) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileChunk) GetOfferId() string {
+	if x != nil {
+		return x.OfferId
+	}
+	return ""
+}
+
+func (x *FileChunk) GetIsDirectory() bool {
+	if x != nil {
+		return x.IsDirectory
+	}
+	return false
+}
+
+func (x *FileChunk) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *FileChunk) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+func (x *FileChunk) GetSymlinkTarget() string {
+	if x != nil {
+		return x.SymlinkTarget
+	}
+	return ""
+}
+
+func (x *FileChunk) GetHardlinkTarget() string {
+	if x != nil {
+		return x.HardlinkTarget
+	}
+	return ""
+}
+
+func (x *FileChunk) GetEncryption() *EncryptionHeader {
+	if x != nil {
+		return x.Encryption
+	}
+	return nil
+}
+
+func (x *FileChunk) GetDeltaBlockSize() int64 {
+	if x != nil {
+		return x.DeltaBlockSize
+	}
+	return 0
+}
+
+func (x *FileChunk) GetCopy() *BlockCopy {
+	if x != nil {
+		return x.Copy
+	}
+	return nil
+}
+
+func (x *FileChunk) GetCompression() string {
+	if x != nil {
+		return x.Compression
+	}
+	return ""
+}
+
+func (x *FileChunk) GetBasisSize() int64 {
+	if x != nil {
+		return x.BasisSize
+	}
+	return 0
+}
+
+func (x *FileChunk) GetBasisMtime() int64 {
+	if x != nil {
+		return x.BasisMtime
+	}
+	return 0
+}
+
+type BlockCopy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int64                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockCopy) Reset() {
+	*x = BlockCopy{}
+	mi := &file_proto_filetransfer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockCopy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockCopy) ProtoMessage() {}
+
+func (x *BlockCopy) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockCopy.ProtoReflect.Descriptor instead.
+func (*BlockCopy) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BlockCopy) GetIndex() int64 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *BlockCopy) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type EncryptionHeader struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EphemeralKey  []byte                 `protobuf:"bytes,1,opt,name=ephemeral_key,json=ephemeralKey,proto3" json:"ephemeral_key,omitempty"`
+	SenderPeerId  string                 `protobuf:"bytes,2,opt,name=sender_peer_id,json=senderPeerId,proto3" json:"sender_peer_id,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncryptionHeader) Reset() {
+	*x = EncryptionHeader{}
+	mi := &file_proto_filetransfer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncryptionHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptionHeader) ProtoMessage() {}
+
+func (x *EncryptionHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptionHeader.ProtoReflect.Descriptor instead.
+func (*EncryptionHeader) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EncryptionHeader) GetEphemeralKey() []byte {
+	if x != nil {
+		return x.EphemeralKey
+	}
+	return nil
+}
+
+func (x *EncryptionHeader) GetSenderPeerId() string {
+	if x != nil {
+		return x.SenderPeerId
+	}
+	return ""
+}
+
+func (x *EncryptionHeader) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
 type FileTransferResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
 	BytesReceived int64                  `protobuf:"varint,3,opt,name=bytes_received,json=bytesReceived,proto3" json:"bytes_received,omitempty"`
+	ResumedFrom   int64                  `protobuf:"varint,4,opt,name=resumed_from,json=resumedFrom,proto3" json:"resumed_from,omitempty"`
+	Verified      bool                   `protobuf:"varint,5,opt,name=verified,proto3" json:"verified,omitempty"`
+	Sha256        string                 `protobuf:"bytes,6,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	BytesSaved    int64                  `protobuf:"varint,7,opt,name=bytes_saved,json=bytesSaved,proto3" json:"bytes_saved,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *FileTransferResponse) Reset() {
 	*x = FileTransferResponse{}
-	mi := &file_proto_filetransfer_proto_msgTypes[1]
+	mi := &file_proto_filetransfer_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -112,7 +364,7 @@ func (x *FileTransferResponse) String() string {
 func (*FileTransferResponse) ProtoMessage() {}
 
 func (x *FileTransferResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_filetransfer_proto_msgTypes[1]
+	mi := &file_proto_filetransfer_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -125,7 +377,7 @@ func (x *FileTransferResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileTransferResponse.ProtoReflect.Descriptor instead.
 func (*FileTransferResponse) Descriptor() ([]byte, []int) {
-	return file_proto_filetransfer_proto_rawDescGZIP(), []int{1}
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *FileTransferResponse) GetSuccess() bool {
@@ -149,22 +401,2059 @@ func (x *FileTransferResponse) GetBytesReceived() int64 {
 	return 0
 }
 
-var File_proto_filetransfer_proto protoreflect.FileDescriptor
+func (x *FileTransferResponse) GetResumedFrom() int64 {
+	if x != nil {
+		return x.ResumedFrom
+	}
+	return 0
+}
 
-const file_proto_filetransfer_proto_rawDesc = "" +
-	"\n" +
-	"\x18proto/filetransfer.proto\x12\ffiletransfer\"\x82\x01\n" +
-	"\tFileChunk\x12\x1b\n" +
-	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x12\n" +
-	"\x04data\x18\x02 \x01(\fR\x04data\x12!\n" +
-	"\fchunk_number\x18\x03 \x01(\x03R\vchunkNumber\x12!\n" +
-	"\ftotal_chunks\x18\x04 \x01(\x03R\vtotalChunks\"q\n" +
-	"\x14FileTransferResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
-	"\x0ebytes_received\x18\x03 \x01(\x03R\rbytesReceived2`\n" +
+func (x *FileTransferResponse) GetVerified() bool {
+	if x != nil {
+		return x.Verified
+	}
+	return false
+}
+
+func (x *FileTransferResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileTransferResponse) GetBytesSaved() int64 {
+	if x != nil {
+		return x.BytesSaved
+	}
+	return 0
+}
+
+type ResumeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	FileName      string                 `protobuf:"bytes,2,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	FileSize      int64                  `protobuf:"varint,3,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeRequest) Reset() {
+	*x = ResumeRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeRequest) ProtoMessage() {}
+
+func (x *ResumeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeRequest.ProtoReflect.Descriptor instead.
+func (*ResumeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ResumeRequest) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *ResumeRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *ResumeRequest) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+type ResumeResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	BytesReceived  int64                  `protobuf:"varint,1,opt,name=bytes_received,json=bytesReceived,proto3" json:"bytes_received,omitempty"`
+	ChunksReceived int64                  `protobuf:"varint,2,opt,name=chunks_received,json=chunksReceived,proto3" json:"chunks_received,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ResumeResponse) Reset() {
+	*x = ResumeResponse{}
+	mi := &file_proto_filetransfer_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeResponse) ProtoMessage() {}
+
+func (x *ResumeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeResponse.ProtoReflect.Descriptor instead.
+func (*ResumeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ResumeResponse) GetBytesReceived() int64 {
+	if x != nil {
+		return x.BytesReceived
+	}
+	return 0
+}
+
+func (x *ResumeResponse) GetChunksReceived() int64 {
+	if x != nil {
+		return x.ChunksReceived
+	}
+	return 0
+}
+
+type OfferedFile struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Path           string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Size           int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	IsDirectory    bool                   `protobuf:"varint,3,opt,name=is_directory,json=isDirectory,proto3" json:"is_directory,omitempty"`
+	SymlinkTarget  string                 `protobuf:"bytes,4,opt,name=symlink_target,json=symlinkTarget,proto3" json:"symlink_target,omitempty"`
+	HardlinkTarget string                 `protobuf:"bytes,5,opt,name=hardlink_target,json=hardlinkTarget,proto3" json:"hardlink_target,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *OfferedFile) Reset() {
+	*x = OfferedFile{}
+	mi := &file_proto_filetransfer_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OfferedFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OfferedFile) ProtoMessage() {}
+
+func (x *OfferedFile) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OfferedFile.ProtoReflect.Descriptor instead.
+func (*OfferedFile) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *OfferedFile) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *OfferedFile) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *OfferedFile) GetIsDirectory() bool {
+	if x != nil {
+		return x.IsDirectory
+	}
+	return false
+}
+
+func (x *OfferedFile) GetSymlinkTarget() string {
+	if x != nil {
+		return x.SymlinkTarget
+	}
+	return ""
+}
+
+func (x *OfferedFile) GetHardlinkTarget() string {
+	if x != nil {
+		return x.HardlinkTarget
+	}
+	return ""
+}
+
+type FileOffer struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	OfferId        string                 `protobuf:"bytes,1,opt,name=offer_id,json=offerId,proto3" json:"offer_id,omitempty"`
+	FileName       string                 `protobuf:"bytes,2,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	FileSize       int64                  `protobuf:"varint,3,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	SenderPeerId   string                 `protobuf:"bytes,4,opt,name=sender_peer_id,json=senderPeerId,proto3" json:"sender_peer_id,omitempty"`
+	SenderHostname string                 `protobuf:"bytes,5,opt,name=sender_hostname,json=senderHostname,proto3" json:"sender_hostname,omitempty"`
+	Files          []*OfferedFile         `protobuf:"bytes,6,rep,name=files,proto3" json:"files,omitempty"`
+	Compression    []string               `protobuf:"bytes,7,rep,name=compression,proto3" json:"compression,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *FileOffer) Reset() {
+	*x = FileOffer{}
+	mi := &file_proto_filetransfer_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileOffer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileOffer) ProtoMessage() {}
+
+func (x *FileOffer) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileOffer.ProtoReflect.Descriptor instead.
+func (*FileOffer) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FileOffer) GetOfferId() string {
+	if x != nil {
+		return x.OfferId
+	}
+	return ""
+}
+
+func (x *FileOffer) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *FileOffer) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+func (x *FileOffer) GetSenderPeerId() string {
+	if x != nil {
+		return x.SenderPeerId
+	}
+	return ""
+}
+
+func (x *FileOffer) GetSenderHostname() string {
+	if x != nil {
+		return x.SenderHostname
+	}
+	return ""
+}
+
+func (x *FileOffer) GetFiles() []*OfferedFile {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *FileOffer) GetCompression() []string {
+	if x != nil {
+		return x.Compression
+	}
+	return nil
+}
+
+type OfferResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Accepted      bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Compression   string                 `protobuf:"bytes,3,opt,name=compression,proto3" json:"compression,omitempty"`
+	TimedOut      bool                   `protobuf:"varint,4,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"` // nobody answered, as opposed to a rejection
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OfferResponse) Reset() {
+	*x = OfferResponse{}
+	mi := &file_proto_filetransfer_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OfferResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OfferResponse) ProtoMessage() {}
+
+func (x *OfferResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OfferResponse.ProtoReflect.Descriptor instead.
+func (*OfferResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *OfferResponse) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *OfferResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *OfferResponse) GetCompression() string {
+	if x != nil {
+		return x.Compression
+	}
+	return ""
+}
+
+func (x *OfferResponse) GetTimedOut() bool {
+	if x != nil {
+		return x.TimedOut
+	}
+	return false
+}
+
+type CancelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	OfferId       string                 `protobuf:"bytes,2,opt,name=offer_id,json=offerId,proto3" json:"offer_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelRequest) Reset() {
+	*x = CancelRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelRequest) ProtoMessage() {}
+
+func (x *CancelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
+func (*CancelRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *CancelRequest) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *CancelRequest) GetOfferId() string {
+	if x != nil {
+		return x.OfferId
+	}
+	return ""
+}
+
+type CancelResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PartialKept   bool                   `protobuf:"varint,1,opt,name=partial_kept,json=partialKept,proto3" json:"partial_kept,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelResponse) Reset() {
+	*x = CancelResponse{}
+	mi := &file_proto_filetransfer_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelResponse) ProtoMessage() {}
+
+func (x *CancelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelResponse.ProtoReflect.Descriptor instead.
+func (*CancelResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CancelResponse) GetPartialKept() bool {
+	if x != nil {
+		return x.PartialKept
+	}
+	return false
+}
+
+type PairMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        string                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Hostname      string                 `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Commitment    []byte                 `protobuf:"bytes,3,opt,name=commitment,proto3" json:"commitment,omitempty"`
+	Proof         []byte                 `protobuf:"bytes,4,opt,name=proof,proto3" json:"proof,omitempty"`
+	Nonce         []byte                 `protobuf:"bytes,5,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Accepted      bool                   `protobuf:"varint,6,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PairMessage) Reset() {
+	*x = PairMessage{}
+	mi := &file_proto_filetransfer_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PairMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PairMessage) ProtoMessage() {}
+
+func (x *PairMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PairMessage.ProtoReflect.Descriptor instead.
+func (*PairMessage) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PairMessage) GetPeerId() string {
+	if x != nil {
+		return x.PeerId
+	}
+	return ""
+}
+
+func (x *PairMessage) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *PairMessage) GetCommitment() []byte {
+	if x != nil {
+		return x.Commitment
+	}
+	return nil
+}
+
+func (x *PairMessage) GetProof() []byte {
+	if x != nil {
+		return x.Proof
+	}
+	return nil
+}
+
+func (x *PairMessage) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *PairMessage) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+type BlockSignatureRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OfferId       string                 `protobuf:"bytes,1,opt,name=offer_id,json=offerId,proto3" json:"offer_id,omitempty"`
+	FileName      string                 `protobuf:"bytes,2,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	FileSize      int64                  `protobuf:"varint,3,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockSignatureRequest) Reset() {
+	*x = BlockSignatureRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockSignatureRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockSignatureRequest) ProtoMessage() {}
+
+func (x *BlockSignatureRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockSignatureRequest.ProtoReflect.Descriptor instead.
+func (*BlockSignatureRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *BlockSignatureRequest) GetOfferId() string {
+	if x != nil {
+		return x.OfferId
+	}
+	return ""
+}
+
+func (x *BlockSignatureRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *BlockSignatureRequest) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+type BlockSignatures struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BlockSize     int64                  `protobuf:"varint,1,opt,name=block_size,json=blockSize,proto3" json:"block_size,omitempty"`
+	BasisSize     int64                  `protobuf:"varint,2,opt,name=basis_size,json=basisSize,proto3" json:"basis_size,omitempty"`
+	Weak          []uint32               `protobuf:"varint,3,rep,packed,name=weak,proto3" json:"weak,omitempty"`
+	Strong        [][]byte               `protobuf:"bytes,4,rep,name=strong,proto3" json:"strong,omitempty"`
+	BasisMtime    int64                  `protobuf:"varint,5,opt,name=basis_mtime,json=basisMtime,proto3" json:"basis_mtime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockSignatures) Reset() {
+	*x = BlockSignatures{}
+	mi := &file_proto_filetransfer_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockSignatures) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockSignatures) ProtoMessage() {}
+
+func (x *BlockSignatures) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockSignatures.ProtoReflect.Descriptor instead.
+func (*BlockSignatures) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *BlockSignatures) GetBlockSize() int64 {
+	if x != nil {
+		return x.BlockSize
+	}
+	return 0
+}
+
+func (x *BlockSignatures) GetBasisSize() int64 {
+	if x != nil {
+		return x.BasisSize
+	}
+	return 0
+}
+
+func (x *BlockSignatures) GetWeak() []uint32 {
+	if x != nil {
+		return x.Weak
+	}
+	return nil
+}
+
+func (x *BlockSignatures) GetStrong() [][]byte {
+	if x != nil {
+		return x.Strong
+	}
+	return nil
+}
+
+func (x *BlockSignatures) GetBasisMtime() int64 {
+	if x != nil {
+		return x.BasisMtime
+	}
+	return 0
+}
+
+type EncryptionKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncryptionKeyRequest) Reset() {
+	*x = EncryptionKeyRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncryptionKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptionKeyRequest) ProtoMessage() {}
+
+func (x *EncryptionKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptionKeyRequest.ProtoReflect.Descriptor instead.
+func (*EncryptionKeyRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{14}
+}
+
+type EncryptionKey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PublicKey     []byte                 `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncryptionKey) Reset() {
+	*x = EncryptionKey{}
+	mi := &file_proto_filetransfer_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncryptionKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptionKey) ProtoMessage() {}
+
+func (x *EncryptionKey) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptionKey.ProtoReflect.Descriptor instead.
+func (*EncryptionKey) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *EncryptionKey) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *EncryptionKey) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type ListSharedFilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSharedFilesRequest) Reset() {
+	*x = ListSharedFilesRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSharedFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSharedFilesRequest) ProtoMessage() {}
+
+func (x *ListSharedFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSharedFilesRequest.ProtoReflect.Descriptor instead.
+func (*ListSharedFilesRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ListSharedFilesRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type SharedFile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	IsDirectory   bool                   `protobuf:"varint,3,opt,name=is_directory,json=isDirectory,proto3" json:"is_directory,omitempty"`
+	Mtime         int64                  `protobuf:"varint,4,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	TransferId    string                 `protobuf:"bytes,5,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SharedFile) Reset() {
+	*x = SharedFile{}
+	mi := &file_proto_filetransfer_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SharedFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SharedFile) ProtoMessage() {}
+
+func (x *SharedFile) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SharedFile.ProtoReflect.Descriptor instead.
+func (*SharedFile) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SharedFile) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SharedFile) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *SharedFile) GetIsDirectory() bool {
+	if x != nil {
+		return x.IsDirectory
+	}
+	return false
+}
+
+func (x *SharedFile) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+func (x *SharedFile) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+type SharedFileList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*SharedFile          `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SharedFileList) Reset() {
+	*x = SharedFileList{}
+	mi := &file_proto_filetransfer_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SharedFileList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SharedFileList) ProtoMessage() {}
+
+func (x *SharedFileList) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SharedFileList.ProtoReflect.Descriptor instead.
+func (*SharedFileList) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *SharedFileList) GetFiles() []*SharedFile {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type GetFileRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Path           string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	TransferId     string                 `protobuf:"bytes,2,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Offset         int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	ChunksReceived int64                  `protobuf:"varint,4,opt,name=chunks_received,json=chunksReceived,proto3" json:"chunks_received,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetFileRequest) Reset() {
+	*x = GetFileRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFileRequest) ProtoMessage() {}
+
+func (x *GetFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFileRequest.ProtoReflect.Descriptor instead.
+func (*GetFileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *GetFileRequest) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *GetFileRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *GetFileRequest) GetChunksReceived() int64 {
+	if x != nil {
+		return x.ChunksReceived
+	}
+	return 0
+}
+
+type BrowseEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	IsDirectory   bool                   `protobuf:"varint,4,opt,name=is_directory,json=isDirectory,proto3" json:"is_directory,omitempty"`
+	Mtime         int64                  `protobuf:"varint,5,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	Mode          uint32                 `protobuf:"varint,6,opt,name=mode,proto3" json:"mode,omitempty"`
+	Sha256        string                 `protobuf:"bytes,7,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	HashPending   bool                   `protobuf:"varint,8,opt,name=hash_pending,json=hashPending,proto3" json:"hash_pending,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrowseEntry) Reset() {
+	*x = BrowseEntry{}
+	mi := &file_proto_filetransfer_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrowseEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrowseEntry) ProtoMessage() {}
+
+func (x *BrowseEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrowseEntry.ProtoReflect.Descriptor instead.
+func (*BrowseEntry) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *BrowseEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *BrowseEntry) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *BrowseEntry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *BrowseEntry) GetIsDirectory() bool {
+	if x != nil {
+		return x.IsDirectory
+	}
+	return false
+}
+
+func (x *BrowseEntry) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+func (x *BrowseEntry) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *BrowseEntry) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *BrowseEntry) GetHashPending() bool {
+	if x != nil {
+		return x.HashPending
+	}
+	return false
+}
+
+type BrowseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Pattern       string                 `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Recursive     bool                   `protobuf:"varint,3,opt,name=recursive,proto3" json:"recursive,omitempty"`
+	PageSize      int32                  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	PageToken     string                 `protobuf:"bytes,5,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	IncludeHashes bool                   `protobuf:"varint,6,opt,name=include_hashes,json=includeHashes,proto3" json:"include_hashes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrowseRequest) Reset() {
+	*x = BrowseRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrowseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrowseRequest) ProtoMessage() {}
+
+func (x *BrowseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrowseRequest.ProtoReflect.Descriptor instead.
+func (*BrowseRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *BrowseRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *BrowseRequest) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *BrowseRequest) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+func (x *BrowseRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *BrowseRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *BrowseRequest) GetIncludeHashes() bool {
+	if x != nil {
+		return x.IncludeHashes
+	}
+	return false
+}
+
+type BrowseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*BrowseEntry         `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	NextPageToken string                 `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrowseResponse) Reset() {
+	*x = BrowseResponse{}
+	mi := &file_proto_filetransfer_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrowseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrowseResponse) ProtoMessage() {}
+
+func (x *BrowseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrowseResponse.ProtoReflect.Descriptor instead.
+func (*BrowseResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *BrowseResponse) GetEntries() []*BrowseEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *BrowseResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type StatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatRequest) Reset() {
+	*x = StatRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatRequest) ProtoMessage() {}
+
+func (x *StatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatRequest.ProtoReflect.Descriptor instead.
+func (*StatRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *StatRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type ParallelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	OfferId       string                 `protobuf:"bytes,2,opt,name=offer_id,json=offerId,proto3" json:"offer_id,omitempty"`
+	FileName      string                 `protobuf:"bytes,3,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	FileSize      int64                  `protobuf:"varint,4,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	RangeSize     int64                  `protobuf:"varint,5,opt,name=range_size,json=rangeSize,proto3" json:"range_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParallelRequest) Reset() {
+	*x = ParallelRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParallelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParallelRequest) ProtoMessage() {}
+
+func (x *ParallelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParallelRequest.ProtoReflect.Descriptor instead.
+func (*ParallelRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ParallelRequest) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *ParallelRequest) GetOfferId() string {
+	if x != nil {
+		return x.OfferId
+	}
+	return ""
+}
+
+func (x *ParallelRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *ParallelRequest) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+func (x *ParallelRequest) GetRangeSize() int64 {
+	if x != nil {
+		return x.RangeSize
+	}
+	return 0
+}
+
+type ParallelStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RangesDone    []bool                 `protobuf:"varint,1,rep,packed,name=ranges_done,json=rangesDone,proto3" json:"ranges_done,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParallelStatus) Reset() {
+	*x = ParallelStatus{}
+	mi := &file_proto_filetransfer_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParallelStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParallelStatus) ProtoMessage() {}
+
+func (x *ParallelStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParallelStatus.ProtoReflect.Descriptor instead.
+func (*ParallelStatus) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ParallelStatus) GetRangesDone() []bool {
+	if x != nil {
+		return x.RangesDone
+	}
+	return nil
+}
+
+type RangeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BytesReceived int64                  `protobuf:"varint,1,opt,name=bytes_received,json=bytesReceived,proto3" json:"bytes_received,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RangeResponse) Reset() {
+	*x = RangeResponse{}
+	mi := &file_proto_filetransfer_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RangeResponse) ProtoMessage() {}
+
+func (x *RangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RangeResponse.ProtoReflect.Descriptor instead.
+func (*RangeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *RangeResponse) GetBytesReceived() int64 {
+	if x != nil {
+		return x.BytesReceived
+	}
+	return 0
+}
+
+type ParallelFinish struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	OfferId       string                 `protobuf:"bytes,2,opt,name=offer_id,json=offerId,proto3" json:"offer_id,omitempty"`
+	FileName      string                 `protobuf:"bytes,3,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	FileSize      int64                  `protobuf:"varint,4,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`
+	Sha256        string                 `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Mode          uint32                 `protobuf:"varint,6,opt,name=mode,proto3" json:"mode,omitempty"`
+	Mtime         int64                  `protobuf:"varint,7,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParallelFinish) Reset() {
+	*x = ParallelFinish{}
+	mi := &file_proto_filetransfer_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParallelFinish) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParallelFinish) ProtoMessage() {}
+
+func (x *ParallelFinish) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParallelFinish.ProtoReflect.Descriptor instead.
+func (*ParallelFinish) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ParallelFinish) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *ParallelFinish) GetOfferId() string {
+	if x != nil {
+		return x.OfferId
+	}
+	return ""
+}
+
+func (x *ParallelFinish) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *ParallelFinish) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+func (x *ParallelFinish) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *ParallelFinish) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *ParallelFinish) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+type SyncFileInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Mtime         int64                  `protobuf:"varint,3,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	Mode          uint32                 `protobuf:"varint,4,opt,name=mode,proto3" json:"mode,omitempty"`
+	Sha256        string                 `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Deleted       bool                   `protobuf:"varint,6,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	Version       map[string]uint64      `protobuf:"bytes,7,rep,name=version,proto3" json:"version,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncFileInfo) Reset() {
+	*x = SyncFileInfo{}
+	mi := &file_proto_filetransfer_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncFileInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncFileInfo) ProtoMessage() {}
+
+func (x *SyncFileInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncFileInfo.ProtoReflect.Descriptor instead.
+func (*SyncFileInfo) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *SyncFileInfo) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SyncFileInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *SyncFileInfo) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+func (x *SyncFileInfo) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *SyncFileInfo) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *SyncFileInfo) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *SyncFileInfo) GetVersion() map[string]uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return nil
+}
+
+type SyncIndexRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FolderId      string                 `protobuf:"bytes,1,opt,name=folder_id,json=folderId,proto3" json:"folder_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncIndexRequest) Reset() {
+	*x = SyncIndexRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncIndexRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncIndexRequest) ProtoMessage() {}
+
+func (x *SyncIndexRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncIndexRequest.ProtoReflect.Descriptor instead.
+func (*SyncIndexRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *SyncIndexRequest) GetFolderId() string {
+	if x != nil {
+		return x.FolderId
+	}
+	return ""
+}
+
+type SyncIndex struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Files         []*SyncFileInfo        `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncIndex) Reset() {
+	*x = SyncIndex{}
+	mi := &file_proto_filetransfer_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncIndex) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncIndex) ProtoMessage() {}
+
+func (x *SyncIndex) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncIndex.ProtoReflect.Descriptor instead.
+func (*SyncIndex) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *SyncIndex) GetFiles() []*SyncFileInfo {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type SyncFileRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	FolderId       string                 `protobuf:"bytes,1,opt,name=folder_id,json=folderId,proto3" json:"folder_id,omitempty"`
+	Path           string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Sha256         string                 `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	Offset         int64                  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	ChunksReceived int64                  `protobuf:"varint,5,opt,name=chunks_received,json=chunksReceived,proto3" json:"chunks_received,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SyncFileRequest) Reset() {
+	*x = SyncFileRequest{}
+	mi := &file_proto_filetransfer_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncFileRequest) ProtoMessage() {}
+
+func (x *SyncFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncFileRequest.ProtoReflect.Descriptor instead.
+func (*SyncFileRequest) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *SyncFileRequest) GetFolderId() string {
+	if x != nil {
+		return x.FolderId
+	}
+	return ""
+}
+
+func (x *SyncFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SyncFileRequest) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *SyncFileRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *SyncFileRequest) GetChunksReceived() int64 {
+	if x != nil {
+		return x.ChunksReceived
+	}
+	return 0
+}
+
+type SyncNotifyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncNotifyResponse) Reset() {
+	*x = SyncNotifyResponse{}
+	mi := &file_proto_filetransfer_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncNotifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncNotifyResponse) ProtoMessage() {}
+
+func (x *SyncNotifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_filetransfer_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncNotifyResponse.ProtoReflect.Descriptor instead.
+func (*SyncNotifyResponse) Descriptor() ([]byte, []int) {
+	return file_proto_filetransfer_proto_rawDescGZIP(), []int{32}
+}
+
+var File_proto_filetransfer_proto protoreflect.FileDescriptor
+
+const file_proto_filetransfer_proto_rawDesc = "" +
+	"\n" +
+	"\x18proto/filetransfer.proto\x12\ffiletransfer\"\xb9\x05\n" +
+	"\tFileChunk\x12\x1b\n" +
+	"\tfile_name\x18\x01 \x01(\tR\bfileName\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12!\n" +
+	"\fchunk_number\x18\x03 \x01(\x03R\vchunkNumber\x12!\n" +
+	"\ftotal_chunks\x18\x04 \x01(\x03R\vtotalChunks\x12\x1f\n" +
+	"\vtransfer_id\x18\x05 \x01(\tR\n" +
+	"transferId\x12\x16\n" +
+	"\x06offset\x18\x06 \x01(\x03R\x06offset\x12\x1b\n" +
+	"\tfile_size\x18\a \x01(\x03R\bfileSize\x12\x16\n" +
+	"\x06crc32c\x18\b \x01(\rR\x06crc32c\x12\x16\n" +
+	"\x06sha256\x18\t \x01(\tR\x06sha256\x12\x19\n" +
+	"\boffer_id\x18\n" +
+	" \x01(\tR\aofferId\x12!\n" +
+	"\fis_directory\x18\v \x01(\bR\visDirectory\x12\x12\n" +
+	"\x04mode\x18\f \x01(\rR\x04mode\x12\x14\n" +
+	"\x05mtime\x18\r \x01(\x03R\x05mtime\x12%\n" +
+	"\x0esymlink_target\x18\x0e \x01(\tR\rsymlinkTarget\x12'\n" +
+	"\x0fhardlink_target\x18\x0f \x01(\tR\x0ehardlinkTarget\x12>\n" +
+	"\n" +
+	"encryption\x18\x10 \x01(\v2\x1e.filetransfer.EncryptionHeaderR\n" +
+	"encryption\x12(\n" +
+	"\x10delta_block_size\x18\x11 \x01(\x03R\x0edeltaBlockSize\x12+\n" +
+	"\x04copy\x18\x12 \x01(\v2\x17.filetransfer.BlockCopyR\x04copy\x12 \n" +
+	"\vcompression\x18\x13 \x01(\tR\vcompression\x12\x1d\n" +
+	"\n" +
+	"basis_size\x18\x14 \x01(\x03R\tbasisSize\x12\x1f\n" +
+	"\vbasis_mtime\x18\x15 \x01(\x03R\n" +
+	"basisMtime\"7\n" +
+	"\tBlockCopy\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x03R\x05index\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\"{\n" +
+	"\x10EncryptionHeader\x12#\n" +
+	"\rephemeral_key\x18\x01 \x01(\fR\fephemeralKey\x12$\n" +
+	"\x0esender_peer_id\x18\x02 \x01(\tR\fsenderPeerId\x12\x1c\n" +
+	"\tsignature\x18\x03 \x01(\fR\tsignature\"\xe9\x01\n" +
+	"\x14FileTransferResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12%\n" +
+	"\x0ebytes_received\x18\x03 \x01(\x03R\rbytesReceived\x12!\n" +
+	"\fresumed_from\x18\x04 \x01(\x03R\vresumedFrom\x12\x1a\n" +
+	"\bverified\x18\x05 \x01(\bR\bverified\x12\x16\n" +
+	"\x06sha256\x18\x06 \x01(\tR\x06sha256\x12\x1f\n" +
+	"\vbytes_saved\x18\a \x01(\x03R\n" +
+	"bytesSaved\"j\n" +
+	"\rResumeRequest\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x1b\n" +
+	"\tfile_name\x18\x02 \x01(\tR\bfileName\x12\x1b\n" +
+	"\tfile_size\x18\x03 \x01(\x03R\bfileSize\"`\n" +
+	"\x0eResumeResponse\x12%\n" +
+	"\x0ebytes_received\x18\x01 \x01(\x03R\rbytesReceived\x12'\n" +
+	"\x0fchunks_received\x18\x02 \x01(\x03R\x0echunksReceived\"\xa8\x01\n" +
+	"\vOfferedFile\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12!\n" +
+	"\fis_directory\x18\x03 \x01(\bR\visDirectory\x12%\n" +
+	"\x0esymlink_target\x18\x04 \x01(\tR\rsymlinkTarget\x12'\n" +
+	"\x0fhardlink_target\x18\x05 \x01(\tR\x0ehardlinkTarget\"\x82\x02\n" +
+	"\tFileOffer\x12\x19\n" +
+	"\boffer_id\x18\x01 \x01(\tR\aofferId\x12\x1b\n" +
+	"\tfile_name\x18\x02 \x01(\tR\bfileName\x12\x1b\n" +
+	"\tfile_size\x18\x03 \x01(\x03R\bfileSize\x12$\n" +
+	"\x0esender_peer_id\x18\x04 \x01(\tR\fsenderPeerId\x12'\n" +
+	"\x0fsender_hostname\x18\x05 \x01(\tR\x0esenderHostname\x12/\n" +
+	"\x05files\x18\x06 \x03(\v2\x19.filetransfer.OfferedFileR\x05files\x12 \n" +
+	"\vcompression\x18\a \x03(\tR\vcompression\"\x84\x01\n" +
+	"\rOfferResponse\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\bR\baccepted\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12 \n" +
+	"\vcompression\x18\x03 \x01(\tR\vcompression\x12\x1b\n" +
+	"\ttimed_out\x18\x04 \x01(\bR\btimedOut\"K\n" +
+	"\rCancelRequest\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x19\n" +
+	"\boffer_id\x18\x02 \x01(\tR\aofferId\"3\n" +
+	"\x0eCancelResponse\x12!\n" +
+	"\fpartial_kept\x18\x01 \x01(\bR\vpartialKept\"\xaa\x01\n" +
+	"\vPairMessage\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\tR\x06peerId\x12\x1a\n" +
+	"\bhostname\x18\x02 \x01(\tR\bhostname\x12\x1e\n" +
+	"\n" +
+	"commitment\x18\x03 \x01(\fR\n" +
+	"commitment\x12\x14\n" +
+	"\x05proof\x18\x04 \x01(\fR\x05proof\x12\x14\n" +
+	"\x05nonce\x18\x05 \x01(\fR\x05nonce\x12\x1a\n" +
+	"\baccepted\x18\x06 \x01(\bR\baccepted\"l\n" +
+	"\x15BlockSignatureRequest\x12\x19\n" +
+	"\boffer_id\x18\x01 \x01(\tR\aofferId\x12\x1b\n" +
+	"\tfile_name\x18\x02 \x01(\tR\bfileName\x12\x1b\n" +
+	"\tfile_size\x18\x03 \x01(\x03R\bfileSize\"\x9c\x01\n" +
+	"\x0fBlockSignatures\x12\x1d\n" +
+	"\n" +
+	"block_size\x18\x01 \x01(\x03R\tblockSize\x12\x1d\n" +
+	"\n" +
+	"basis_size\x18\x02 \x01(\x03R\tbasisSize\x12\x12\n" +
+	"\x04weak\x18\x03 \x03(\rR\x04weak\x12\x16\n" +
+	"\x06strong\x18\x04 \x03(\fR\x06strong\x12\x1f\n" +
+	"\vbasis_mtime\x18\x05 \x01(\x03R\n" +
+	"basisMtime\"\x16\n" +
+	"\x14EncryptionKeyRequest\"L\n" +
+	"\rEncryptionKey\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x01 \x01(\fR\tpublicKey\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignature\",\n" +
+	"\x16ListSharedFilesRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"\x8e\x01\n" +
+	"\n" +
+	"SharedFile\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12!\n" +
+	"\fis_directory\x18\x03 \x01(\bR\visDirectory\x12\x14\n" +
+	"\x05mtime\x18\x04 \x01(\x03R\x05mtime\x12\x1f\n" +
+	"\vtransfer_id\x18\x05 \x01(\tR\n" +
+	"transferId\"@\n" +
+	"\x0eSharedFileList\x12.\n" +
+	"\x05files\x18\x01 \x03(\v2\x18.filetransfer.SharedFileR\x05files\"\x86\x01\n" +
+	"\x0eGetFileRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1f\n" +
+	"\vtransfer_id\x18\x02 \x01(\tR\n" +
+	"transferId\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12'\n" +
+	"\x0fchunks_received\x18\x04 \x01(\x03R\x0echunksReceived\"\xd1\x01\n" +
+	"\vBrowseEntry\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12!\n" +
+	"\fis_directory\x18\x04 \x01(\bR\visDirectory\x12\x14\n" +
+	"\x05mtime\x18\x05 \x01(\x03R\x05mtime\x12\x12\n" +
+	"\x04mode\x18\x06 \x01(\rR\x04mode\x12\x16\n" +
+	"\x06sha256\x18\a \x01(\tR\x06sha256\x12!\n" +
+	"\fhash_pending\x18\b \x01(\bR\vhashPending\"\xbe\x01\n" +
+	"\rBrowseRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\tR\apattern\x12\x1c\n" +
+	"\trecursive\x18\x03 \x01(\bR\trecursive\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x05 \x01(\tR\tpageToken\x12%\n" +
+	"\x0einclude_hashes\x18\x06 \x01(\bR\rincludeHashes\"m\n" +
+	"\x0eBrowseResponse\x123\n" +
+	"\aentries\x18\x01 \x03(\v2\x19.filetransfer.BrowseEntryR\aentries\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"!\n" +
+	"\vStatRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"\xa6\x01\n" +
+	"\x0fParallelRequest\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x19\n" +
+	"\boffer_id\x18\x02 \x01(\tR\aofferId\x12\x1b\n" +
+	"\tfile_name\x18\x03 \x01(\tR\bfileName\x12\x1b\n" +
+	"\tfile_size\x18\x04 \x01(\x03R\bfileSize\x12\x1d\n" +
+	"\n" +
+	"range_size\x18\x05 \x01(\x03R\trangeSize\"1\n" +
+	"\x0eParallelStatus\x12\x1f\n" +
+	"\vranges_done\x18\x01 \x03(\bR\n" +
+	"rangesDone\"6\n" +
+	"\rRangeResponse\x12%\n" +
+	"\x0ebytes_received\x18\x01 \x01(\x03R\rbytesReceived\"\xc8\x01\n" +
+	"\x0eParallelFinish\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x19\n" +
+	"\boffer_id\x18\x02 \x01(\tR\aofferId\x12\x1b\n" +
+	"\tfile_name\x18\x03 \x01(\tR\bfileName\x12\x1b\n" +
+	"\tfile_size\x18\x04 \x01(\x03R\bfileSize\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\x12\x12\n" +
+	"\x04mode\x18\x06 \x01(\rR\x04mode\x12\x14\n" +
+	"\x05mtime\x18\a \x01(\x03R\x05mtime\"\x91\x02\n" +
+	"\fSyncFileInfo\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x14\n" +
+	"\x05mtime\x18\x03 \x01(\x03R\x05mtime\x12\x12\n" +
+	"\x04mode\x18\x04 \x01(\rR\x04mode\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\x12\x18\n" +
+	"\adeleted\x18\x06 \x01(\bR\adeleted\x12A\n" +
+	"\aversion\x18\a \x03(\v2'.filetransfer.SyncFileInfo.VersionEntryR\aversion\x1a:\n" +
+	"\fVersionEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"/\n" +
+	"\x10SyncIndexRequest\x12\x1b\n" +
+	"\tfolder_id\x18\x01 \x01(\tR\bfolderId\"=\n" +
+	"\tSyncIndex\x120\n" +
+	"\x05files\x18\x01 \x03(\v2\x1a.filetransfer.SyncFileInfoR\x05files\"\x9b\x01\n" +
+	"\x0fSyncFileRequest\x12\x1b\n" +
+	"\tfolder_id\x18\x01 \x01(\tR\bfolderId\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x03R\x06offset\x12'\n" +
+	"\x0fchunks_received\x18\x05 \x01(\x03R\x0echunksReceived\"\x14\n" +
+	"\x12SyncNotifyResponse2\xaf\a\n" +
 	"\x13FileTransferService\x12I\n" +
-	"\bSendFile\x12\x17.filetransfer.FileChunk\x1a\".filetransfer.FileTransferResponse(\x01B\tZ\a./protob\x06proto3"
+	"\bSendFile\x12\x17.filetransfer.FileChunk\x1a\".filetransfer.FileTransferResponse(\x01\x12H\n" +
+	"\vQueryResume\x12\x1b.filetransfer.ResumeRequest\x1a\x1c.filetransfer.ResumeResponse\x12A\n" +
+	"\tOfferFile\x12\x17.filetransfer.FileOffer\x1a\x1b.filetransfer.OfferResponse\x12K\n" +
+	"\x0eCancelTransfer\x12\x1b.filetransfer.CancelRequest\x1a\x1c.filetransfer.CancelResponse\x12@\n" +
+	"\x04Pair\x12\x19.filetransfer.PairMessage\x1a\x19.filetransfer.PairMessage(\x010\x01\x12S\n" +
+	"\x10GetEncryptionKey\x12\".filetransfer.EncryptionKeyRequest\x1a\x1b.filetransfer.EncryptionKey\x12Z\n" +
+	"\x12GetBlockSignatures\x12#.filetransfer.BlockSignatureRequest\x1a\x1d.filetransfer.BlockSignatures0\x01\x12U\n" +
+	"\x0fListSharedFiles\x12$.filetransfer.ListSharedFilesRequest\x1a\x1c.filetransfer.SharedFileList\x12B\n" +
+	"\aGetFile\x12\x1c.filetransfer.GetFileRequest\x1a\x17.filetransfer.FileChunk0\x01\x12L\n" +
+	"\rBeginParallel\x12\x1d.filetransfer.ParallelRequest\x1a\x1c.filetransfer.ParallelStatus\x12C\n" +
+	"\tSendRange\x12\x17.filetransfer.FileChunk\x1a\x1b.filetransfer.RangeResponse(\x01\x12R\n" +
+	"\x0eFinishParallel\x12\x1c.filetransfer.ParallelFinish\x1a\".filetransfer.FileTransferResponse2\x92\x01\n" +
+	"\rBrowseService\x12C\n" +
+	"\x06Browse\x12\x1b.filetransfer.BrowseRequest\x1a\x1c.filetransfer.BrowseResponse\x12<\n" +
+	"\x04Stat\x12\x19.filetransfer.StatRequest\x1a\x19.filetransfer.BrowseEntry2\xee\x01\n" +
+	"\vSyncService\x12C\n" +
+	"\bGetIndex\x12\x1e.filetransfer.SyncIndexRequest\x1a\x17.filetransfer.SyncIndex\x12G\n" +
+	"\vGetSyncFile\x12\x1d.filetransfer.SyncFileRequest\x1a\x17.filetransfer.FileChunk0\x01\x12Q\n" +
+	"\rNotifyChanged\x12\x1e.filetransfer.SyncIndexRequest\x1a .filetransfer.SyncNotifyResponseB\tZ\a./protob\x06proto3"
 
 var (
 	file_proto_filetransfer_proto_rawDescOnce sync.Once
@@ -178,19 +2467,90 @@ func file_proto_filetransfer_proto_rawDescGZIP() []byte {
 	return file_proto_filetransfer_proto_rawDescData
 }
 
-var file_proto_filetransfer_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_filetransfer_proto_msgTypes = make([]protoimpl.MessageInfo, 34)
 var file_proto_filetransfer_proto_goTypes = []any{
-	(*FileChunk)(nil),            // 0: filetransfer.FileChunk
-	(*FileTransferResponse)(nil), // 1: filetransfer.FileTransferResponse
+	(*FileChunk)(nil),              // 0: filetransfer.FileChunk
+	(*BlockCopy)(nil),              // 1: filetransfer.BlockCopy
+	(*EncryptionHeader)(nil),       // 2: filetransfer.EncryptionHeader
+	(*FileTransferResponse)(nil),   // 3: filetransfer.FileTransferResponse
+	(*ResumeRequest)(nil),          // 4: filetransfer.ResumeRequest
+	(*ResumeResponse)(nil),         // 5: filetransfer.ResumeResponse
+	(*OfferedFile)(nil),            // 6: filetransfer.OfferedFile
+	(*FileOffer)(nil),              // 7: filetransfer.FileOffer
+	(*OfferResponse)(nil),          // 8: filetransfer.OfferResponse
+	(*CancelRequest)(nil),          // 9: filetransfer.CancelRequest
+	(*CancelResponse)(nil),         // 10: filetransfer.CancelResponse
+	(*PairMessage)(nil),            // 11: filetransfer.PairMessage
+	(*BlockSignatureRequest)(nil),  // 12: filetransfer.BlockSignatureRequest
+	(*BlockSignatures)(nil),        // 13: filetransfer.BlockSignatures
+	(*EncryptionKeyRequest)(nil),   // 14: filetransfer.EncryptionKeyRequest
+	(*EncryptionKey)(nil),          // 15: filetransfer.EncryptionKey
+	(*ListSharedFilesRequest)(nil), // 16: filetransfer.ListSharedFilesRequest
+	(*SharedFile)(nil),             // 17: filetransfer.SharedFile
+	(*SharedFileList)(nil),         // 18: filetransfer.SharedFileList
+	(*GetFileRequest)(nil),         // 19: filetransfer.GetFileRequest
+	(*BrowseEntry)(nil),            // 20: filetransfer.BrowseEntry
+	(*BrowseRequest)(nil),          // 21: filetransfer.BrowseRequest
+	(*BrowseResponse)(nil),         // 22: filetransfer.BrowseResponse
+	(*StatRequest)(nil),            // 23: filetransfer.StatRequest
+	(*ParallelRequest)(nil),        // 24: filetransfer.ParallelRequest
+	(*ParallelStatus)(nil),         // 25: filetransfer.ParallelStatus
+	(*RangeResponse)(nil),          // 26: filetransfer.RangeResponse
+	(*ParallelFinish)(nil),         // 27: filetransfer.ParallelFinish
+	(*SyncFileInfo)(nil),           // 28: filetransfer.SyncFileInfo
+	(*SyncIndexRequest)(nil),       // 29: filetransfer.SyncIndexRequest
+	(*SyncIndex)(nil),              // 30: filetransfer.SyncIndex
+	(*SyncFileRequest)(nil),        // 31: filetransfer.SyncFileRequest
+	(*SyncNotifyResponse)(nil),     // 32: filetransfer.SyncNotifyResponse
+	nil,                            // 33: filetransfer.SyncFileInfo.VersionEntry
 }
 var file_proto_filetransfer_proto_depIdxs = []int32{
-	0, // 0: filetransfer.FileTransferService.SendFile:input_type -> filetransfer.FileChunk
-	1, // 1: filetransfer.FileTransferService.SendFile:output_type -> filetransfer.FileTransferResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2,  // 0: filetransfer.FileChunk.encryption:type_name -> filetransfer.EncryptionHeader
+	1,  // 1: filetransfer.FileChunk.copy:type_name -> filetransfer.BlockCopy
+	6,  // 2: filetransfer.FileOffer.files:type_name -> filetransfer.OfferedFile
+	17, // 3: filetransfer.SharedFileList.files:type_name -> filetransfer.SharedFile
+	20, // 4: filetransfer.BrowseResponse.entries:type_name -> filetransfer.BrowseEntry
+	33, // 5: filetransfer.SyncFileInfo.version:type_name -> filetransfer.SyncFileInfo.VersionEntry
+	28, // 6: filetransfer.SyncIndex.files:type_name -> filetransfer.SyncFileInfo
+	0,  // 7: filetransfer.FileTransferService.SendFile:input_type -> filetransfer.FileChunk
+	4,  // 8: filetransfer.FileTransferService.QueryResume:input_type -> filetransfer.ResumeRequest
+	7,  // 9: filetransfer.FileTransferService.OfferFile:input_type -> filetransfer.FileOffer
+	9,  // 10: filetransfer.FileTransferService.CancelTransfer:input_type -> filetransfer.CancelRequest
+	11, // 11: filetransfer.FileTransferService.Pair:input_type -> filetransfer.PairMessage
+	14, // 12: filetransfer.FileTransferService.GetEncryptionKey:input_type -> filetransfer.EncryptionKeyRequest
+	12, // 13: filetransfer.FileTransferService.GetBlockSignatures:input_type -> filetransfer.BlockSignatureRequest
+	16, // 14: filetransfer.FileTransferService.ListSharedFiles:input_type -> filetransfer.ListSharedFilesRequest
+	19, // 15: filetransfer.FileTransferService.GetFile:input_type -> filetransfer.GetFileRequest
+	24, // 16: filetransfer.FileTransferService.BeginParallel:input_type -> filetransfer.ParallelRequest
+	0,  // 17: filetransfer.FileTransferService.SendRange:input_type -> filetransfer.FileChunk
+	27, // 18: filetransfer.FileTransferService.FinishParallel:input_type -> filetransfer.ParallelFinish
+	21, // 19: filetransfer.BrowseService.Browse:input_type -> filetransfer.BrowseRequest
+	23, // 20: filetransfer.BrowseService.Stat:input_type -> filetransfer.StatRequest
+	29, // 21: filetransfer.SyncService.GetIndex:input_type -> filetransfer.SyncIndexRequest
+	31, // 22: filetransfer.SyncService.GetSyncFile:input_type -> filetransfer.SyncFileRequest
+	29, // 23: filetransfer.SyncService.NotifyChanged:input_type -> filetransfer.SyncIndexRequest
+	3,  // 24: filetransfer.FileTransferService.SendFile:output_type -> filetransfer.FileTransferResponse
+	5,  // 25: filetransfer.FileTransferService.QueryResume:output_type -> filetransfer.ResumeResponse
+	8,  // 26: filetransfer.FileTransferService.OfferFile:output_type -> filetransfer.OfferResponse
+	10, // 27: filetransfer.FileTransferService.CancelTransfer:output_type -> filetransfer.CancelResponse
+	11, // 28: filetransfer.FileTransferService.Pair:output_type -> filetransfer.PairMessage
+	15, // 29: filetransfer.FileTransferService.GetEncryptionKey:output_type -> filetransfer.EncryptionKey
+	13, // 30: filetransfer.FileTransferService.GetBlockSignatures:output_type -> filetransfer.BlockSignatures
+	18, // 31: filetransfer.FileTransferService.ListSharedFiles:output_type -> filetransfer.SharedFileList
+	0,  // 32: filetransfer.FileTransferService.GetFile:output_type -> filetransfer.FileChunk
+	25, // 33: filetransfer.FileTransferService.BeginParallel:output_type -> filetransfer.ParallelStatus
+	26, // 34: filetransfer.FileTransferService.SendRange:output_type -> filetransfer.RangeResponse
+	3,  // 35: filetransfer.FileTransferService.FinishParallel:output_type -> filetransfer.FileTransferResponse
+	22, // 36: filetransfer.BrowseService.Browse:output_type -> filetransfer.BrowseResponse
+	20, // 37: filetransfer.BrowseService.Stat:output_type -> filetransfer.BrowseEntry
+	30, // 38: filetransfer.SyncService.GetIndex:output_type -> filetransfer.SyncIndex
+	0,  // 39: filetransfer.SyncService.GetSyncFile:output_type -> filetransfer.FileChunk
+	32, // 40: filetransfer.SyncService.NotifyChanged:output_type -> filetransfer.SyncNotifyResponse
+	24, // [24:41] is the sub-list for method output_type
+	7,  // [7:24] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_proto_filetransfer_proto_init() }
@@ -204,9 +2564,9 @@ func file_proto_filetransfer_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_filetransfer_proto_rawDesc), len(file_proto_filetransfer_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   34,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   3,
 		},
 		GoTypes:           file_proto_filetransfer_proto_goTypes,
 		DependencyIndexes: file_proto_filetransfer_proto_depIdxs,
@@ -19,7 +19,18 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	FileTransferService_SendFile_FullMethodName = "/filetransfer.FileTransferService/SendFile"
+	FileTransferService_SendFile_FullMethodName           = "/filetransfer.FileTransferService/SendFile"
+	FileTransferService_QueryResume_FullMethodName        = "/filetransfer.FileTransferService/QueryResume"
+	FileTransferService_OfferFile_FullMethodName          = "/filetransfer.FileTransferService/OfferFile"
+	FileTransferService_CancelTransfer_FullMethodName     = "/filetransfer.FileTransferService/CancelTransfer"
+	FileTransferService_Pair_FullMethodName               = "/filetransfer.FileTransferService/Pair"
+	FileTransferService_GetEncryptionKey_FullMethodName   = "/filetransfer.FileTransferService/GetEncryptionKey"
+	FileTransferService_GetBlockSignatures_FullMethodName = "/filetransfer.FileTransferService/GetBlockSignatures"
+	FileTransferService_ListSharedFiles_FullMethodName    = "/filetransfer.FileTransferService/ListSharedFiles"
+	FileTransferService_GetFile_FullMethodName            = "/filetransfer.FileTransferService/GetFile"
+	FileTransferService_BeginParallel_FullMethodName      = "/filetransfer.FileTransferService/BeginParallel"
+	FileTransferService_SendRange_FullMethodName          = "/filetransfer.FileTransferService/SendRange"
+	FileTransferService_FinishParallel_FullMethodName     = "/filetransfer.FileTransferService/FinishParallel"
 )
 
 // FileTransferServiceClient is the client API for FileTransferService service.
@@ -27,6 +38,17 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type FileTransferServiceClient interface {
 	SendFile(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[FileChunk, FileTransferResponse], error)
+	QueryResume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error)
+	OfferFile(ctx context.Context, in *FileOffer, opts ...grpc.CallOption) (*OfferResponse, error)
+	CancelTransfer(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error)
+	Pair(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PairMessage, PairMessage], error)
+	GetEncryptionKey(ctx context.Context, in *EncryptionKeyRequest, opts ...grpc.CallOption) (*EncryptionKey, error)
+	GetBlockSignatures(ctx context.Context, in *BlockSignatureRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlockSignatures], error)
+	ListSharedFiles(ctx context.Context, in *ListSharedFilesRequest, opts ...grpc.CallOption) (*SharedFileList, error)
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error)
+	BeginParallel(ctx context.Context, in *ParallelRequest, opts ...grpc.CallOption) (*ParallelStatus, error)
+	SendRange(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[FileChunk, RangeResponse], error)
+	FinishParallel(ctx context.Context, in *ParallelFinish, opts ...grpc.CallOption) (*FileTransferResponse, error)
 }
 
 type fileTransferServiceClient struct {
@@ -50,11 +72,156 @@ func (c *fileTransferServiceClient) SendFile(ctx context.Context, opts ...grpc.C
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type FileTransferService_SendFileClient = grpc.ClientStreamingClient[FileChunk, FileTransferResponse]
 
+func (c *fileTransferServiceClient) QueryResume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResumeResponse)
+	err := c.cc.Invoke(ctx, FileTransferService_QueryResume_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) OfferFile(ctx context.Context, in *FileOffer, opts ...grpc.CallOption) (*OfferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OfferResponse)
+	err := c.cc.Invoke(ctx, FileTransferService_OfferFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) CancelTransfer(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelResponse)
+	err := c.cc.Invoke(ctx, FileTransferService_CancelTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) Pair(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PairMessage, PairMessage], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileTransferService_ServiceDesc.Streams[1], FileTransferService_Pair_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PairMessage, PairMessage]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_PairClient = grpc.BidiStreamingClient[PairMessage, PairMessage]
+
+func (c *fileTransferServiceClient) GetEncryptionKey(ctx context.Context, in *EncryptionKeyRequest, opts ...grpc.CallOption) (*EncryptionKey, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EncryptionKey)
+	err := c.cc.Invoke(ctx, FileTransferService_GetEncryptionKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) GetBlockSignatures(ctx context.Context, in *BlockSignatureRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlockSignatures], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileTransferService_ServiceDesc.Streams[2], FileTransferService_GetBlockSignatures_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BlockSignatureRequest, BlockSignatures]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_GetBlockSignaturesClient = grpc.ServerStreamingClient[BlockSignatures]
+
+func (c *fileTransferServiceClient) ListSharedFiles(ctx context.Context, in *ListSharedFilesRequest, opts ...grpc.CallOption) (*SharedFileList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SharedFileList)
+	err := c.cc.Invoke(ctx, FileTransferService_ListSharedFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileTransferService_ServiceDesc.Streams[3], FileTransferService_GetFile_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetFileRequest, FileChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_GetFileClient = grpc.ServerStreamingClient[FileChunk]
+
+func (c *fileTransferServiceClient) BeginParallel(ctx context.Context, in *ParallelRequest, opts ...grpc.CallOption) (*ParallelStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ParallelStatus)
+	err := c.cc.Invoke(ctx, FileTransferService_BeginParallel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fileTransferServiceClient) SendRange(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[FileChunk, RangeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileTransferService_ServiceDesc.Streams[4], FileTransferService_SendRange_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FileChunk, RangeResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_SendRangeClient = grpc.ClientStreamingClient[FileChunk, RangeResponse]
+
+func (c *fileTransferServiceClient) FinishParallel(ctx context.Context, in *ParallelFinish, opts ...grpc.CallOption) (*FileTransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileTransferResponse)
+	err := c.cc.Invoke(ctx, FileTransferService_FinishParallel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // FileTransferServiceServer is the server API for FileTransferService service.
 // All implementations must embed UnimplementedFileTransferServiceServer
 // for forward compatibility.
 type FileTransferServiceServer interface {
 	SendFile(grpc.ClientStreamingServer[FileChunk, FileTransferResponse]) error
+	QueryResume(context.Context, *ResumeRequest) (*ResumeResponse, error)
+	OfferFile(context.Context, *FileOffer) (*OfferResponse, error)
+	CancelTransfer(context.Context, *CancelRequest) (*CancelResponse, error)
+	Pair(grpc.BidiStreamingServer[PairMessage, PairMessage]) error
+	GetEncryptionKey(context.Context, *EncryptionKeyRequest) (*EncryptionKey, error)
+	GetBlockSignatures(*BlockSignatureRequest, grpc.ServerStreamingServer[BlockSignatures]) error
+	ListSharedFiles(context.Context, *ListSharedFilesRequest) (*SharedFileList, error)
+	GetFile(*GetFileRequest, grpc.ServerStreamingServer[FileChunk]) error
+	BeginParallel(context.Context, *ParallelRequest) (*ParallelStatus, error)
+	SendRange(grpc.ClientStreamingServer[FileChunk, RangeResponse]) error
+	FinishParallel(context.Context, *ParallelFinish) (*FileTransferResponse, error)
 	mustEmbedUnimplementedFileTransferServiceServer()
 }
 
@@ -68,6 +235,39 @@ type UnimplementedFileTransferServiceServer struct{}
 func (UnimplementedFileTransferServiceServer) SendFile(grpc.ClientStreamingServer[FileChunk, FileTransferResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method SendFile not implemented")
 }
+func (UnimplementedFileTransferServiceServer) QueryResume(context.Context, *ResumeRequest) (*ResumeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryResume not implemented")
+}
+func (UnimplementedFileTransferServiceServer) OfferFile(context.Context, *FileOffer) (*OfferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OfferFile not implemented")
+}
+func (UnimplementedFileTransferServiceServer) CancelTransfer(context.Context, *CancelRequest) (*CancelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelTransfer not implemented")
+}
+func (UnimplementedFileTransferServiceServer) Pair(grpc.BidiStreamingServer[PairMessage, PairMessage]) error {
+	return status.Errorf(codes.Unimplemented, "method Pair not implemented")
+}
+func (UnimplementedFileTransferServiceServer) GetEncryptionKey(context.Context, *EncryptionKeyRequest) (*EncryptionKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEncryptionKey not implemented")
+}
+func (UnimplementedFileTransferServiceServer) GetBlockSignatures(*BlockSignatureRequest, grpc.ServerStreamingServer[BlockSignatures]) error {
+	return status.Errorf(codes.Unimplemented, "method GetBlockSignatures not implemented")
+}
+func (UnimplementedFileTransferServiceServer) ListSharedFiles(context.Context, *ListSharedFilesRequest) (*SharedFileList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSharedFiles not implemented")
+}
+func (UnimplementedFileTransferServiceServer) GetFile(*GetFileRequest, grpc.ServerStreamingServer[FileChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method GetFile not implemented")
+}
+func (UnimplementedFileTransferServiceServer) BeginParallel(context.Context, *ParallelRequest) (*ParallelStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BeginParallel not implemented")
+}
+func (UnimplementedFileTransferServiceServer) SendRange(grpc.ClientStreamingServer[FileChunk, RangeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method SendRange not implemented")
+}
+func (UnimplementedFileTransferServiceServer) FinishParallel(context.Context, *ParallelFinish) (*FileTransferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishParallel not implemented")
+}
 func (UnimplementedFileTransferServiceServer) mustEmbedUnimplementedFileTransferServiceServer() {}
 func (UnimplementedFileTransferServiceServer) testEmbeddedByValue()                             {}
 
@@ -96,19 +296,553 @@ func _FileTransferService_SendFile_Handler(srv interface{}, stream grpc.ServerSt
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type FileTransferService_SendFileServer = grpc.ClientStreamingServer[FileChunk, FileTransferResponse]
 
+func _FileTransferService_QueryResume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).QueryResume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_QueryResume_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).QueryResume(ctx, req.(*ResumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_OfferFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileOffer)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).OfferFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_OfferFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).OfferFile(ctx, req.(*FileOffer))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_CancelTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).CancelTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_CancelTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).CancelTransfer(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_Pair_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileTransferServiceServer).Pair(&grpc.GenericServerStream[PairMessage, PairMessage]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_PairServer = grpc.BidiStreamingServer[PairMessage, PairMessage]
+
+func _FileTransferService_GetEncryptionKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncryptionKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).GetEncryptionKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_GetEncryptionKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).GetEncryptionKey(ctx, req.(*EncryptionKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_GetBlockSignatures_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlockSignatureRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FileTransferServiceServer).GetBlockSignatures(m, &grpc.GenericServerStream[BlockSignatureRequest, BlockSignatures]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_GetBlockSignaturesServer = grpc.ServerStreamingServer[BlockSignatures]
+
+func _FileTransferService_ListSharedFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSharedFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).ListSharedFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_ListSharedFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).ListSharedFiles(ctx, req.(*ListSharedFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FileTransferServiceServer).GetFile(m, &grpc.GenericServerStream[GetFileRequest, FileChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_GetFileServer = grpc.ServerStreamingServer[FileChunk]
+
+func _FileTransferService_BeginParallel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParallelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).BeginParallel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_BeginParallel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).BeginParallel(ctx, req.(*ParallelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FileTransferService_SendRange_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FileTransferServiceServer).SendRange(&grpc.GenericServerStream[FileChunk, RangeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileTransferService_SendRangeServer = grpc.ClientStreamingServer[FileChunk, RangeResponse]
+
+func _FileTransferService_FinishParallel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParallelFinish)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FileTransferServiceServer).FinishParallel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FileTransferService_FinishParallel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FileTransferServiceServer).FinishParallel(ctx, req.(*ParallelFinish))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // FileTransferService_ServiceDesc is the grpc.ServiceDesc for FileTransferService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var FileTransferService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "filetransfer.FileTransferService",
 	HandlerType: (*FileTransferServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QueryResume",
+			Handler:    _FileTransferService_QueryResume_Handler,
+		},
+		{
+			MethodName: "OfferFile",
+			Handler:    _FileTransferService_OfferFile_Handler,
+		},
+		{
+			MethodName: "CancelTransfer",
+			Handler:    _FileTransferService_CancelTransfer_Handler,
+		},
+		{
+			MethodName: "GetEncryptionKey",
+			Handler:    _FileTransferService_GetEncryptionKey_Handler,
+		},
+		{
+			MethodName: "ListSharedFiles",
+			Handler:    _FileTransferService_ListSharedFiles_Handler,
+		},
+		{
+			MethodName: "BeginParallel",
+			Handler:    _FileTransferService_BeginParallel_Handler,
+		},
+		{
+			MethodName: "FinishParallel",
+			Handler:    _FileTransferService_FinishParallel_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "SendFile",
 			Handler:       _FileTransferService_SendFile_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Pair",
+			Handler:       _FileTransferService_Pair_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetBlockSignatures",
+			Handler:       _FileTransferService_GetBlockSignatures_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetFile",
+			Handler:       _FileTransferService_GetFile_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SendRange",
+			Handler:       _FileTransferService_SendRange_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "proto/filetransfer.proto",
+}
+
+const (
+	BrowseService_Browse_FullMethodName = "/filetransfer.BrowseService/Browse"
+	BrowseService_Stat_FullMethodName   = "/filetransfer.BrowseService/Stat"
+)
+
+// BrowseServiceClient is the client API for BrowseService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BrowseServiceClient interface {
+	Browse(ctx context.Context, in *BrowseRequest, opts ...grpc.CallOption) (*BrowseResponse, error)
+	Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*BrowseEntry, error)
+}
+
+type browseServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBrowseServiceClient(cc grpc.ClientConnInterface) BrowseServiceClient {
+	return &browseServiceClient{cc}
+}
+
+func (c *browseServiceClient) Browse(ctx context.Context, in *BrowseRequest, opts ...grpc.CallOption) (*BrowseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BrowseResponse)
+	err := c.cc.Invoke(ctx, BrowseService_Browse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *browseServiceClient) Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*BrowseEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BrowseEntry)
+	err := c.cc.Invoke(ctx, BrowseService_Stat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BrowseServiceServer is the server API for BrowseService service.
+// All implementations must embed UnimplementedBrowseServiceServer
+// for forward compatibility.
+type BrowseServiceServer interface {
+	Browse(context.Context, *BrowseRequest) (*BrowseResponse, error)
+	Stat(context.Context, *StatRequest) (*BrowseEntry, error)
+	mustEmbedUnimplementedBrowseServiceServer()
+}
+
+// UnimplementedBrowseServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBrowseServiceServer struct{}
+
+func (UnimplementedBrowseServiceServer) Browse(context.Context, *BrowseRequest) (*BrowseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Browse not implemented")
+}
+func (UnimplementedBrowseServiceServer) Stat(context.Context, *StatRequest) (*BrowseEntry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stat not implemented")
+}
+func (UnimplementedBrowseServiceServer) mustEmbedUnimplementedBrowseServiceServer() {}
+func (UnimplementedBrowseServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeBrowseServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BrowseServiceServer will
+// result in compilation errors.
+type UnsafeBrowseServiceServer interface {
+	mustEmbedUnimplementedBrowseServiceServer()
+}
+
+func RegisterBrowseServiceServer(s grpc.ServiceRegistrar, srv BrowseServiceServer) {
+	// If the following call pancis, it indicates UnimplementedBrowseServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&BrowseService_ServiceDesc, srv)
+}
+
+func _BrowseService_Browse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BrowseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BrowseServiceServer).Browse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BrowseService_Browse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BrowseServiceServer).Browse(ctx, req.(*BrowseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BrowseService_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BrowseServiceServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BrowseService_Stat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BrowseServiceServer).Stat(ctx, req.(*StatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BrowseService_ServiceDesc is the grpc.ServiceDesc for BrowseService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BrowseService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "filetransfer.BrowseService",
+	HandlerType: (*BrowseServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Browse",
+			Handler:    _BrowseService_Browse_Handler,
+		},
+		{
+			MethodName: "Stat",
+			Handler:    _BrowseService_Stat_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/filetransfer.proto",
+}
+
+const (
+	SyncService_GetIndex_FullMethodName      = "/filetransfer.SyncService/GetIndex"
+	SyncService_GetSyncFile_FullMethodName   = "/filetransfer.SyncService/GetSyncFile"
+	SyncService_NotifyChanged_FullMethodName = "/filetransfer.SyncService/NotifyChanged"
+)
+
+// SyncServiceClient is the client API for SyncService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SyncServiceClient interface {
+	GetIndex(ctx context.Context, in *SyncIndexRequest, opts ...grpc.CallOption) (*SyncIndex, error)
+	GetSyncFile(ctx context.Context, in *SyncFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error)
+	NotifyChanged(ctx context.Context, in *SyncIndexRequest, opts ...grpc.CallOption) (*SyncNotifyResponse, error)
+}
+
+type syncServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSyncServiceClient(cc grpc.ClientConnInterface) SyncServiceClient {
+	return &syncServiceClient{cc}
+}
+
+func (c *syncServiceClient) GetIndex(ctx context.Context, in *SyncIndexRequest, opts ...grpc.CallOption) (*SyncIndex, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncIndex)
+	err := c.cc.Invoke(ctx, SyncService_GetIndex_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *syncServiceClient) GetSyncFile(ctx context.Context, in *SyncFileRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SyncService_ServiceDesc.Streams[0], SyncService_GetSyncFile_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SyncFileRequest, FileChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SyncService_GetSyncFileClient = grpc.ServerStreamingClient[FileChunk]
+
+func (c *syncServiceClient) NotifyChanged(ctx context.Context, in *SyncIndexRequest, opts ...grpc.CallOption) (*SyncNotifyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncNotifyResponse)
+	err := c.cc.Invoke(ctx, SyncService_NotifyChanged_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SyncServiceServer is the server API for SyncService service.
+// All implementations must embed UnimplementedSyncServiceServer
+// for forward compatibility.
+type SyncServiceServer interface {
+	GetIndex(context.Context, *SyncIndexRequest) (*SyncIndex, error)
+	GetSyncFile(*SyncFileRequest, grpc.ServerStreamingServer[FileChunk]) error
+	NotifyChanged(context.Context, *SyncIndexRequest) (*SyncNotifyResponse, error)
+	mustEmbedUnimplementedSyncServiceServer()
+}
+
+// UnimplementedSyncServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSyncServiceServer struct{}
+
+func (UnimplementedSyncServiceServer) GetIndex(context.Context, *SyncIndexRequest) (*SyncIndex, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetIndex not implemented")
+}
+func (UnimplementedSyncServiceServer) GetSyncFile(*SyncFileRequest, grpc.ServerStreamingServer[FileChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method GetSyncFile not implemented")
+}
+func (UnimplementedSyncServiceServer) NotifyChanged(context.Context, *SyncIndexRequest) (*SyncNotifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NotifyChanged not implemented")
+}
+func (UnimplementedSyncServiceServer) mustEmbedUnimplementedSyncServiceServer() {}
+func (UnimplementedSyncServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeSyncServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SyncServiceServer will
+// result in compilation errors.
+type UnsafeSyncServiceServer interface {
+	mustEmbedUnimplementedSyncServiceServer()
+}
+
+func RegisterSyncServiceServer(s grpc.ServiceRegistrar, srv SyncServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSyncServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SyncService_ServiceDesc, srv)
+}
+
+func _SyncService_GetIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncIndexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SyncServiceServer).GetIndex(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SyncService_GetIndex_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SyncServiceServer).GetIndex(ctx, req.(*SyncIndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SyncService_GetSyncFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SyncFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SyncServiceServer).GetSyncFile(m, &grpc.GenericServerStream[SyncFileRequest, FileChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SyncService_GetSyncFileServer = grpc.ServerStreamingServer[FileChunk]
+
+func _SyncService_NotifyChanged_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncIndexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SyncServiceServer).NotifyChanged(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SyncService_NotifyChanged_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SyncServiceServer).NotifyChanged(ctx, req.(*SyncIndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SyncService_ServiceDesc is the grpc.ServiceDesc for SyncService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SyncService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "filetransfer.SyncService",
+	HandlerType: (*SyncServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetIndex",
+			Handler:    _SyncService_GetIndex_Handler,
+		},
+		{
+			MethodName: "NotifyChanged",
+			Handler:    _SyncService_NotifyChanged_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetSyncFile",
+			Handler:       _SyncService_GetSyncFile_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/filetransfer.proto",
 }